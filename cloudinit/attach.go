@@ -0,0 +1,189 @@
+package cloudinit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adrg/xdg"
+
+	"github.com/calebstewart/vroomm/config"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Builds NoCloud seeds for domains and attaches them
+type Seeder struct {
+	Conn        *virt.Connection // Libvirt connection
+	Pool        string           // Storage pool seed images are uploaded to
+	Dir         string           // Directory holding user-data, meta-data and network-config templates
+	User        string           // Default user
+	SSHKeys     []string         // SSH public keys authorized in every seed
+	SSHKeyFiles []string         // Files of SSH public keys authorized in every seed
+	DNS         []string         // Default name servers of static addresses
+}
+
+func New(cfg *config.Config, conn *virt.Connection) *Seeder {
+	return &Seeder{
+		Conn:        conn,
+		Pool:        cfg.CloudInit.Pool,
+		Dir:         filepath.Join(xdg.ConfigHome, "vroomm", "cloud-init"),
+		User:        cfg.CloudInit.User,
+		SSHKeys:     cfg.CloudInit.SSHKeys,
+		SSHKeyFiles: cfg.CloudInit.SSHKeyFiles,
+		DNS:         cfg.CloudInit.DNS,
+	}
+}
+
+// Fill in the variables from the domain and the configured defaults, and
+// render the seed. The SSH keys given are authorized in addition to the
+// configured ones. Every seed gets a new instance ID, so cloud-init applies
+// it even if the guest was already set up from an older one.
+func (s *Seeder) Prepare(domain *virt.Domain, vars Variables) (*Seed, error) {
+	name, err := domain.GetName()
+	if err != nil {
+		return nil, err
+	}
+
+	uuid, err := domain.GetUUIDString()
+	if err != nil {
+		return nil, err
+	}
+
+	if vars.Name == "" {
+		vars.Name = name
+	}
+	if vars.InstanceID == "" {
+		vars.InstanceID = fmt.Sprintf("%v-%v", uuid, time.Now().Unix())
+	}
+	if vars.Hostname == "" {
+		vars.Hostname = name
+	}
+	if vars.MAC == "" {
+		if interfaces, err := domain.Interfaces(); err != nil {
+			return nil, err
+		} else if len(interfaces) > 0 && interfaces[0].MAC != nil {
+			vars.MAC = interfaces[0].MAC.Address
+		}
+	}
+	if vars.User == "" {
+		vars.User = s.User
+	}
+	if vars.Address != "" && len(vars.DNS) == 0 {
+		vars.DNS = s.DNS
+	}
+
+	keys, err := s.authorizedKeys()
+	if err != nil {
+		return nil, err
+	}
+	vars.SSHKeys = append(keys, vars.SSHKeys...)
+
+	if err := vars.Validate(); err != nil {
+		return nil, err
+	}
+
+	return Render(s.Dir, vars)
+}
+
+// Render a seed, upload it to a new volume in the pool and insert it into
+// the domain. If the domain still holds an older seed, that drive is reused
+// so the guest never sees two seeds, and the old seed is removed once nothing
+// uses it anymore. Clones never hold the seed of their source.
+// The volume is recorded in the domain metadata, so it is removed with the
+// domain.
+func (s *Seeder) Attach(domain *virt.Domain, vars Variables) (*Seed, error) {
+	seed, err := s.Prepare(domain, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := s.Conn.UploadVolume(s.Pool, fmt.Sprintf("%v-cidata-%v.iso", seed.Variables.Name, time.Now().Unix()), seed.Image())
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := domain.GetVmmData()
+	previous := metadata.Seed
+
+	target := ""
+	if previous != "" {
+		cdroms, err := domain.Disks("cdrom")
+		if err != nil {
+			return nil, errors.Join(err, s.Conn.DeleteVolume(path))
+		}
+		for idx := range cdroms {
+			if virt.DiskSourcePath(&cdroms[idx]) == previous {
+				target = cdroms[idx].Target.Dev
+			}
+		}
+	}
+
+	attached := target
+	if target != "" {
+		err = domain.ChangeMedia(target, path)
+	} else {
+		attached, err = domain.AttachCDROM(path)
+	}
+	if err != nil {
+		return nil, errors.Join(err, s.Conn.DeleteVolume(path))
+	}
+
+	// Without the metadata, the new seed would never be cleaned up, so put
+	// the old one back and remove the new one
+	metadata.Seed = path
+	if err := domain.UpdateVmmData(metadata); err != nil {
+		if target != "" {
+			err = errors.Join(err, domain.ChangeMedia(target, previous))
+		} else {
+			err = errors.Join(err, domain.DetachDisk(attached))
+		}
+		return nil, errors.Join(err, s.Conn.DeleteVolume(path))
+	}
+
+	if target != "" {
+		if users, err := s.Conn.DiskUsers(previous); err != nil {
+			return seed, fmt.Errorf("attached seed, but failed to check the old one: %w", err)
+		} else if len(users) == 0 {
+			if err := s.Conn.DeleteVolume(previous); err != nil {
+				return seed, fmt.Errorf("attached seed, but failed to remove the old one: %w", err)
+			}
+		}
+	}
+
+	return seed, nil
+}
+
+// Collect the configured SSH keys, skipping blank lines and comments in key
+// files. A leading "~/" in a file name is the home directory.
+func (s *Seeder) authorizedKeys() ([]string, error) {
+	keys := append([]string{}, s.SSHKeys...)
+
+	for _, path := range s.SSHKeyFiles {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(home, rest)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				keys = append(keys, line)
+			}
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,266 @@
+package cloudinit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// A minimal ISO9660 image writer with Joliet extensions. It only supports
+// a handful of small files in the root directory, which is all a NoCloud
+// seed needs. The primary volume descriptor carries upper case 8.3 names
+// for strict readers, and the Joliet tree carries the real file names
+// (cloud-init looks for "user-data", "meta-data" and "network-config").
+//
+// Layout, in 2048 byte sectors:
+//
+//	0-15   system area (empty)
+//	16     primary volume descriptor
+//	17     Joliet supplementary volume descriptor
+//	18     volume descriptor set terminator
+//	19-22  path tables (primary L/M, Joliet L/M)
+//	23     primary root directory
+//	24     Joliet root directory
+//	25-    file data, shared by both trees
+const (
+	sectorSize = 2048
+
+	primaryDescriptorSector = 16
+	jolietDescriptorSector  = 17
+	terminatorSector        = 18
+	primaryLPathSector      = 19
+	primaryMPathSector      = 20
+	jolietLPathSector       = 21
+	jolietMPathSector       = 22
+	primaryRootSector       = 23
+	jolietRootSector        = 24
+	firstFileSector         = 25
+
+	pathTableSize = 10 // A single root entry
+)
+
+// A file of the image, with its position in the data area
+type isoFile struct {
+	name   string
+	data   []byte
+	sector uint32
+}
+
+// Build an ISO9660/Joliet image containing the given files in its root
+// directory. Every directory record must fit in one sector, which limits
+// the image to a few dozen files.
+func buildISO(label string, files map[string][]byte, now time.Time) []byte {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sector := uint32(firstFileSector)
+	entries := []*isoFile{}
+	for _, name := range names {
+		entries = append(entries, &isoFile{name: name, data: files[name], sector: sector})
+		sector += sectorsFor(len(files[name]))
+	}
+	totalSectors := sector
+
+	image := make([]byte, int(totalSectors)*sectorSize)
+	at := func(sector uint32) []byte {
+		return image[int(sector)*sectorSize : int(sector+1)*sectorSize]
+	}
+
+	primaryRoot := directory(entries, primaryRootSector, now, primaryName, encodePrimary)
+	jolietRoot := directory(entries, jolietRootSector, now, func(name string) string { return name }, encodeJoliet)
+
+	writeDescriptor(at(primaryDescriptorSector), 1, label, totalSectors, primaryLPathSector, primaryMPathSector, primaryRootSector, now, false)
+	writeDescriptor(at(jolietDescriptorSector), 2, label, totalSectors, jolietLPathSector, jolietMPathSector, jolietRootSector, now, true)
+
+	terminator := at(terminatorSector)
+	terminator[0] = 255
+	copy(terminator[1:6], "CD001")
+	terminator[6] = 1
+
+	writePathTable(at(primaryLPathSector), primaryRootSector, binary.LittleEndian)
+	writePathTable(at(primaryMPathSector), primaryRootSector, binary.BigEndian)
+	writePathTable(at(jolietLPathSector), jolietRootSector, binary.LittleEndian)
+	writePathTable(at(jolietMPathSector), jolietRootSector, binary.BigEndian)
+
+	copy(at(primaryRootSector), primaryRoot)
+	copy(at(jolietRootSector), jolietRoot)
+
+	for _, entry := range entries {
+		copy(image[int(entry.sector)*sectorSize:], entry.data)
+	}
+
+	return image
+}
+
+func sectorsFor(size int) uint32 {
+	return uint32((size + sectorSize - 1) / sectorSize)
+}
+
+// Build the root directory extent: the "." and ".." entries followed by one
+// record per file, sorted by their on-disc names.
+func directory(entries []*isoFile, self uint32, now time.Time, rename func(string) string, encode func(string) []byte) []byte {
+	sorted := append([]*isoFile{}, entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(encode(rename(sorted[i].name)), encode(rename(sorted[j].name))) < 0
+	})
+
+	extent := &bytes.Buffer{}
+	extent.Write(directoryRecord([]byte{0}, self, sectorSize, true, now))
+	extent.Write(directoryRecord([]byte{1}, self, sectorSize, true, now))
+	for _, entry := range sorted {
+		extent.Write(directoryRecord(encode(rename(entry.name)), entry.sector, uint32(len(entry.data)), false, now))
+	}
+
+	return extent.Bytes()
+}
+
+// Encode a single directory record
+func directoryRecord(name []byte, sector uint32, size uint32, isDirectory bool, now time.Time) []byte {
+	length := 33 + len(name)
+	if length%2 != 0 {
+		length++
+	}
+
+	record := make([]byte, length)
+	record[0] = byte(length)
+	putBothEndian32(record[2:10], sector)
+	putBothEndian32(record[10:18], size)
+	copy(record[18:25], recordingDate(now))
+	if isDirectory {
+		record[25] = 2
+	}
+	putBothEndian16(record[28:32], 1)
+	record[32] = byte(len(name))
+	copy(record[33:], name)
+
+	return record
+}
+
+// Fill in a primary (type 1) or Joliet supplementary (type 2) volume
+// descriptor. Identifiers of the Joliet descriptor are UCS-2.
+func writeDescriptor(descriptor []byte, kind byte, label string, totalSectors uint32, lPath uint32, mPath uint32, root uint32, now time.Time, joliet bool) {
+	text := func(field []byte, value string) {
+		if joliet {
+			encoded := encodeJoliet(value)
+			for idx := 0; idx+1 < len(field); idx += 2 {
+				field[idx], field[idx+1] = 0, ' '
+			}
+			copy(field, encoded)
+		} else {
+			for idx := range field {
+				field[idx] = ' '
+			}
+			copy(field, value)
+		}
+	}
+
+	descriptor[0] = kind
+	copy(descriptor[1:6], "CD001")
+	descriptor[6] = 1
+	text(descriptor[8:40], "")
+	text(descriptor[40:72], label)
+	putBothEndian32(descriptor[80:88], totalSectors)
+	if joliet {
+		// UCS-2 level 3
+		copy(descriptor[88:91], "%/E")
+	}
+	putBothEndian16(descriptor[120:124], 1)
+	putBothEndian16(descriptor[124:128], 1)
+	putBothEndian16(descriptor[128:132], sectorSize)
+	putBothEndian32(descriptor[132:140], pathTableSize)
+	binary.LittleEndian.PutUint32(descriptor[140:144], lPath)
+	binary.BigEndian.PutUint32(descriptor[148:152], mPath)
+	copy(descriptor[156:190], directoryRecord([]byte{0}, root, sectorSize, true, now))
+	text(descriptor[190:318], "")
+	text(descriptor[318:446], "")
+	text(descriptor[446:574], "")
+	text(descriptor[574:702], "vroomm")
+	text(descriptor[702:739], "")
+	text(descriptor[739:776], "")
+	text(descriptor[776:813], "")
+	copy(descriptor[813:830], volumeDate(now))
+	copy(descriptor[830:847], volumeDate(now))
+	copy(descriptor[847:864], volumeDate(time.Time{}))
+	copy(descriptor[864:881], volumeDate(time.Time{}))
+	descriptor[881] = 1
+}
+
+// A path table holding only the root directory
+func writePathTable(table []byte, root uint32, order binary.ByteOrder) {
+	table[0] = 1 // Name length
+	order.PutUint32(table[2:6], root)
+	order.PutUint16(table[6:8], 1) // Parent directory number
+	table[8] = 0                   // Name of the root
+}
+
+// Convert a file name to an upper case 8.3 name using only d-characters
+func primaryName(name string) string {
+	base, extension, _ := strings.Cut(name, ".")
+	clean := func(text string, limit int) string {
+		result := []rune{}
+		for _, r := range strings.ToUpper(text) {
+			if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+				r = '_'
+			}
+			result = append(result, r)
+		}
+		if len(result) > limit {
+			result = result[:limit]
+		}
+		return string(result)
+	}
+
+	return clean(base, 8) + "." + clean(extension, 3) + ";1"
+}
+
+func encodePrimary(name string) []byte {
+	return []byte(name)
+}
+
+// Encode a name as big endian UCS-2
+func encodeJoliet(name string) []byte {
+	encoded := []byte{}
+	for _, unit := range utf16.Encode([]rune(name)) {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+	return encoded
+}
+
+// The 7 byte date format used by directory records
+func recordingDate(now time.Time) []byte {
+	now = now.UTC()
+	return []byte{
+		byte(now.Year() - 1900),
+		byte(now.Month()),
+		byte(now.Day()),
+		byte(now.Hour()),
+		byte(now.Minute()),
+		byte(now.Second()),
+		0,
+	}
+}
+
+// The 17 byte date format used by volume descriptors. The zero time is
+// encoded as "not specified".
+func volumeDate(now time.Time) []byte {
+	if now.IsZero() {
+		return append([]byte(strings.Repeat("0", 16)), 0)
+	}
+	return append([]byte(now.UTC().Format("20060102150405")+"00"), 0)
+}
+
+func putBothEndian16(field []byte, value uint16) {
+	binary.LittleEndian.PutUint16(field[0:2], value)
+	binary.BigEndian.PutUint16(field[2:4], value)
+}
+
+func putBothEndian32(field []byte, value uint32) {
+	binary.LittleEndian.PutUint32(field[0:4], value)
+	binary.BigEndian.PutUint32(field[4:8], value)
+}
@@ -0,0 +1,163 @@
+package cloudinit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// A file found by walking a root directory extent
+type isoEntry struct {
+	name string
+	data []byte
+}
+
+// Read the root directory records of the volume descriptor in the given
+// sector, skipping the "." and ".." entries
+func readRoot(t *testing.T, image []byte, descriptorSector int, decode func([]byte) string) []isoEntry {
+	t.Helper()
+
+	descriptor := image[descriptorSector*sectorSize:]
+	root := descriptor[156:190]
+	extent := int(binary.LittleEndian.Uint32(root[2:6]))
+	if big := int(binary.BigEndian.Uint32(root[6:10])); big != extent {
+		t.Fatalf("root extent %v (little endian) != %v (big endian)", extent, big)
+	}
+
+	entries := []isoEntry{}
+	records := image[extent*sectorSize : (extent+1)*sectorSize]
+	for offset, idx := 0, 0; offset < len(records) && records[offset] != 0; idx++ {
+		record := records[offset : offset+int(records[offset])]
+		offset += len(record)
+
+		sector := binary.LittleEndian.Uint32(record[2:6])
+		size := binary.LittleEndian.Uint32(record[10:14])
+		if binary.BigEndian.Uint32(record[6:10]) != sector || binary.BigEndian.Uint32(record[14:18]) != size {
+			t.Errorf("record %v: both-endian fields disagree", idx)
+		}
+
+		name := record[33 : 33+int(record[32])]
+		if idx < 2 {
+			if record[25] != 2 || !bytes.Equal(name, []byte{byte(idx)}) {
+				t.Errorf("record %v: expected a '.' or '..' directory entry, got %q", idx, name)
+			}
+			continue
+		}
+
+		entries = append(entries, isoEntry{
+			name: decode(name),
+			data: image[int(sector)*sectorSize : int(sector)*sectorSize+int(size)],
+		})
+	}
+
+	return entries
+}
+
+func decodeJoliet(encoded []byte) string {
+	units := []uint16{}
+	for idx := 0; idx+1 < len(encoded); idx += 2 {
+		units = append(units, uint16(encoded[idx])<<8|uint16(encoded[idx+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func TestBuildISO(t *testing.T) {
+	files := map[string][]byte{
+		"user-data":      []byte("#cloud-config\nhostname: test\n"),
+		"meta-data":      []byte("instance-id: test-1\n"),
+		"network-config": bytes.Repeat([]byte("x"), sectorSize+1), // Spans two sectors
+	}
+
+	image := buildISO(seedLabel, files, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC))
+	if len(image)%sectorSize != 0 {
+		t.Fatalf("image size %v is not a multiple of the sector size", len(image))
+	}
+
+	tests := []struct {
+		name   string
+		sector int
+		kind   byte
+		label  func([]byte) string
+		want   map[string]string // On-disc name to file name
+	}{
+		{
+			name:   "primary",
+			sector: primaryDescriptorSector,
+			kind:   1,
+			label:  func(field []byte) string { return strings.TrimRight(string(field), " ") },
+			want:   map[string]string{"USER_DAT.;1": "user-data", "META_DAT.;1": "meta-data", "NETWORK_.;1": "network-config"},
+		},
+		{
+			name:   "joliet",
+			sector: jolietDescriptorSector,
+			kind:   2,
+			label:  func(field []byte) string { return strings.TrimRight(decodeJoliet(field), " ") },
+			want:   map[string]string{"user-data": "user-data", "meta-data": "meta-data", "network-config": "network-config"},
+		},
+	}
+
+	for _, test := range tests {
+		descriptor := image[test.sector*sectorSize:]
+		if descriptor[0] != test.kind || string(descriptor[1:6]) != "CD001" {
+			t.Errorf("%v: bad volume descriptor header % x", test.name, descriptor[:7])
+			continue
+		}
+		if label := test.label(descriptor[40:72]); label != seedLabel {
+			t.Errorf("%v: volume label %q, want %q", test.name, label, seedLabel)
+		}
+		if total := binary.LittleEndian.Uint32(descriptor[80:84]); int(total)*sectorSize != len(image) {
+			t.Errorf("%v: volume size %v sectors, image has %v", test.name, total, len(image)/sectorSize)
+		}
+
+		entries := readRoot(t, image, test.sector, func(name []byte) string {
+			if test.kind == 2 {
+				return decodeJoliet(name)
+			}
+			return string(name)
+		})
+		if len(entries) != len(test.want) {
+			t.Errorf("%v: got %v files, want %v", test.name, len(entries), len(test.want))
+		}
+
+		previous := ""
+		for _, entry := range entries {
+			file, ok := test.want[entry.name]
+			if !ok {
+				t.Errorf("%v: unexpected file %q", test.name, entry.name)
+			} else if !bytes.Equal(entry.data, files[file]) {
+				t.Errorf("%v: contents of %q don't match %q", test.name, entry.name, file)
+			}
+			if entry.name < previous {
+				t.Errorf("%v: %q is sorted after %q", test.name, entry.name, previous)
+			}
+			previous = entry.name
+		}
+	}
+
+	terminator := image[terminatorSector*sectorSize:]
+	if terminator[0] != 255 || string(terminator[1:6]) != "CD001" {
+		t.Errorf("bad volume descriptor set terminator % x", terminator[:7])
+	}
+}
+
+func TestPrimaryName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "user-data", want: "USER_DAT.;1"},
+		{name: "meta-data", want: "META_DAT.;1"},
+		{name: "a.txt", want: "A.TXT;1"},
+		{name: "archive.tar.gz", want: "ARCHIVE.TAR;1"},
+		{name: "notes.markdown", want: "NOTES.MAR;1"},
+	}
+
+	for _, test := range tests {
+		if got := primaryName(test.name); got != test.want {
+			t.Errorf("primaryName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package cloudinit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Names of the seed files, which are also the names of their templates
+var seedFiles = []string{"meta-data", "user-data", "network-config"}
+
+// Built-in templates, used for any file without a template in the template
+// directory. The network config is empty (and left out of the seed) unless
+// a static address is given, so the guest falls back to DHCP.
+var builtinTemplates = map[string]string{
+	"meta-data": `instance-id: {{ quote .InstanceID }}
+local-hostname: {{ quote .Hostname }}
+`,
+	"user-data": `#cloud-config
+preserve_hostname: false
+hostname: {{ quote .Hostname }}
+{{- if .User }}
+users:
+  - default
+  - name: {{ quote .User }}
+    shell: /bin/bash
+    sudo: "ALL=(ALL) NOPASSWD:ALL"
+    lock_passwd: true
+{{- if .SSHKeys }}
+    ssh_authorized_keys:
+{{- range .SSHKeys }}
+      - {{ quote . }}
+{{- end }}
+{{- end }}
+{{- else if .SSHKeys }}
+ssh_authorized_keys:
+{{- range .SSHKeys }}
+  - {{ quote . }}
+{{- end }}
+{{- end }}
+`,
+	"network-config": `{{- if .Address -}}
+version: 2
+ethernets:
+  primary:
+    match:
+{{- if .MAC }}
+      macaddress: {{ quote .MAC }}
+{{- else }}
+      name: "e*"
+{{- end }}
+    dhcp4: false
+    addresses:
+      - {{ quote .Address }}
+{{- if .Gateway }}
+    {{ if ipv6 .Gateway }}gateway6{{ else }}gateway4{{ end }}: {{ quote .Gateway }}
+{{- end }}
+{{- if .DNS }}
+    nameservers:
+      addresses:
+{{- range .DNS }}
+        - {{ quote . }}
+{{- end }}
+{{- end }}
+{{ end -}}
+`,
+}
+
+var templateFuncs = template.FuncMap{
+	// Quote a string for YAML. JSON strings are valid YAML scalars.
+	"quote": func(value string) (string, error) {
+		quoted, err := json.Marshal(value)
+		return string(quoted), err
+	},
+	// Check whether an address (or CIDR) is IPv6
+	"ipv6": func(value string) bool {
+		address, _, _ := strings.Cut(value, "/")
+		ip := net.ParseIP(address)
+		return ip != nil && ip.To4() == nil
+	},
+}
+
+// Render the seed files from the templates in dir, falling back to the
+// built-in template for any file which doesn't exist there. Files which
+// render to nothing but whitespace are left out of the seed.
+func Render(dir string, vars Variables) (*Seed, error) {
+	seed := &Seed{
+		Variables: vars,
+		Files:     map[string][]byte{},
+	}
+
+	for _, name := range seedFiles {
+		text, err := loadTemplate(dir, name)
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%v template: %w", name, err)
+		}
+
+		var output bytes.Buffer
+		if err := tmpl.Execute(&output, &vars); err != nil {
+			return nil, fmt.Errorf("%v template: %w", name, err)
+		}
+
+		if len(bytes.TrimSpace(output.Bytes())) > 0 {
+			seed.Files[name] = output.Bytes()
+		}
+	}
+
+	if _, ok := seed.Files["meta-data"]; !ok {
+		return nil, errors.New("meta-data template rendered nothing")
+	}
+	if _, ok := seed.Files["user-data"]; !ok {
+		return nil, errors.New("user-data template rendered nothing")
+	}
+
+	return seed, nil
+}
+
+func loadTemplate(dir string, name string) (string, error) {
+	if dir != "" {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return string(data), nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return builtinTemplates[name], nil
+}
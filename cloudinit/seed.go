@@ -0,0 +1,93 @@
+package cloudinit
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// cloud-init only recognizes NoCloud media with this volume label
+	seedLabel = "cidata"
+)
+
+var (
+	hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]{0,251}[a-zA-Z0-9])?$`)
+	userPattern     = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+)
+
+// Check that a hostname is valid, which also keeps it safe to write into the
+// YAML seed files as-is
+func ValidateHostname(hostname string) error {
+	if !hostnamePattern.MatchString(hostname) {
+		return fmt.Errorf("invalid hostname '%v'", hostname)
+	}
+	return nil
+}
+
+// Values available to the seed templates. Custom templates may also use
+// anything in Vars, e.g. {{ .Vars.role }}.
+type Variables struct {
+	InstanceID string            // Unique per seed; cloud-init reruns first boot setup when it changes
+	Name       string            // Domain name
+	Hostname   string            // Guest hostname (defaults to the domain name)
+	MAC        string            // MAC address of the first network interface
+	User       string            // User created with sudo rights and the SSH keys (cloud image default user if empty)
+	SSHKeys    []string          // Authorized SSH public keys
+	Address    string            // Static address in CIDR notation (DHCP if empty)
+	Gateway    string            // Default gateway of the static address
+	DNS        []string          // Name servers of the static address
+	Vars       map[string]string // Extra values for custom templates
+}
+
+// Check the variables, so they can't break out of the YAML they are
+// rendered into
+func (vars *Variables) Validate() error {
+	if err := ValidateHostname(vars.Hostname); err != nil {
+		return err
+	}
+
+	if vars.User != "" && !userPattern.MatchString(vars.User) {
+		return fmt.Errorf("invalid user name '%v'", vars.User)
+	}
+
+	for _, key := range vars.SSHKeys {
+		if strings.ContainsAny(key, "\r\n") {
+			return fmt.Errorf("SSH keys must be a single line")
+		}
+	}
+
+	if vars.Address == "" {
+		if vars.Gateway != "" {
+			return fmt.Errorf("a gateway requires a static address")
+		}
+		return nil
+	}
+
+	if _, _, err := net.ParseCIDR(vars.Address); err != nil {
+		return fmt.Errorf("invalid address '%v': expected CIDR notation (e.g. 10.0.0.5/24)", vars.Address)
+	}
+	if vars.Gateway != "" && net.ParseIP(vars.Gateway) == nil {
+		return fmt.Errorf("invalid gateway '%v'", vars.Gateway)
+	}
+	for _, server := range vars.DNS {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid name server '%v'", server)
+		}
+	}
+
+	return nil
+}
+
+// A rendered NoCloud seed
+type Seed struct {
+	Variables Variables         // Values the seed was rendered with
+	Files     map[string][]byte // Seed files by name (user-data, meta-data and optionally network-config)
+}
+
+// Build the seed ISO image
+func (seed *Seed) Image() []byte {
+	return buildISO(seedLabel, seed.Files, time.Now())
+}
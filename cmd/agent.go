@@ -0,0 +1,207 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec VM COMMAND [ARG...]",
+	Short: "Run a command in a VM through the guest agent",
+	Long: `Run a program inside a running domain using the QEMU guest agent, and
+print its output once it exits. No network access or SSH setup is needed
+in the guest, only a running qemu-guest-agent. The exit code of the guest
+program is used as the exit code of vroomm.
+
+Flags for the guest program may follow the VM name directly, since flag
+parsing stops at the first argument.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+
+		domain, err := conn.LookupDomain(args[0])
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to find domain")
+		}
+
+		var stdin []byte
+		if useStdin, _ := cmd.Flags().GetBool("stdin"); useStdin {
+			if stdin, err = io.ReadAll(os.Stdin); err != nil {
+				logrus.WithError(err).Fatal("failed to read stdin")
+			}
+		}
+
+		ctx := context.Background()
+		if timeout, _ := cmd.Flags().GetDuration("timeout"); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		result, err := domain.GuestExec(ctx, args[1], args[2:], stdin)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to run guest command")
+		}
+
+		os.Stdout.Write(result.Stdout)
+		os.Stderr.Write(result.Stderr)
+		if result.Truncated {
+			logrus.Warn("guest command output was truncated by the agent")
+		}
+
+		if result.Signal != 0 {
+			os.Exit(128 + result.Signal)
+		}
+		os.Exit(result.ExitCode)
+	},
+}
+
+var cpCmd = &cobra.Command{
+	Use:   "cp SRC DST",
+	Short: "Copy files into or out of a VM through the guest agent",
+	Long: `Copy a single file between the host and a running domain using the QEMU
+guest agent. Exactly one of the paths must refer to the guest using the
+form VM:PATH. A local path of "-" reads from stdin or writes to stdout.
+
+Examples:
+  vroomm cp ./setup.sh testvm:/root/setup.sh
+  vroomm cp testvm:/var/log/syslog ./syslog`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		srcDomain, srcPath, srcIsGuest := strings.Cut(args[0], ":")
+		dstDomain, dstPath, dstIsGuest := strings.Cut(args[1], ":")
+		if srcIsGuest == dstIsGuest {
+			logrus.Fatal("exactly one of SRC and DST must be a guest path (VM:PATH)")
+		}
+
+		_, conn := mustConnect()
+
+		if srcIsGuest {
+			domain := mustLookupDomain(conn, srcDomain)
+
+			writer := os.Stdout
+			if args[1] != "-" {
+				file, err := os.Create(args[1])
+				if err != nil {
+					logrus.WithError(err).Fatal("failed to create local file")
+				}
+				defer file.Close()
+				writer = file
+			}
+
+			if n, err := domain.GuestReadFile(srcPath, writer); err != nil {
+				logrus.WithError(err).Fatal("failed to copy file from guest")
+			} else {
+				logrus.Infof("copied %v from %v", virt.FormatSize(uint64(n)), args[0])
+			}
+		} else {
+			domain := mustLookupDomain(conn, dstDomain)
+
+			reader := os.Stdin
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					logrus.WithError(err).Fatal("failed to open local file")
+				}
+				defer file.Close()
+				reader = file
+			}
+
+			if n, err := domain.GuestWriteFile(dstPath, reader); err != nil {
+				logrus.WithError(err).Fatal("failed to copy file to guest")
+			} else {
+				logrus.Infof("copied %v to %v", virt.FormatSize(uint64(n)), args[1])
+			}
+		}
+	},
+}
+
+var passwdCmd = &cobra.Command{
+	Use:   "passwd VM USER",
+	Short: "Set the password of a guest user through the guest agent",
+	Long: `Set the password of a user account inside a running domain using the QEMU
+guest agent. The password is read from the terminal without echo, or from
+the first line of stdin when it is not a terminal.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+		domain := mustLookupDomain(conn, args[0])
+
+		password, err := readPassword(fmt.Sprintf("New password for %v on %v: ", args[1], args[0]))
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to read password")
+		}
+
+		if err := domain.SetGuestPassword(args[1], password); err != nil {
+			logrus.WithError(err).Fatal("failed to set password")
+		}
+	},
+}
+
+func init() {
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().BoolP("stdin", "i", false, "Pass stdin to the guest command")
+	execCmd.Flags().DurationP("timeout", "t", time.Duration(0), "Give up waiting for the guest command after this long")
+
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(cpCmd)
+	rootCmd.AddCommand(passwdCmd)
+}
+
+// Read a line from stdin, disabling echo if stdin is a terminal
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if original, err := unix.IoctlGetTermios(fd, unix.TCGETS); err == nil {
+		fmt.Fprint(os.Stderr, prompt)
+
+		noEcho := *original
+		noEcho.Lflag &^= unix.ECHO
+		if err := unix.IoctlSetTermios(fd, unix.TCSETS, &noEcho); err != nil {
+			return "", err
+		}
+		defer func() {
+			unix.IoctlSetTermios(fd, unix.TCSETS, original)
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
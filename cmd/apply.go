@@ -0,0 +1,143 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/manifest"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply MANIFEST",
+	Short: "Create or update the VMs described by a manifest",
+	Long: `Reconcile libvirt against a TOML manifest describing a set of VMs:
+
+    name = "web-lab"
+
+    [[vm]]
+    name      = "web-db"
+    source    = "debian-12-base"   # domain to clone
+    clone     = "linked"           # or "full"
+    folder    = "/labs/web/"
+    labels    = ["web-lab"]
+    memory    = "4GiB"             # optional
+    vcpus     = 2                  # optional
+    networks  = ["default", "bridge:br0"]
+    snapshots = ["clean"]          # taken once the VM exists
+
+Missing VMs are cloned, and existing ones are moved, relabeled, resized,
+rewired and snapshotted to match. VMs whose source or clone mode changed are
+deleted and cloned again. VMs are only ever touched if they were created by
+a manifest with the same name. The plan is printed before anything is
+changed; pass --dry-run to only print it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		prune, _ := cmd.Flags().GetBool("prune")
+
+		lab, reconciler := mustLoadManifest(args[0])
+
+		plan, err := reconciler.Plan(lab, prune)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to plan changes")
+		}
+
+		runPlan(reconciler, plan, dryRun)
+	},
+}
+
+var destroyCmd = &cobra.Command{
+	Use:   "destroy MANIFEST",
+	Short: "Delete every VM (and its disks) created by a manifest",
+	Long: `Delete every VM created by the manifest, along with the disks only they
+use. VMs which were removed from the manifest since they were created are
+deleted too. Pass --dry-run to only print what would be deleted.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		lab, reconciler := mustLoadManifest(args[0])
+
+		plan, err := reconciler.DestroyPlan(lab)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to plan changes")
+		}
+
+		runPlan(reconciler, plan, dryRun)
+	},
+}
+
+func mustLoadManifest(path string) (*manifest.Manifest, *manifest.Reconciler) {
+	lab, err := manifest.Load(path)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to load manifest")
+	}
+
+	cfg, conn := mustConnect()
+	return lab, manifest.New(conn, hooks.New(cfg))
+}
+
+// Print the plan and, unless this is a dry run, apply it
+func runPlan(reconciler *manifest.Reconciler, plan *manifest.Plan, dryRun bool) {
+	symbols := map[manifest.Action]string{
+		manifest.ActionCreate:    "+",
+		manifest.ActionReplace:   "-/+",
+		manifest.ActionUpdate:    "~",
+		manifest.ActionDelete:    "-",
+		manifest.ActionOrphaned:  "!",
+		manifest.ActionUnchanged: "=",
+	}
+
+	for _, change := range plan.Changes {
+		fmt.Printf("%3v %v (%v)\n", symbols[change.Action], change.Name, change.Action)
+		for _, detail := range change.Details {
+			fmt.Printf("      %v\n", detail)
+		}
+	}
+
+	pending := plan.Pending()
+	fmt.Printf("\nManifest '%v': %v changes\n", plan.Manifest.Name, pending)
+	if dryRun || pending == 0 {
+		return
+	}
+
+	if err := reconciler.Apply(plan, func(change *manifest.Change) {
+		logrus.WithField("domain", change.Name).Infof("%v%v", strings.ToUpper(string(change.Action[:1])), change.Action[1:])
+	}); err != nil {
+		logrus.WithError(err).Fatal("failed to apply manifest")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(destroyCmd)
+
+	applyCmd.Flags().BoolP("dry-run", "n", false, "Only print the planned changes")
+	applyCmd.Flags().Bool("prune", false, "Delete VMs created by the manifest which are no longer in it")
+	destroyCmd.Flags().BoolP("dry-run", "n", false, "Only print the VMs which would be deleted")
+}
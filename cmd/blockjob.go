@@ -0,0 +1,107 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var detachParentCmd = &cobra.Command{
+	Use:   "detach-parent VM [DISK...]",
+	Short: "Make a linked clone independent of its parent image",
+	Long: `Flatten the backing chain of each given disk (or every disk with a
+backing image if none are given) so the domain no longer depends on the
+image it was cloned from.
+
+Running domains are flattened live with a block pull. Stopped domains
+have each disk copied into a new standalone volume, and the old overlay
+is removed. Internal qcow2 snapshots do not survive an offline copy.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+
+		domain, err := conn.LookupDomain(args[0])
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to find domain")
+		}
+
+		targets := args[1:]
+		if len(targets) == 0 {
+			if targets, err = domain.BackedDisks(conn); err != nil {
+				logrus.WithError(err).Fatal("failed to inspect domain disks")
+			} else if len(targets) == 0 {
+				logrus.Fatal("domain has no disks with a backing image")
+			}
+		}
+
+		for _, target := range targets {
+			if err := domain.Flatten(conn, target, printBlockJobProgress(target)); err != nil {
+				fmt.Fprintln(os.Stderr)
+				logrus.WithError(err).WithField("disk", target).Fatal("failed to flatten disk")
+			}
+			fmt.Fprintf(os.Stderr, "\r%v: done\n", target)
+		}
+	},
+}
+
+var commitCmd = &cobra.Command{
+	Use:   "commit VM DISK",
+	Short: "Merge the active overlay of a running VM's disk into its backing image",
+	Long: `Commit the active overlay of a disk into its immediate backing image and
+pivot the domain onto the backing image. The domain must be running, and
+the backing image must not be shared with any other volume. The old
+overlay volume is removed afterwards.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+
+		domain, err := conn.LookupDomain(args[0])
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to find domain")
+		}
+
+		if err := domain.Commit(conn, args[1], printBlockJobProgress(args[1])); err != nil {
+			fmt.Fprintln(os.Stderr)
+			logrus.WithError(err).WithField("disk", args[1]).Fatal("failed to commit disk")
+		}
+		fmt.Fprintf(os.Stderr, "\r%v: done\n", args[1])
+	},
+}
+
+func printBlockJobProgress(target string) virt.BlockJobProgress {
+	return func(current uint64, end uint64) {
+		if end > 0 {
+			fmt.Fprintf(os.Stderr, "\r%v: %3d%%", target, current*100/end)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(detachParentCmd)
+	rootCmd.AddCommand(commitCmd)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+)
+
+var consoleCmd = &cobra.Command{
+	Use:   "console VM",
+	Short: "Connect to the serial console of a running VM",
+	Long: `Attach the terminal to a serial console of a running domain through a
+libvirt stream. The terminal is placed in raw mode, so every key is sent to
+the guest except the escape character (Ctrl+] by default), which ends the
+session.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+
+		domain, err := conn.LookupDomain(args[0])
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to find domain")
+		}
+
+		escapeText, _ := cmd.Flags().GetString("escape")
+		escape, err := parseEscapeChar(escapeText)
+		if err != nil {
+			logrus.WithError(err).Fatal("invalid escape character")
+		}
+
+		device, _ := cmd.Flags().GetString("device")
+		force, _ := cmd.Flags().GetBool("force")
+
+		console, err := domain.OpenConsole(conn, device, force)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to open console")
+		}
+		defer console.Close()
+
+		fmt.Fprintf(os.Stderr, "Connected to %v (escape character is %v)\r\n", args[0], escapeText)
+
+		restore, err := makeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to put terminal in raw mode")
+		}
+		defer restore()
+
+		done := make(chan error, 2)
+
+		go func() {
+			_, err := io.Copy(os.Stdout, console)
+			done <- err
+		}()
+
+		go func() {
+			buffer := make([]byte, 1024)
+			for {
+				n, err := os.Stdin.Read(buffer)
+				if err != nil {
+					done <- err
+					return
+				}
+
+				data := buffer[:n]
+				if idx := bytes.IndexByte(data, escape); idx >= 0 {
+					console.Write(data[:idx])
+					done <- nil
+					return
+				} else if _, err := console.Write(data); err != nil {
+					done <- err
+					return
+				}
+			}
+		}()
+
+		err = <-done
+		restore()
+		fmt.Fprintln(os.Stderr)
+
+		if err != nil && err != io.EOF {
+			logrus.WithError(err).Error("console disconnected")
+		}
+	},
+}
+
+func init() {
+	consoleCmd.Flags().StringP("device", "d", "", "Console device alias (default is the first console)")
+	consoleCmd.Flags().BoolP("force", "f", false, "Disconnect any existing console session")
+	consoleCmd.Flags().StringP("escape", "e", "^]", "Escape character which ends the session (e.g. ^] or ^X)")
+	rootCmd.AddCommand(consoleCmd)
+}
+
+// Parse an escape character in caret notation (e.g. "^]") or a single
+// literal character.
+func parseEscapeChar(text string) (byte, error) {
+	if len(text) == 2 && text[0] == '^' {
+		return text[1] & 0x1f, nil
+	} else if len(text) == 1 {
+		return text[0], nil
+	}
+	return 0, fmt.Errorf("expected a single character or ^X, got '%v'", text)
+}
+
+// Put the terminal in raw mode (like cfmakeraw), returning a function which
+// restores the previous state. The restore function is safe to call more
+// than once.
+func makeRaw(fd int) (func(), error) {
+	original, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *original
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		unix.IoctlSetTermios(fd, unix.TCSETS, original)
+	}, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/folders"
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var folderCmd = &cobra.Command{
+	Use:   "folder",
+	Short: "Create, rename, move and delete VM folders",
+}
+
+var folderListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all folders, including empty ones",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		conn, manager := mustFolderManager()
+
+		all, err := manager.List(conn)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to list folders")
+		}
+
+		for _, folder := range all {
+			fmt.Println(folder)
+		}
+	},
+}
+
+var folderCreateCmd = &cobra.Command{
+	Use:   "create FOLDER",
+	Short: "Create an empty folder",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		conn, manager := mustFolderManager()
+		if err := manager.Create(conn, args[0]); err != nil {
+			logrus.WithError(err).Fatal("failed to create folder")
+		}
+	},
+}
+
+var folderRenameCmd = &cobra.Command{
+	Use:   "rename FOLDER NEW_PATH",
+	Short: "Rename a folder, moving all VMs and subfolders with it",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		conn, manager := mustFolderManager()
+		if err := manager.Rename(conn, args[0], args[1]); err != nil {
+			logrus.WithError(err).Fatal("failed to rename folder")
+		}
+	},
+}
+
+var folderMoveCmd = &cobra.Command{
+	Use:   "move FOLDER PARENT",
+	Short: "Move a folder (with its VMs and subfolders) into another folder",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		conn, manager := mustFolderManager()
+		if err := manager.Move(conn, args[0], args[1]); err != nil {
+			logrus.WithError(err).Fatal("failed to move folder")
+		}
+	},
+}
+
+var folderDeleteCmd = &cobra.Command{
+	Use:   "delete FOLDER",
+	Short: "Delete an empty folder (or with --force, move its contents to the parent folder)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+
+		conn, manager := mustFolderManager()
+		if err := manager.Delete(conn, args[0], force); err != nil {
+			logrus.WithError(err).Fatal("failed to delete folder")
+		}
+	},
+}
+
+func mustFolderManager() (*virt.Connection, *folders.Manager) {
+	cfg, conn := mustConnect()
+	return conn, folders.New(cfg, hooks.New(cfg))
+}
+
+func init() {
+	rootCmd.AddCommand(folderCmd)
+
+	folderCmd.AddCommand(folderListCmd)
+	folderCmd.AddCommand(folderCreateCmd)
+	folderCmd.AddCommand(folderRenameCmd)
+	folderCmd.AddCommand(folderMoveCmd)
+	folderCmd.AddCommand(folderDeleteCmd)
+
+	folderDeleteCmd.Flags().BoolP("force", "f", false, "Delete non-empty folders, moving their contents to the parent folder")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Find and remove unused storage volumes",
+	Long: `Scan all active storage pools and report volumes which are no longer
+needed. A volume is reported if no domain, snapshot or other volume
+references it (orphaned), or if it is a linked clone whose backing file
+no longer exists (missing-backing).
+
+ISO images are never reported as orphaned. Linked clones with a missing
+backing file which are still attached to a domain, or which other overlays
+are stacked on, are reported but never removed; fix or remove those first.
+
+By default, this command only prints a report. Pass --clean to delete
+all removable volumes, optionally with --dry-run to see what would be
+removed without touching anything.`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		clean, _ := cmd.Flags().GetBool("clean")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		_, conn := mustConnect()
+
+		report, err := conn.FindGarbage()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to scan storage pools")
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "REASON\tPOOL\tVOLUME\tSIZE\tUSED BY")
+		for _, volume := range report.Volumes {
+			usedBy := strings.Join(volume.UsedBy(), ",")
+			if usedBy == "" {
+				usedBy = "-"
+			}
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\n", volume.Reason, volume.Pool, volume.Path, virt.FormatSize(volume.Allocation), usedBy)
+		}
+		writer.Flush()
+
+		fmt.Printf("\n%v volumes found, %v reclaimable\n", len(report.Volumes), virt.FormatSize(report.Reclaimable()))
+
+		if !clean {
+			return
+		}
+
+		removed, err := conn.CleanupGarbage(report, dryRun)
+		for _, volume := range removed {
+			if dryRun {
+				fmt.Printf("would remove %v\n", volume.Path)
+			} else {
+				fmt.Printf("removed %v\n", volume.Path)
+			}
+		}
+
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to remove some volumes")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().Bool("clean", false, "Delete all removable volumes")
+	gcCmd.Flags().BoolP("dry-run", "n", false, "With --clean, only print the volumes which would be deleted")
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var hostCmd = &cobra.Command{
+	Use:   "host",
+	Short: "Show host hardware, versions, capabilities and committed resources",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		interval, _ := cmd.Flags().GetDuration("cpu-interval")
+
+		_, conn := mustConnect()
+
+		info, err := conn.HostInfo()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to inspect host")
+		}
+
+		// CPU usage needs two samples to compute
+		usage := "-"
+		if interval > 0 {
+			if before, err := conn.SampleHostCPU(); err != nil {
+				logrus.WithError(err).Warn("failed to sample host cpu usage")
+			} else {
+				time.Sleep(interval)
+				if after, err := conn.SampleHostCPU(); err != nil {
+					logrus.WithError(err).Warn("failed to sample host cpu usage")
+				} else {
+					usage = fmt.Sprintf("%.1f%%", after.Usage(&before))
+				}
+			}
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(writer, "Hostname:\t%v\n", info.Hostname)
+		fmt.Fprintf(writer, "CPU:\t%v %v, %v CPUs @ %v MHz\n", info.Arch, info.Model, info.CPUs, info.MHz)
+		fmt.Fprintf(writer, "Topology:\t%v nodes, %v sockets, %v cores, %v threads\n", info.Nodes, info.Sockets, info.Cores, info.Threads)
+		fmt.Fprintf(writer, "CPU Usage:\t%v\n", usage)
+		fmt.Fprintf(writer, "Memory:\t%v free of %v\n", virt.FormatSize(info.MemoryFree), virt.FormatSize(info.MemoryTotal))
+		fmt.Fprintf(writer, "Hypervisor:\t%v %v (%v)\n", info.Hypervisor, info.HypervisorVersion, info.VirtType)
+		fmt.Fprintf(writer, "Libvirt:\t%v\n", info.LibvirtVersion)
+		fmt.Fprintf(writer, "Running VMs:\t%v of %v\n", info.RunningDomains, info.AllDomains)
+		fmt.Fprintf(writer, "vCPUs Committed:\t%v running, %v total (%.0f%% of host CPUs)\n", info.RunningVCPUs, info.AllVCPUs, percentOf(float64(info.AllVCPUs), float64(info.CPUs)))
+		fmt.Fprintf(writer, "Memory Committed:\t%v running, %v total (%.0f%% of host memory)\n", virt.FormatSize(info.RunningMemory), virt.FormatSize(info.AllMemory), percentOf(float64(info.AllMemory), float64(info.MemoryTotal)))
+		fmt.Fprintf(writer, "Firmware:\t%v\n", strings.Join(info.Firmware, ", "))
+		for _, loader := range info.Loaders {
+			fmt.Fprintf(writer, "Loader:\t%v\n", loader)
+		}
+		fmt.Fprintf(writer, "Machine Types:\t%v\n", strings.Join(info.MachineTypes, ", "))
+		writer.Flush()
+	},
+}
+
+func percentOf(value float64, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return value / total * 100
+}
+
+func init() {
+	rootCmd.AddCommand(hostCmd)
+
+	hostCmd.Flags().Duration("cpu-interval", time.Second, "Time to sample host CPU usage over (0 to skip)")
+}
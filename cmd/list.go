@@ -0,0 +1,84 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/query"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List VMs with their state, folder and labels",
+	Long: `List all VMs, or only those matching a query. A query is made of space
+separated terms which must all match, such as:
+
+    label:ci state:running path:/work/** name:web*
+
+Fields are name, uuid, label, state (running, paused, suspended, off,
+crashed), path and os (windows, linux, default). Bare words match the
+name, "*" and "?" are wildcards, "**" also matches subfolders, "a,b"
+matches either value and a leading "-" negates a term.`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		queryText, _ := cmd.Flags().GetString("query")
+
+		q, err := query.Parse(queryText)
+		if err != nil {
+			logrus.WithError(err).Fatal("invalid query")
+		}
+
+		_, conn := mustConnect()
+
+		domains, err := conn.EnumerateAllDomains()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to list domains")
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tSTATE\tPATH\tLABELS")
+		for _, domain := range domains {
+			subject, err := query.NewSubject(domain)
+			if err != nil {
+				logrus.WithError(err).Fatal("failed to inspect domain")
+			} else if !q.Match(subject) {
+				continue
+			}
+
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", subject.Name, subject.State, subject.Path, strings.Join(subject.Labels, ","))
+		}
+		writer.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringP("query", "q", "", "Only list VMs matching the query")
+}
@@ -0,0 +1,191 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"libvirt.org/go/libvirt"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var netCmd = &cobra.Command{
+	Use:   "net",
+	Short: "Inspect and manage libvirt networks",
+}
+
+var netListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List networks with their state, bridge and address ranges",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+
+		networks, err := conn.EnumerateNetworks()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to list networks")
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tSTATE\tAUTOSTART\tFORWARD\tBRIDGE\tSUBNETS\tLEASES")
+		for _, network := range networks {
+			info, err := network.Info(nil)
+			if err != nil {
+				logrus.WithError(err).Fatal("failed to inspect network")
+			}
+
+			state := "inactive"
+			if info.Active {
+				state = "active"
+			}
+
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", info.Name, state, info.Autostart, info.Forward, info.Bridge, strings.Join(info.Ranges, ", "), len(info.Leases))
+		}
+		writer.Flush()
+	},
+}
+
+var netLeasesCmd = &cobra.Command{
+	Use:   "leases NETWORK",
+	Short: "Show DHCP leases of a network and the domains holding them",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+
+		network := mustLookupNetwork(conn, args[0])
+
+		owners, err := conn.DomainsByMAC()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to map interfaces to domains")
+		}
+
+		info, err := network.Info(owners)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to inspect network")
+		} else if !info.Active {
+			logrus.Warnf("network '%v' is not active", info.Name)
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "ADDRESS\tMAC\tDOMAIN\tHOSTNAME\tEXPIRES")
+		for _, lease := range info.Leases {
+			domain := lease.Domain
+			if domain == "" {
+				domain = "-"
+			}
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\n", lease.Address, lease.MAC, domain, lease.Hostname, lease.Expiry.Format("2006-01-02 15:04:05"))
+		}
+		writer.Flush()
+	},
+}
+
+var netStartCmd = &cobra.Command{
+	Use:   "start NETWORK",
+	Short: "Start a network",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+		if err := mustLookupNetwork(conn, args[0]).Create(); err != nil {
+			logrus.WithError(err).Fatal("failed to start network")
+		}
+	},
+}
+
+var netStopCmd = &cobra.Command{
+	Use:   "stop NETWORK",
+	Short: "Stop a network",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+		if err := mustLookupNetwork(conn, args[0]).Destroy(); err != nil {
+			logrus.WithError(err).Fatal("failed to stop network")
+		}
+	},
+}
+
+var netAutostartCmd = &cobra.Command{
+	Use:   "autostart NETWORK",
+	Short: "Enable (or with --disable, disable) autostart for a network",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		disable, _ := cmd.Flags().GetBool("disable")
+
+		_, conn := mustConnect()
+		if err := mustLookupNetwork(conn, args[0]).SetAutostart(!disable); err != nil {
+			logrus.WithError(err).Fatal("failed to change network autostart")
+		}
+	},
+}
+
+var netEditCmd = &cobra.Command{
+	Use:   "edit NETWORK",
+	Short: "Edit the XML definition of a network with $EDITOR",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+		network := mustLookupNetwork(conn, args[0])
+
+		netXml, err := network.GetXMLDesc(libvirt.NETWORK_XML_INACTIVE)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to read network definition")
+		}
+
+		newNetXml, err := editInTerminal("vroomm-network.*.xml", netXml)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to edit network definition")
+		} else if strings.TrimSpace(newNetXml) == strings.TrimSpace(netXml) {
+			fmt.Println("Network XML unchanged")
+			return
+		} else if _, err := conn.NetworkDefineXML(newNetXml); err != nil {
+			logrus.WithError(err).Fatal("failed to define network")
+		}
+
+		fmt.Println("Network definition updated (restart the network to apply)")
+	},
+}
+
+func mustLookupNetwork(conn *virt.Connection, name string) *virt.Network {
+	network, err := conn.LookupNetwork(name)
+	if err != nil {
+		logrus.WithError(err).WithField("network", name).Fatal("failed to find network")
+	}
+	return network
+}
+
+func init() {
+	rootCmd.AddCommand(netCmd)
+
+	netCmd.AddCommand(netListCmd)
+	netCmd.AddCommand(netLeasesCmd)
+	netCmd.AddCommand(netStartCmd)
+	netCmd.AddCommand(netStopCmd)
+	netCmd.AddCommand(netAutostartCmd)
+	netCmd.AddCommand(netEditCmd)
+
+	netAutostartCmd.Flags().Bool("disable", false, "Disable autostart instead of enabling it")
+}
@@ -51,7 +51,9 @@ relatively intuitive. Typing will always focus the text input with the exception
 of the up/down arrows which select items in the appropriate menu, the enter key
 which accepts the current menu or item selection, and escape. Escape alone will
 navigate backwards through the menu tree. Pressing Shift+Escape will exit the
-application immediately.
+application immediately. In VM lists, Space (or Ctrl+Space while typing) marks
+the selected VM and Ctrl+A marks all visible VMs; Enter then opens actions
+which apply to every marked VM.
 
 This application was built for my own use, and is not heavily tested. If you
 don't know what you're doing with low-level libvirt interaction, it is not
@@ -0,0 +1,155 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/disposable"
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run SOURCE [NAME]",
+	Short: "Start a linked clone of a VM or template",
+	Long: `Make a linked clone of SOURCE with new MAC addresses and start it.
+
+With --rm the clone is disposable: it is deleted along with its storage as
+soon as it shuts off, and NAME defaults to a generated one. vroomm waits for
+that unless --detach is given or it is interrupted. Disposable VMs which shut
+off while nothing is waiting are deleted by the GUI, or by the next run.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		remove, _ := cmd.Flags().GetBool("rm")
+		detach, _ := cmd.Flags().GetBool("detach")
+
+		cfg, conn := mustConnect()
+		source := mustLookupDomain(conn, args[0])
+		runner := hooks.New(cfg)
+
+		if !remove {
+			if len(args) < 2 {
+				logrus.Fatal("a name is required without --rm")
+			}
+			runClone(conn, runner, source, args[1])
+			logrus.Infof("started '%v'", args[1])
+			return
+		}
+
+		name := disposable.Name(conn, args[0])
+		if len(args) > 1 {
+			name = args[1]
+		}
+
+		done := make(chan error, 1)
+		reaper := disposable.New(func() *virt.Connection { return conn }, runner, func(domain string, err error) {
+			if domain == name {
+				// Only the first outcome is waited for
+				select {
+				case done <- err:
+				default:
+				}
+			} else if err != nil {
+				logrus.WithError(err).Warnf("failed to delete disposable VM '%v'", domain)
+			}
+		})
+
+		if err := reaper.Sweep(); err != nil {
+			logrus.WithError(err).Warn("failed to clean up disposable VMs")
+		}
+
+		domain, err := reaper.Run(source, name)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to start disposable VM")
+		} else if detach {
+			logrus.Infof("started disposable VM '%v'", name)
+			return
+		}
+
+		stop, err := reaper.Watch(domain)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to watch disposable VM")
+		}
+		defer stop()
+
+		// The VM may have shut off before the watch was registered
+		if reaped, err := reaper.Reap(domain); err != nil {
+			logrus.WithError(err).Fatal("failed to delete disposable VM")
+		} else if reaped {
+			logrus.Infof("deleted disposable VM '%v'", name)
+			return
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		logrus.Infof("started disposable VM '%v'; waiting for it to shut off (Ctrl-C to detach)", name)
+		select {
+		case err := <-done:
+			if err != nil {
+				logrus.WithError(err).Fatal("failed to delete disposable VM")
+			}
+			logrus.Infof("deleted disposable VM '%v'", name)
+		case <-ctx.Done():
+			logrus.Infof("detached; '%v' is still deleted once it shuts off", name)
+		}
+	},
+}
+
+// Make a regular linked clone with new MAC addresses and start it
+func runClone(conn *virt.Connection, runner *hooks.Runner, source *virt.Domain, name string) {
+	var domain *virt.Domain
+	details := map[string]string{"clone": name, "linked": "true"}
+	if err := runner.Wrap(hooks.EventClone, source, details, func() (err error) {
+		if domain, err = source.Clone(conn, name, true); err == nil {
+			details["clone_uuid"], _ = domain.GetUUIDString()
+		}
+		return err
+	}); err != nil {
+		logrus.WithError(err).Fatal("failed to clone VM")
+	}
+
+	metadata := domain.GetVmmData()
+	metadata.Template = false
+	if err := domain.ResetMACs(conn); err != nil {
+		logrus.WithError(err).Fatal("failed to reset MAC addresses")
+	} else if err := domain.UpdateVmmData(metadata); err != nil {
+		logrus.WithError(err).Fatal("failed to update VM metadata")
+	}
+
+	if err := runner.Wrap(hooks.EventStart, domain, nil, domain.Create); err != nil {
+		logrus.WithError(err).Fatal("failed to start VM")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().Bool("rm", false, "Delete the VM and its storage once it shuts off")
+	runCmd.Flags().BoolP("detach", "d", false, "With --rm, return once the VM is started instead of waiting")
+}
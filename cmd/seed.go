@@ -0,0 +1,121 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/cloudinit"
+)
+
+// Flags filling in cloud-init seed variables
+var seedFlags = []string{"hostname", "user", "ssh-key", "ip", "gateway", "dns", "var"}
+
+var seedCmd = &cobra.Command{
+	Use:   "seed VM",
+	Short: "Attach a cloud-init NoCloud seed to a VM",
+	Long: `Render user-data, meta-data and network-config from the templates in
+$XDG_CONFIG_HOME/vroomm/cloud-init/ (or the built-in ones), upload them as
+an ISO image and insert it into the VM. An older seed is replaced. The seed
+gets a new instance ID, so cloud-init applies it on the next boot.
+
+The hostname defaults to the VM name, and the guest uses DHCP unless --ip is
+given. SSH keys are authorized in addition to the configured ones.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		cfg, conn := mustConnect()
+		domain := mustLookupDomain(conn, args[0])
+		seeder := cloudinit.New(cfg, conn)
+		vars := seedVariables(cmd)
+
+		if dryRun {
+			seed, err := seeder.Prepare(domain, *vars)
+			if err != nil {
+				logrus.WithError(err).Fatal("failed to render seed")
+			}
+
+			names := []string{}
+			for name := range seed.Files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				fmt.Printf("--- %v\n", name)
+				os.Stdout.Write(seed.Files[name])
+			}
+			return
+		}
+
+		if _, err := seeder.Attach(domain, *vars); err != nil {
+			logrus.WithError(err).Fatal("failed to attach seed")
+		}
+	},
+}
+
+// Add the flags filling in seed variables
+func addSeedFlags(cmd *cobra.Command) {
+	cmd.Flags().String("hostname", "", "Guest hostname (defaults to the VM name)")
+	cmd.Flags().String("user", "", "User created with sudo rights and the SSH keys")
+	cmd.Flags().StringSlice("ssh-key", nil, "Authorized SSH public key (repeatable)")
+	cmd.Flags().String("ip", "", "Static address in CIDR notation (DHCP if empty)")
+	cmd.Flags().String("gateway", "", "Default gateway of the static address")
+	cmd.Flags().StringSlice("dns", nil, "Name server of the static address (repeatable)")
+	cmd.Flags().StringToString("var", nil, "Extra template value as name=value (repeatable)")
+}
+
+// Check whether any seed flag was given
+func seedFlagsChanged(cmd *cobra.Command) bool {
+	for _, name := range seedFlags {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Build the seed variables from the flags added by addSeedFlags
+func seedVariables(cmd *cobra.Command) *cloudinit.Variables {
+	vars := &cloudinit.Variables{}
+	vars.Hostname, _ = cmd.Flags().GetString("hostname")
+	vars.User, _ = cmd.Flags().GetString("user")
+	vars.SSHKeys, _ = cmd.Flags().GetStringSlice("ssh-key")
+	vars.Address, _ = cmd.Flags().GetString("ip")
+	vars.Gateway, _ = cmd.Flags().GetString("gateway")
+	vars.DNS, _ = cmd.Flags().GetStringSlice("dns")
+	vars.Vars, _ = cmd.Flags().GetStringToString("var")
+	return vars
+}
+
+func init() {
+	rootCmd.AddCommand(seedCmd)
+
+	addSeedFlags(seedCmd)
+	seedCmd.Flags().BoolP("dry-run", "n", false, "Print the rendered seed files instead of attaching them")
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/templates"
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage golden image templates and create VMs from them",
+	Long: `Templates are VMs which are cloned rather than started. vroomm refuses to
+start a template, and hides the actions which would change it until it is
+unmarked.`,
+}
+
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all templates",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+
+		domains, err := templates.List(conn)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to list templates")
+		}
+
+		for _, domain := range domains {
+			name, _ := domain.GetName()
+			fmt.Println(name)
+		}
+	},
+}
+
+var templateMarkCmd = &cobra.Command{
+	Use:   "mark VM",
+	Short: "Mark a shut off VM as a template",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+		if err := mustLookupDomain(conn, args[0]).SetTemplate(true); err != nil {
+			logrus.WithError(err).Fatal("failed to mark template")
+		}
+	},
+}
+
+var templateUnmarkCmd = &cobra.Command{
+	Use:   "unmark VM",
+	Short: "Turn a template back into a regular VM",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+		if err := mustLookupDomain(conn, args[0]).SetTemplate(false); err != nil {
+			logrus.WithError(err).Fatal("failed to unmark template")
+		}
+	},
+}
+
+var templateNewCmd = &cobra.Command{
+	Use:   "new TEMPLATE NAME",
+	Short: "Create a VM from a template",
+	Long: `Clone a template into a new VM with fresh MAC addresses, its own folder
+and labels and, with --cloud-init or any of the seed flags, a cloud-init
+NoCloud seed (see 'vroomm seed --help').`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		full, _ := cmd.Flags().GetBool("full")
+		folder, _ := cmd.Flags().GetString("folder")
+		labels, _ := cmd.Flags().GetStringSlice("label")
+		withSeed, _ := cmd.Flags().GetBool("cloud-init")
+		start, _ := cmd.Flags().GetBool("start")
+
+		instance := templates.Instance{
+			Name:   args[1],
+			Linked: !full,
+			Folder: folder,
+			Labels: labels,
+			Start:  start,
+		}
+		if withSeed || seedFlagsChanged(cmd) {
+			instance.Seed = seedVariables(cmd)
+		}
+
+		cfg, conn := mustConnect()
+		template := mustLookupDomain(conn, args[0])
+
+		builder := templates.New(cfg, conn, hooks.New(cfg))
+		if _, err := builder.Create(template, instance); err != nil {
+			logrus.WithError(err).Fatal("failed to create VM from template")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+
+	templateCmd.AddCommand(templateListCmd)
+	templateCmd.AddCommand(templateMarkCmd)
+	templateCmd.AddCommand(templateUnmarkCmd)
+	templateCmd.AddCommand(templateNewCmd)
+
+	templateNewCmd.Flags().Bool("full", false, "Make a full copy instead of a linked clone")
+	templateNewCmd.Flags().StringP("folder", "f", "/", "Folder of the new VM")
+	templateNewCmd.Flags().StringSliceP("label", "l", nil, "Label of the new VM (repeatable)")
+	templateNewCmd.Flags().Bool("cloud-init", false, "Attach a cloud-init seed, even without seed flags")
+	addSeedFlags(templateNewCmd)
+	templateNewCmd.Flags().Bool("start", false, "Start the VM once it is created")
+}
@@ -0,0 +1,302 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/calebstewart/vroomm/folders"
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/query"
+	"github.com/calebstewart/vroomm/set"
+	"github.com/calebstewart/vroomm/startup"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var upCmd = &cobra.Command{
+	Use:   "up [VM...]",
+	Short: "Start a group of VMs (and their dependencies) in dependency order",
+	Long: `Start the named VMs and/or every VM selected by --folder, --label and
+--query, along with any VMs they depend on. VMs start in dependency order;
+each VM must become ready (see "vroomm depends") before the VMs depending
+on it are started, and startup stops at the first failure.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runGroup(cmd, args, true)
+	},
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down [VM...]",
+	Short: "Shut down a group of VMs in reverse dependency order",
+	Long: `Shut down the named VMs and/or every VM selected by --folder, --label
+and --query in reverse dependency order. VMs which have not shut down
+within bulk.shutdown_timeout are forced off. Dependencies outside of the
+group are left running.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runGroup(cmd, args, false)
+	},
+}
+
+var dependsCmd = &cobra.Command{
+	Use:   "depends VM [DEPENDENCY...]",
+	Short: "Show or set the startup dependencies of a VM",
+	Long: `Show the startup dependencies of a VM, or set them. The VM is started
+after all of its dependencies are ready. --wait sets what "ready" means for
+this VM (agent: the guest agent answers a ping, ip: an address is known),
+and --delay adds a pause after it is ready before its dependents start.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, conn := mustConnect()
+		domain := mustLookupDomain(conn, args[0])
+
+		name, err := domain.GetName()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to inspect domain")
+		}
+
+		metadata := domain.GetVmmData()
+		if metadata.Startup == nil {
+			metadata.Startup = &virt.StartupMetadata{}
+		}
+
+		changed := false
+		if clear, _ := cmd.Flags().GetBool("clear"); clear {
+			metadata.Startup = &virt.StartupMetadata{}
+			changed = true
+		}
+
+		if len(args) > 1 {
+			metadata.Startup.DependsOn = []string{}
+			for _, dependencyName := range args[1:] {
+				dependency := mustLookupDomain(conn, dependencyName)
+				if dependencyName, err = dependency.GetName(); err != nil {
+					logrus.WithError(err).Fatal("failed to inspect domain")
+				} else if dependencyName == name {
+					logrus.WithField("domain", name).Fatal("a VM cannot depend on itself")
+				} else if cycle, err := startup.DependsOn(conn, dependencyName, name); err != nil {
+					logrus.WithError(err).Fatal("failed to check dependencies")
+				} else if cycle {
+					logrus.WithField("domain", name).Fatalf("'%v' already depends on '%v'", dependencyName, name)
+				}
+				metadata.Startup.DependsOn = append(metadata.Startup.DependsOn, dependencyName)
+			}
+			changed = true
+		}
+
+		if cmd.Flags().Changed("wait") {
+			wait, _ := cmd.Flags().GetString("wait")
+			if wait == "none" {
+				wait = virt.WaitNone
+			}
+			if err := virt.ValidateWait(wait); err != nil {
+				logrus.WithError(err).Fatal("invalid wait condition")
+			}
+			metadata.Startup.Wait = wait
+			changed = true
+		}
+
+		if cmd.Flags().Changed("delay") {
+			delay, _ := cmd.Flags().GetDuration("delay")
+			metadata.Startup.Delay = uint(delay.Seconds())
+			changed = true
+		}
+
+		if cmd.Flags().Changed("timeout") {
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			metadata.Startup.Timeout = uint(timeout.Seconds())
+			changed = true
+		}
+
+		if changed {
+			if len(metadata.Startup.DependsOn) == 0 && metadata.Startup.Wait == virt.WaitNone && metadata.Startup.Delay == 0 && metadata.Startup.Timeout == 0 {
+				metadata.Startup = nil
+			}
+			if err := domain.UpdateVmmData(metadata); err != nil {
+				logrus.WithError(err).Fatal("failed to update domain metadata")
+			}
+			if metadata.Startup == nil {
+				return
+			}
+		} else if len(metadata.Startup.DependsOn) == 0 && metadata.Startup.Wait == virt.WaitNone {
+			fmt.Printf("%v has no startup dependencies\n", name)
+			return
+		}
+
+		wait := metadata.Startup.Wait
+		if wait == virt.WaitNone {
+			wait = "none"
+		}
+		fmt.Printf("Depends On: %v\n", strings.Join(metadata.Startup.DependsOn, ", "))
+		fmt.Printf("Wait:       %v (timeout %v)\n", wait, metadata.Startup.ReadyTimeout())
+		fmt.Printf("Delay:      %v\n", time.Duration(metadata.Startup.Delay)*time.Second)
+	},
+}
+
+// Start or shut down the selected VMs, logging progress for each one
+func runGroup(cmd *cobra.Command, args []string, up bool) {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	cfg, conn := mustConnect()
+
+	domains, err := selectGroup(cmd, conn, args)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to select VMs")
+	} else if len(domains) == 0 {
+		logrus.Fatal("no VMs selected")
+	}
+
+	if dryRun {
+		levels, err := startup.Plan(conn, domains, up)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to order VMs")
+		}
+		if !up {
+			for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+				levels[i], levels[j] = levels[j], levels[i]
+			}
+		}
+
+		for idx, level := range levels {
+			names := []string{}
+			for _, domain := range level {
+				name, _ := domain.GetName()
+				names = append(names, name)
+			}
+			fmt.Printf("%v: %v\n", idx+1, strings.Join(names, ", "))
+		}
+		return
+	}
+
+	sources, err := virt.ParseAddressSources(cfg.AddressSources)
+	if err != nil {
+		logrus.WithError(err).Warn("invalid address sources; using defaults")
+		sources = virt.DefaultAddressSources
+	}
+
+	runner := startup.New(cfg, conn, hooks.New(cfg), sources, func(name string, message string, err error) {
+		if err != nil {
+			logrus.WithError(err).WithField("domain", name).Error("failed")
+		} else {
+			logrus.WithField("domain", name).Info(message)
+		}
+	})
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if up {
+		err = runner.Up(ctx, domains)
+	} else {
+		err = runner.Down(ctx, domains)
+	}
+	if err != nil {
+		logrus.WithError(err).Fatal("group action failed")
+	}
+}
+
+// Collect the VMs named on the command line, and those matching all of the
+// folder, label and query flags (if any were given).
+func selectGroup(cmd *cobra.Command, conn *virt.Connection, args []string) ([]*virt.Domain, error) {
+	folder, _ := cmd.Flags().GetString("folder")
+	label, _ := cmd.Flags().GetString("label")
+	queryText, _ := cmd.Flags().GetString("query")
+
+	selected := []*virt.Domain{}
+	names := set.New[string]()
+	for _, arg := range args {
+		domain := mustLookupDomain(conn, arg)
+		if name, err := domain.GetName(); err != nil {
+			return nil, err
+		} else if !names.Has(name) {
+			names.Add(name)
+			selected = append(selected, domain)
+		}
+	}
+
+	if folder == "" && label == "" && queryText == "" {
+		return selected, nil
+	}
+
+	q, err := query.Parse(queryText)
+	if err != nil {
+		return nil, err
+	}
+
+	domains, err := conn.EnumerateAllDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	if folder != "" {
+		folder = folders.Normalize(folder)
+	}
+
+	for _, domain := range domains {
+		metadata := domain.GetVmmData()
+		if folder != "" && !strings.HasPrefix(metadata.Path, folder) {
+			continue
+		} else if label != "" && !set.New(metadata.Labels...).Has(label) {
+			continue
+		}
+
+		subject, err := query.NewSubject(domain)
+		if err != nil {
+			return nil, err
+		} else if !q.Match(subject) || names.Has(subject.Name) {
+			continue
+		}
+
+		names.Add(subject.Name)
+		selected = append(selected, domain)
+	}
+
+	return selected, nil
+}
+
+func addGroupFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("folder", "f", "", "Select every VM in the folder (and its subfolders)")
+	cmd.Flags().StringP("label", "l", "", "Select every VM with the label")
+	cmd.Flags().StringP("query", "q", "", "Select every VM matching the query (see \"vroomm list --help\")")
+	cmd.Flags().Bool("dry-run", false, "Print the order VMs would be acted on in, without doing anything")
+}
+
+func init() {
+	rootCmd.AddCommand(upCmd)
+	rootCmd.AddCommand(downCmd)
+	rootCmd.AddCommand(dependsCmd)
+
+	addGroupFlags(upCmd)
+	addGroupFlags(downCmd)
+
+	dependsCmd.Flags().String("wait", "", "What ready means for this VM before its dependents start (agent, ip or none)")
+	dependsCmd.Flags().Duration("delay", 0, "Extra time to wait once this VM is ready before starting its dependents")
+	dependsCmd.Flags().Duration("timeout", 0, "How long to wait for this VM to become ready (default 5m)")
+	dependsCmd.Flags().Bool("clear", false, "Remove all startup settings before applying the other arguments")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2023 Caleb Stewart
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/calebstewart/vroomm/config"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Load the configuration and open a libvirt connection for command line
+// subcommands. Any failure is fatal, since none of the subcommands can
+// do anything useful without a connection.
+func mustConnect() (*config.Config, *virt.Connection) {
+	cfg, err := config.NewFromViper()
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to load configuration")
+	}
+
+	conn, err := virt.New(cfg.ConnectionString)
+	if err != nil {
+		logrus.WithError(err).WithField("connect_uri", cfg.ConnectionString).Fatal("failed to connect to libvirt")
+	}
+
+	return &cfg, conn
+}
+
+// Find a domain by name or UUID, exiting if it does not exist
+func mustLookupDomain(conn *virt.Connection, name string) *virt.Domain {
+	domain, err := conn.LookupDomain(name)
+	if err != nil {
+		logrus.WithError(err).WithField("domain", name).Fatal("failed to find domain")
+	}
+	return domain
+}
+
+// Open the document in $EDITOR (falling back to vi) attached to the current
+// terminal, and return the edited document.
+func editInTerminal(pattern string, document string) (string, error) {
+	filp, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(filp.Name())
+
+	if _, err := filp.WriteString(document); err != nil {
+		return "", err
+	} else if err := filp.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	command := exec.Command(editor, filp.Name())
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		return "", err
+	}
+
+	if newDocument, err := os.ReadFile(filp.Name()); err != nil {
+		return "", err
+	} else {
+		return string(newDocument), nil
+	}
+}
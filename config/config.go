@@ -25,6 +25,9 @@ SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package config
 
 import (
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -46,12 +49,127 @@ type LayerShell struct {
 	ExclusiveZone int  `mapstructure:"exclusivezone" toml:"exclusivezone"` // Size of the exclusive zone when anchored to an edge (default is auto)
 }
 
+type LookingGlass struct {
+	Width  uint     `mapstructure:"width" toml:"width"`   // Default guest display width used to size IVSHMEM
+	Height uint     `mapstructure:"height" toml:"height"` // Default guest display height used to size IVSHMEM
+	Args   []string `mapstructure:"args" toml:"args"`     // Extra arguments passed to every client
+}
+
+// A user-defined command shown in the VM view. The command is a template
+// using the same placeholders as viewers. The filters are optional, and an
+// action is only shown for VMs matching all of the non-empty ones.
+type Action struct {
+	Name    string   `mapstructure:"name" toml:"name"`       // Text of the menu item
+	Icon    string   `mapstructure:"icon" toml:"icon"`       // Icon name of the menu item
+	Command []string `mapstructure:"command" toml:"command"` // Command template
+	Labels  []string `mapstructure:"labels" toml:"labels"`   // Only show for VMs with any of these labels
+	Folders []string `mapstructure:"folders" toml:"folders"` // Only show for VMs within any of these folders
+	States  []string `mapstructure:"states" toml:"states"`   // Only show for VMs in any of these states (running, off)
+}
+
+// Check whether the action applies to a VM with the given folder path, labels
+// and state.
+func (action *Action) Matches(path string, labels []string, state string) bool {
+	if len(action.Labels) > 0 && !containsAny(action.Labels, labels) {
+		return false
+	}
+
+	if len(action.Folders) > 0 {
+		found := false
+		for _, folder := range action.Folders {
+			if !strings.HasSuffix(folder, "/") {
+				folder += "/"
+			}
+			if strings.HasPrefix(path, folder) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(action.States) > 0 {
+		found := false
+		for _, wanted := range action.States {
+			if strings.EqualFold(wanted, state) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsAny(wanted []string, values []string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if w == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Ordering and concurrency of actions applied to many VMs at once (marked
+// VMs, or all VMs in a folder or with a label)
+type Bulk struct {
+	Concurrency     int           `mapstructure:"concurrency" toml:"concurrency"`           // Number of VMs acted on at once (0 means no limit)
+	Order           []string      `mapstructure:"order" toml:"order"`                       // VM name patterns; groups run in this order when starting, and in reverse when stopping
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" toml:"shutdown_timeout"` // How long a dependency-ordered shutdown waits for each VM before forcing it off (and a bulk shutdown before giving up)
+}
+
+// Settings for the cloud-init seeds created for new VMs. The seed files are
+// rendered from templates in $XDG_CONFIG_HOME/vroomm/cloud-init/, falling
+// back to built-in ones.
+type CloudInit struct {
+	Pool        string   `mapstructure:"pool" toml:"pool"`                   // Storage pool seed images are uploaded to
+	User        string   `mapstructure:"user" toml:"user"`                   // User created with sudo rights and the SSH keys (cloud image default user if empty)
+	SSHKeys     []string `mapstructure:"ssh_keys" toml:"ssh_keys"`           // SSH public keys authorized in every seed
+	SSHKeyFiles []string `mapstructure:"ssh_key_files" toml:"ssh_key_files"` // Files of SSH public keys authorized in every seed
+	DNS         []string `mapstructure:"dns" toml:"dns"`                     // Name servers used with static addresses
+}
+
+// A saved query shown as a folder on the main menu
+type SmartFolder struct {
+	Name  string `mapstructure:"name" toml:"name"`   // Text of the menu item
+	Icon  string `mapstructure:"icon" toml:"icon"`   // Icon name of the menu item (optional)
+	Query string `mapstructure:"query" toml:"query"` // Query selecting the VMs shown in the folder
+}
+
+// A hook command run before and/or after a vroomm operation. It receives
+// the same JSON payload on stdin as scripts in hooks.d.
+type Hook struct {
+	Event   string   `mapstructure:"event" toml:"event"`     // Operation (start, shutdown, clone, snapshot, revert, move, label, delete)
+	Phase   string   `mapstructure:"phase" toml:"phase"`     // Either "pre" or "post" (both if empty)
+	Command []string `mapstructure:"command" toml:"command"` // Command and arguments
+}
+
 // Application Configuration
 type Config struct {
-	ConnectionString string     `mapstructure:"connect_uri" toml:"connect_uri"`
-	LayerShell       LayerShell `mapstructure:"layershell" toml:"layershell"`
-	Style            string     `mapstructure:"style" toml:"style"`
-	UseStyle         bool       `mapstructure:"use_style" toml:"use_style"`
+	ConnectionString string              `mapstructure:"connect_uri" toml:"connect_uri"`
+	LayerShell       LayerShell          `mapstructure:"layershell" toml:"layershell"`
+	Style            string              `mapstructure:"style" toml:"style"`
+	UseStyle         bool                `mapstructure:"use_style" toml:"use_style"`
+	AddressSources   []string            `mapstructure:"address_sources" toml:"address_sources"` // Where to look for VM addresses, in order (agent, lease, arp)
+	LookingGlass     LookingGlass        `mapstructure:"looking_glass" toml:"looking_glass"`
+	Viewers          map[string][]string `mapstructure:"viewers" toml:"viewers"`               // Named viewer command templates
+	OSViewers        map[string][]string `mapstructure:"os_viewers" toml:"os_viewers"`         // Ordered viewers per guest OS family (windows, linux, default)
+	Actions          []Action            `mapstructure:"actions" toml:"actions"`               // Custom actions shown in the VM view
+	Hooks            []Hook              `mapstructure:"hooks" toml:"hooks"`                   // Commands run around vroomm operations
+	HookTimeout      time.Duration       `mapstructure:"hook_timeout" toml:"hook_timeout"`     // How long a single hook may run before it is killed
+	Terminal         []string            `mapstructure:"terminal" toml:"terminal"`             // Terminal emulator command prefix used to run console programs
+	SmartFolders     []SmartFolder       `mapstructure:"smart_folders" toml:"smart_folders"`   // Saved queries shown on the main menu
+	Bulk             Bulk                `mapstructure:"bulk" toml:"bulk"`                     // Ordering and concurrency of bulk actions
+	CloudInit        CloudInit           `mapstructure:"cloud_init" toml:"cloud_init"`         // Cloud-init seeds for new and cloned VMs
+	StatsInterval    time.Duration       `mapstructure:"stats_interval" toml:"stats_interval"` // How often VM resource statistics are sampled
+	StatsHistory     int                 `mapstructure:"stats_history" toml:"stats_history"`   // Number of samples kept for sparklines
 }
 
 func NewFromViper() (Config, error) {
@@ -59,6 +177,39 @@ func NewFromViper() (Config, error) {
 		ConnectionString: "qemu:///system",
 		UseStyle:         true,
 		Style:            "",
+		AddressSources:   []string{"agent", "lease", "arp"},
+		LookingGlass: LookingGlass{
+			Width:  1920,
+			Height: 1080,
+			Args:   []string{},
+		},
+		Viewers: map[string][]string{
+			"virt-viewer":   {"virt-viewer", "--connect", "{uri}", "--auto-resize=always", "--cursor=auto", "--wait", "--reconnect", "--shared", "--uuid", "{uuid}"},
+			"remote-viewer": {"remote-viewer", "spice://{host}:{spice_port}"},
+			"looking-glass": {"looking-glass-client", "-f", "{shm}", "{lg_args}"},
+			"xfreerdp":      {"xfreerdp", "/v:{ip}", "/dynamic-resolution", "/cert:ignore"},
+			"vncviewer":     {"vncviewer", "{host}::{vnc_port}"},
+		},
+		Bulk: Bulk{
+			Concurrency:     4,
+			Order:           []string{},
+			ShutdownTimeout: 2 * time.Minute,
+		},
+		CloudInit: CloudInit{
+			Pool:        "default",
+			SSHKeys:     []string{},
+			SSHKeyFiles: []string{},
+			DNS:         []string{},
+		},
+		HookTimeout:   time.Minute,
+		Terminal:      []string{"xterm", "-e"},
+		StatsInterval: 2 * time.Second,
+		StatsHistory:  30,
+		OSViewers: map[string][]string{
+			"windows": {"xfreerdp", "virt-viewer"},
+			"linux":   {"virt-viewer", "remote-viewer"},
+			"default": {"virt-viewer"},
+		},
 	}
 
 	return cfg, viper.Unmarshal(&cfg)
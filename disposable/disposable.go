@@ -0,0 +1,226 @@
+package disposable
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"libvirt.org/go/libvirt"
+
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Called for every disposable domain the reaper deleted (err is nil) or
+// failed to delete
+type Reporter func(domain string, err error)
+
+// Creates disposable VMs, and deletes them along with their storage once
+// they shut off
+type Reaper struct {
+	Conn   func() *virt.Connection // Returns the current libvirt connection
+	Hooks  *hooks.Runner           // Hooks run around the clone, start and delete
+	Report Reporter                // Receives the outcome of every reaped domain (optional)
+
+	lock    sync.Mutex
+	reaping map[string]struct{} // UUIDs of domains being deleted
+}
+
+// The connection is looked up for every operation, so a reaper outlives
+// reconnects. Watches must be registered again on the new connection.
+func New(conn func() *virt.Connection, runner *hooks.Runner, report Reporter) *Reaper {
+	return &Reaper{
+		Conn:    conn,
+		Hooks:   runner,
+		Report:  report,
+		reaping: map[string]struct{}{},
+	}
+}
+
+// Pick an unused name for a disposable clone of the source
+func Name(conn *virt.Connection, source string) string {
+	for {
+		name := fmt.Sprintf("%v-disposable-%04x", source, rand.Intn(0x10000))
+		if _, err := conn.LookupDomainByName(name); err != nil {
+			return name
+		}
+	}
+}
+
+// Make a linked clone of the source with new MAC addresses, and mark it as
+// disposable. If marking the clone fails, it is deleted again. Running
+// sources are refused, since their disks are still being written to.
+func (r *Reaper) Clone(source *virt.Domain, name string) (*virt.Domain, error) {
+	if active, err := source.IsActive(); err != nil {
+		return nil, err
+	} else if active {
+		return nil, errors.New("cannot make a disposable clone of a running VM")
+	}
+
+	conn := r.Conn()
+	var domain *virt.Domain
+	details := map[string]string{"clone": name, "linked": "true", "disposable": "true"}
+	if err := r.Hooks.Wrap(hooks.EventClone, source, details, func() (err error) {
+		if domain, err = source.Clone(conn, name, true); err == nil {
+			details["clone_uuid"], _ = domain.GetUUIDString()
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := r.mark(conn, domain); err != nil {
+		return nil, errors.Join(err, domain.Delete(conn, true))
+	}
+
+	return domain, nil
+}
+
+func (r *Reaper) mark(conn *virt.Connection, domain *virt.Domain) error {
+	if err := domain.ResetMACs(conn); err != nil {
+		return err
+	}
+
+	// The clone is never a template
+	metadata := domain.GetVmmData()
+	metadata.Disposable = true
+	metadata.Template = false
+	return domain.UpdateVmmData(metadata)
+}
+
+// Clone the source into a disposable VM and start it. If it fails to start,
+// it is deleted again.
+func (r *Reaper) Run(source *virt.Domain, name string) (*virt.Domain, error) {
+	domain, err := r.Clone(source, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Hooks.Wrap(hooks.EventStart, domain, nil, domain.Create); err != nil {
+		return nil, errors.Join(err, domain.Delete(r.Conn(), true))
+	}
+
+	return domain, nil
+}
+
+// Delete the domain and its storage if it is disposable and shut off. A
+// domain with a saved state is kept, since it will be restored later.
+func (r *Reaper) Reap(domain *virt.Domain) (bool, error) {
+	if !domain.IsDisposable() {
+		return false, nil
+	}
+
+	if state, _, err := domain.GetState(); err != nil {
+		return false, err
+	} else if state != libvirt.DOMAIN_SHUTOFF && state != libvirt.DOMAIN_CRASHED {
+		return false, nil
+	}
+
+	if saved, err := domain.HasManagedSaveImage(0); err != nil {
+		return false, err
+	} else if saved {
+		return false, nil
+	}
+
+	uuid, err := domain.GetUUIDString()
+	if err != nil {
+		return false, err
+	}
+
+	// A sweep and a shutdown event can race for the same domain
+	r.lock.Lock()
+	if _, ok := r.reaping[uuid]; ok {
+		r.lock.Unlock()
+		return false, nil
+	}
+	r.reaping[uuid] = struct{}{}
+	r.lock.Unlock()
+
+	defer func() {
+		r.lock.Lock()
+		delete(r.reaping, uuid)
+		r.lock.Unlock()
+	}()
+
+	details := map[string]string{"remove_storage": "true", "disposable": "true"}
+	if err := r.Hooks.Wrap(hooks.EventDelete, domain, details, func() error {
+		return domain.Delete(r.Conn(), true)
+	}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Reap every disposable domain which is already shut off, e.g. because it
+// shut down while nothing was watching
+func (r *Reaper) Sweep() error {
+	domains, err := r.Conn().EnumerateStoppedDomains()
+	if err != nil {
+		return err
+	}
+
+	for _, domain := range domains {
+		r.reap(domain)
+	}
+
+	return nil
+}
+
+// Reap disposable domains as soon as they shut off. If domain is nil, all
+// domains are watched. The watch is registered on the current connection,
+// and the returned function stops watching.
+func (r *Reaper) Watch(domain *virt.Domain) (func(), error) {
+	var watched *libvirt.Domain
+	if domain != nil {
+		watched = &domain.Domain
+	}
+
+	conn := r.Conn()
+	callbackId, err := conn.DomainEventLifecycleRegister(watched, func(_ *libvirt.Connect, dom *libvirt.Domain, event *libvirt.DomainEventLifecycle) {
+		if event.Event != libvirt.DOMAIN_EVENT_STOPPED {
+			return
+		}
+
+		// The domain lives on elsewhere, or was reverted to a stopped snapshot
+		switch libvirt.DomainEventStoppedDetailType(event.Detail) {
+		case libvirt.DOMAIN_EVENT_STOPPED_MIGRATED, libvirt.DOMAIN_EVENT_STOPPED_FROM_SNAPSHOT:
+			return
+		}
+
+		uuid, err := dom.GetUUIDString()
+		if err != nil {
+			return
+		}
+
+		// Deleting the domain calls back into libvirt, which must not happen
+		// on the event loop
+		go func() {
+			if found, err := r.Conn().LookupDomainByUUIDString(uuid); err == nil {
+				if domain, err := virt.NewDomain(*found); err == nil {
+					r.reap(domain)
+				}
+			}
+		}()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		conn.DomainEventDeregister(callbackId)
+	}, nil
+}
+
+// Reap the domain and report the outcome
+func (r *Reaper) reap(domain *virt.Domain) {
+	name, err := domain.GetName()
+	if err != nil {
+		return
+	}
+
+	if reaped, err := r.Reap(domain); (reaped || err != nil) && r.Report != nil {
+		r.Report(name, err)
+	}
+}
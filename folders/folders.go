@@ -0,0 +1,363 @@
+package folders
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/sirupsen/logrus"
+
+	"github.com/calebstewart/vroomm/config"
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/set"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Manages the folder hierarchy formed by domain paths. Folders normally only
+// exist while a domain is in them, so explicitly created folders are kept in
+// a state file (per connection URI) until they are deleted.
+type Manager struct {
+	StatePath string        // Path of the JSON state file
+	URI       string        // Libvirt connection URI the folders belong to
+	Hooks     *hooks.Runner // Hooks run for every domain which is moved
+}
+
+// A domain path change which has been applied, and can be undone
+type pathChange struct {
+	domain *virt.Domain
+	from   string
+	to     string
+}
+
+func New(cfg *config.Config, runner *hooks.Runner) *Manager {
+	return &Manager{
+		StatePath: filepath.Join(xdg.StateHome, "vroomm", "folders.json"),
+		URI:       cfg.ConnectionString,
+		Hooks:     runner,
+	}
+}
+
+// Clean a folder path so it starts and ends with a slash
+func Normalize(folder string) string {
+	folder = path.Clean("/" + folder)
+	if folder != "/" {
+		folder += "/"
+	}
+	return folder
+}
+
+// Return the parent of a normalized folder path
+func Parent(folder string) string {
+	if folder == "/" {
+		return "/"
+	}
+	return Normalize(path.Dir(strings.TrimSuffix(folder, "/")))
+}
+
+// Return the direct children of parent from a list of folders
+func Children(folders []string, parent string) []string {
+	children := set.New[string]()
+	for _, folder := range folders {
+		if folder == parent || !strings.HasPrefix(folder, parent) {
+			continue
+		}
+
+		name, _, _ := strings.Cut(strings.TrimPrefix(folder, parent), "/")
+		children.Add(parent + name + "/")
+	}
+
+	result := children.Array()
+	sort.Strings(result)
+	return result
+}
+
+// List every folder (excluding the root), both those containing domains and
+// those persisted in the state file, along with all of their parents.
+func (m *Manager) List(conn *virt.Connection) ([]string, error) {
+	domains, err := conn.EnumerateAllDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	persisted, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := persisted[m.URI]
+	for _, domain := range domains {
+		paths = append(paths, domain.GetVmmData().Path)
+	}
+
+	folders := set.New[string]()
+	for _, folder := range paths {
+		for folder = Normalize(folder); folder != "/"; folder = Parent(folder) {
+			folders.Add(folder)
+		}
+	}
+
+	result := folders.Array()
+	sort.Strings(result)
+	return result, nil
+}
+
+// Create an empty folder
+func (m *Manager) Create(conn *virt.Connection, folder string) error {
+	folder = Normalize(folder)
+	if folder == "/" {
+		return errors.New("the root folder always exists")
+	}
+
+	if exists, err := m.exists(conn, folder); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("folder '%v' already exists", folder)
+	}
+
+	persisted, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	persisted[m.URI] = append(persisted[m.URI], folder)
+	return m.save(persisted)
+}
+
+// Rename a folder, moving all domains and subfolders with it. If moving any
+// domain fails, the domains already moved are moved back.
+func (m *Manager) Rename(conn *virt.Connection, from string, to string) error {
+	from, to = Normalize(from), Normalize(to)
+	if from == "/" {
+		return errors.New("cannot rename the root folder")
+	} else if from == to {
+		return nil
+	} else if strings.HasPrefix(to, from) {
+		return fmt.Errorf("cannot move folder '%v' into itself", from)
+	}
+
+	if exists, err := m.exists(conn, from); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("folder '%v' does not exist", from)
+	}
+
+	if exists, err := m.exists(conn, to); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("folder '%v' already exists", to)
+	}
+
+	return m.rewrite(conn, from, to, true)
+}
+
+// Move a folder (and everything in it) into another folder
+func (m *Manager) Move(conn *virt.Connection, folder string, parent string) error {
+	folder = Normalize(folder)
+	return m.Rename(conn, folder, Normalize(parent)+path.Base(folder))
+}
+
+// Delete a folder. Folders which are not empty are only deleted when forced,
+// in which case their domains and subfolders are moved to the parent folder.
+func (m *Manager) Delete(conn *virt.Connection, folder string, force bool) error {
+	folder = Normalize(folder)
+	if folder == "/" {
+		return errors.New("cannot delete the root folder")
+	}
+
+	folders, err := m.List(conn)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, existing := range folders {
+		if existing == folder {
+			found = true
+		} else if strings.HasPrefix(existing, folder) && !force {
+			return fmt.Errorf("folder '%v' is not empty", folder)
+		}
+	}
+	if !found {
+		return fmt.Errorf("folder '%v' does not exist", folder)
+	}
+
+	if !force {
+		domains, err := conn.EnumerateAllDomains()
+		if err != nil {
+			return err
+		}
+
+		for _, domain := range domains {
+			if domain.GetVmmData().Path == folder {
+				return fmt.Errorf("folder '%v' is not empty", folder)
+			}
+		}
+	}
+
+	return m.rewrite(conn, folder, Parent(folder), false)
+}
+
+// Check whether a folder exists
+func (m *Manager) exists(conn *virt.Connection, folder string) (bool, error) {
+	folders, err := m.List(conn)
+	if err != nil {
+		return false, err
+	}
+
+	for _, existing := range folders {
+		if existing == folder {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Move every domain and persisted folder under from to the same place under
+// to. If keep is set, a persisted entry for from itself becomes one for to,
+// and otherwise it is dropped. Domains are moved one at a time, so a failure
+// rolls back the domains already moved.
+func (m *Manager) rewrite(conn *virt.Connection, from string, to string, keep bool) error {
+	domains, err := conn.EnumerateAllDomains()
+	if err != nil {
+		return err
+	}
+
+	changes := []pathChange{}
+	for _, domain := range domains {
+		current := domain.GetVmmData().Path
+		if !strings.HasPrefix(current, from) {
+			continue
+		}
+
+		changes = append(changes, pathChange{
+			domain: domain,
+			from:   current,
+			to:     to + strings.TrimPrefix(current, from),
+		})
+	}
+
+	persisted, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	folders := []string{}
+	for _, folder := range persisted[m.URI] {
+		if folder == from {
+			if keep {
+				folders = append(folders, to)
+			}
+		} else if strings.HasPrefix(folder, from) {
+			folders = append(folders, to+strings.TrimPrefix(folder, from))
+		} else {
+			folders = append(folders, folder)
+		}
+	}
+
+	applied := []pathChange{}
+	for _, change := range changes {
+		if err := m.setPath(change.domain, change.from, change.to); err != nil {
+			return m.rollback(applied, err)
+		}
+		applied = append(applied, change)
+	}
+
+	persisted[m.URI] = dedupe(folders)
+	if err := m.save(persisted); err != nil {
+		return m.rollback(applied, err)
+	}
+
+	return nil
+}
+
+// Change the path of a single domain, running move hooks around it
+func (m *Manager) setPath(domain *virt.Domain, from string, to string) error {
+	details := map[string]string{"from": from, "to": to}
+	return m.Hooks.Wrap(hooks.EventMove, domain, details, func() error {
+		metadata := domain.GetVmmData()
+		metadata.Path = to
+		return domain.UpdateVmmData(metadata)
+	})
+}
+
+// Undo applied path changes in reverse order. Hooks are not run, since the
+// domains end up where they started.
+func (m *Manager) rollback(applied []pathChange, cause error) error {
+	failed := 0
+	for idx := len(applied) - 1; idx >= 0; idx-- {
+		change := applied[idx]
+		metadata := change.domain.GetVmmData()
+		metadata.Path = change.from
+		if err := change.domain.UpdateVmmData(metadata); err != nil {
+			name, _ := change.domain.GetName()
+			logrus.WithError(err).WithField("domain", name).Errorf("failed to move domain back to '%v'", change.from)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w (rollback failed for %v domains)", cause, failed)
+	}
+	return fmt.Errorf("%w (changes rolled back)", cause)
+}
+
+// Load persisted folders for all connections. A missing state file is the
+// same as an empty one.
+func (m *Manager) load() (map[string][]string, error) {
+	persisted := map[string][]string{}
+
+	data, err := os.ReadFile(m.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return persisted, nil
+	} else if err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(data, &persisted); err != nil {
+		return nil, fmt.Errorf("%v: %w", m.StatePath, err)
+	}
+
+	return persisted, nil
+}
+
+// Save persisted folders, replacing the state file atomically
+func (m *Manager) save(persisted map[string][]string) error {
+	if len(persisted[m.URI]) == 0 {
+		delete(persisted, m.URI)
+	}
+
+	data, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.StatePath), 0o755); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(m.StatePath), ".folders.*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	} else if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), m.StatePath)
+}
+
+func dedupe(folders []string) []string {
+	result := set.New(folders...).Array()
+	sort.Strings(result)
+	return result
+}
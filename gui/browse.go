@@ -2,11 +2,13 @@ package gui
 
 import (
 	"context"
-	"sort"
+	"fmt"
+	"path"
 	"strings"
 
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 
+	"github.com/calebstewart/vroomm/folders"
 	"github.com/calebstewart/vroomm/set"
 	"github.com/calebstewart/vroomm/virt"
 )
@@ -55,8 +57,8 @@ func (menu *BrowseAllView) Enter(app *Application) error {
 	menu.EmptyItems()
 
 	go func() {
-		virt := app.Virt()
-		if domains, err := virt.EnumerateAllDomains(); err != nil {
+		virtConn := app.Virt()
+		if domains, err := virtConn.EnumerateAllDomains(); err != nil {
 			app.Logger.Error(err.Error())
 		} else {
 			glib.IdleAdd(func() {
@@ -107,54 +109,44 @@ func (menu *BrowseFolderView) Enter(app *Application) error {
 		virtConn := app.Virt()
 		if domains, err := virtConn.EnumerateAllDomains(); err != nil {
 			app.Logger.Error(err.Error())
+		} else if allFolders, err := app.Folders.List(virtConn); err != nil {
+			app.Logger.Error(err.Error())
 		} else {
 			glib.IdleAdd(func() {
+				for _, folder := range folders.Children(allFolders, menu.Folder) {
+					menu.Add(NewBrowseFolderItem(app, menu.Folder, strings.TrimPrefix(folder, menu.Folder)))
+				}
 
-				// Array of sub folders to show items for
-				folders := []string{}
-				// Array of domains to show items for
-				directDomains := []*virt.Domain{}
-
-				// Collect all direct domains and potential child folders
 				for _, domain := range domains {
-					metadata := domain.GetVmmData()
-					if metadata.Path == menu.Folder {
-						directDomains = append(directDomains, domain)
-					} else if strings.HasPrefix(metadata.Path, menu.Folder) {
-						folders = append(folders, metadata.Path)
+					if metadata := domain.GetVmmData(); metadata.Path != menu.Folder {
+						continue
+					} else if item, err := NewVirtualMachineItem(app, domain); err != nil {
+						app.Logger.Error(err.Error())
+					} else {
+						item.ShowAll()
+						menu.Add(item)
 					}
 				}
 
-				directFolders := []string{}
-
-				// Sort the folders by length
-				sort.Slice(folders, func(i, j int) bool {
-					return len(folders[i]) < len(folders[j])
-				})
-
-				// Find all unique direct child folders
-			outer:
-				for _, folder := range folders {
-					for _, existing := range directFolders {
-						if strings.HasPrefix(folder, existing) {
-							continue outer
-						}
+				// Actions apply to every VM in the folder and its subfolders
+				contained := []*virt.Domain{}
+				for _, domain := range domains {
+					if strings.HasPrefix(domain.GetVmmData().Path, menu.Folder) {
+						contained = append(contained, domain)
 					}
-
-					directFolders = append(directFolders, folder)
 				}
-
-				for _, folder := range directFolders {
-					menu.Add(NewBrowseFolderItem(app, menu.Folder, strings.TrimPrefix(folder, menu.Folder)))
+				if len(contained) > 0 {
+					title := fmt.Sprintf("Actions (%v VMs)", len(contained))
+					menu.Add(NewLabelItemWithAction(bulkIcon, "Actions", func() {
+						app.Push(NewBulkView(title, contained))
+					}))
 				}
 
-				for _, domain := range directDomains {
-					if item, err := NewVirtualMachineItem(app, domain); err != nil {
-						app.Logger.Error(err.Error())
-					} else {
-						item.ShowAll()
-						menu.Add(item)
-					}
+				menu.Add(NewLabelItemWithAction("folder-new-symbolic", "New Folder", menu.newFolder(app)))
+				if menu.Folder != "/" {
+					menu.Add(NewLabelItemWithAction("document-edit-symbolic", "Rename Folder", menu.renameFolder(app)))
+					menu.Add(NewLabelItemWithAction("go-jump-symbolic", "Move Folder", menu.moveFolder(app, allFolders)))
+					menu.Add(NewLabelItemWithAction("edit-delete-symbolic", "Delete Folder", app.ActivationWithPulse("Deleting folder...", menu.deleteFolder)))
 				}
 
 				menu.InvalidateFilter()
@@ -169,6 +161,93 @@ func (menu *BrowseFolderView) Enter(app *Application) error {
 	return nil
 }
 
+// Folder operations run in the background once the prompt is popped, and
+// reload the folder view with the result. The view keeps its original title.
+func (menu *BrowseFolderView) newFolder(app *Application) func() {
+	return func() {
+		app.Push(NewPrompt(app, "New Folder", "Name>", false, func(app *Application, entry string) {
+			folder := folders.Normalize(menu.Folder + entry)
+			app.Pop()
+
+			app.ActivationWithPulse("Creating folder...", func(app *Application) (string, error) {
+				if err := app.Folders.Create(app.Virt(), folder); err != nil {
+					return "", err
+				}
+				menu.reload(app, menu.Folder)
+				return fmt.Sprintf("Created folder '%v'", folder), nil
+			})()
+		}))
+	}
+}
+
+func (menu *BrowseFolderView) renameFolder(app *Application) func() {
+	return func() {
+		app.Push(NewPrompt(app, "Rename Folder", "Name>", false, func(app *Application, entry string) {
+			from, to := menu.Folder, folders.Normalize(folders.Parent(menu.Folder)+entry)
+			app.Pop()
+
+			app.ActivationWithPulse("Renaming folder...", func(app *Application) (string, error) {
+				if err := app.Folders.Rename(app.Virt(), from, to); err != nil {
+					return "", err
+				}
+				menu.reload(app, to)
+				return fmt.Sprintf("Renamed folder '%v' to '%v'", from, to), nil
+			})()
+		}))
+	}
+}
+
+func (menu *BrowseFolderView) moveFolder(app *Application, allFolders []string) func() {
+	return func() {
+		// A folder cannot be moved into itself or one of its subfolders
+		items := []*LabelItem{}
+		for _, folder := range append([]string{"/"}, allFolders...) {
+			if !strings.HasPrefix(folder, menu.Folder) && folder != folders.Parent(menu.Folder) {
+				items = append(items, NewLabelItem(folderIcon, folder))
+			}
+		}
+
+		app.Push(NewPrompt(app, "Move Folder", "Path>", false, func(app *Application, entry string) {
+			from, to := menu.Folder, folders.Normalize(entry)+path.Base(menu.Folder)+"/"
+			app.Pop()
+
+			app.ActivationWithPulse("Moving folder...", func(app *Application) (string, error) {
+				if err := app.Folders.Move(app.Virt(), from, entry); err != nil {
+					return "", err
+				}
+				menu.reload(app, to)
+				return fmt.Sprintf("Moved folder '%v' to '%v'", from, to), nil
+			})()
+		}, items...))
+	}
+}
+
+// Delete the folder, moving its contents to the parent folder, and return
+// to the parent folder view.
+func (menu *BrowseFolderView) deleteFolder(app *Application) (string, error) {
+	if err := app.Folders.Delete(app.Virt(), menu.Folder, true); err != nil {
+		return "", err
+	}
+
+	glib.IdleAdd(func() {
+		if app.Top() == View(menu) {
+			app.Pop()
+		}
+	})
+	return fmt.Sprintf("Deleted folder '%v' (contents moved to '%v')", menu.Folder, folders.Parent(menu.Folder)), nil
+}
+
+// Show the given folder once a folder operation finished, if the view is
+// still open
+func (menu *BrowseFolderView) reload(app *Application, folder string) {
+	glib.IdleAdd(func() {
+		menu.Folder = folder
+		if app.Top() == View(menu) {
+			menu.Enter(app)
+		}
+	})
+}
+
 func (menu *BrowseFolderView) Close(app *Application) error {
 	return nil
 }
@@ -261,12 +340,14 @@ func (view *BrowseLabelView) Enter(app *Application) error {
 		} else {
 			glib.IdleAdd(func() {
 				// Collect all direct domains and potential child folders
+				labeled := []*virt.Domain{}
 				for _, domain := range domains {
 					metadata := domain.GetVmmData()
 					labels := set.New(metadata.Labels...)
 
 					// Add any VMs with this label
 					if labels.Has(view.Label) {
+						labeled = append(labeled, domain)
 						item, err := NewVirtualMachineItem(app, domain)
 						if err != nil {
 							app.Logger.Error(err.Error())
@@ -276,6 +357,13 @@ func (view *BrowseLabelView) Enter(app *Application) error {
 					}
 				}
 
+				if len(labeled) > 0 {
+					title := fmt.Sprintf("Actions (%v VMs)", len(labeled))
+					view.Add(NewLabelItemWithAction(bulkIcon, "Actions", func() {
+						app.Push(NewBulkView(title, labeled))
+					}))
+				}
+
 				// Ensure the pulsing stops
 				cancel()
 			})
@@ -0,0 +1,475 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"libvirt.org/go/libvirt"
+
+	"github.com/calebstewart/vroomm/folders"
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/set"
+	"github.com/calebstewart/vroomm/startup"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	labelIcon = "user-bookmarks-symbolic"
+	bulkIcon  = "view-list-symbolic"
+
+	bulkShutdownPollInterval = time.Second
+)
+
+// An operation applied to a single VM by a bulk action
+type bulkOperation func(app *Application, domain *virt.Domain) error
+
+// A menu of actions which are run on every marked VM, or on every VM in a
+// folder or with a label. The result for each VM is written to the log.
+type BulkView struct {
+	Domains []*virt.Domain
+	*FlowboxMenu
+}
+
+func NewBulkView(title string, domains []*virt.Domain) *BulkView {
+	return &BulkView{
+		Domains:     domains,
+		FlowboxMenu: NewFlowboxMenu(title),
+	}
+}
+
+func (view *BulkView) Enter(app *Application) error {
+	view.EmptyItems()
+
+	view.Add(NewLabelItemWithAction("media-playback-start-symbolic", "Start", func() {
+		view.run(app, "Start", false, bulkStart)
+	}))
+	view.Add(NewLabelItemWithAction("system-shutdown-symbolic", "Shutdown", func() {
+		view.run(app, "Shutdown", true, bulkShutdown)
+	}))
+	view.Add(NewLabelItemWithAction("face-shutmouth-symbolic", "Force Off", func() {
+		view.run(app, "Force Off", true, bulkForceOff)
+	}))
+	view.Add(NewLabelItemWithAction("media-playback-pause-symbolic", "Suspend", func() {
+		view.run(app, "Suspend", true, bulkSuspend)
+	}))
+	view.Add(NewLabelItemWithAction("media-playback-start-symbolic", "Resume", func() {
+		view.run(app, "Resume", false, bulkResume)
+	}))
+	view.Add(NewLabelItemWithAction("go-up-symbolic", "Up (Dependency Order)", func() {
+		view.runGroup(app, "Up", true)
+	}))
+	view.Add(NewLabelItemWithAction("go-down-symbolic", "Down (Reverse Order)", func() {
+		view.runGroup(app, "Down", false)
+	}))
+	view.Add(NewLabelItemWithAction("camera-photo-symbolic", "Take Snapshot", view.snapshot(app)))
+	view.Add(NewLabelItemWithAction("document-revert-symbolic", "Revert to Snapshot", view.revert(app)))
+	view.Add(NewLabelItemWithAction(folderIcon, "Move to Folder", view.move(app)))
+	view.Add(NewLabelItemWithAction(labelIcon, "Add Label", view.addLabel(app)))
+	view.Add(NewLabelItemWithAction(labelIcon, "Remove Label", view.removeLabel(app)))
+	view.Add(NewLabelItemWithAction("edit-delete-symbolic", "Delete", view.delete(app)))
+
+	return view.FlowboxMenu.Enter(app)
+}
+
+func (view *BulkView) Leave(app *Application) error {
+	return nil
+}
+
+func (view *BulkView) Close(app *Application) error {
+	return nil
+}
+
+// Run the operation on every VM in the background, logging the result for
+// each VM and a summary once all of them are done. VMs are acted on in the
+// configured order groups (reversed when stopping), with up to the
+// configured number of VMs of a group at once. The next group only starts
+// once every operation of the current group returned, and a shutdown only
+// returns once the VM is off (or the shutdown timeout passed).
+func (view *BulkView) run(app *Application, action string, reverse bool, operation bulkOperation) {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.PulseProgress(ctx, fmt.Sprintf("%v: %v VMs...", action, len(view.Domains)))
+
+	groups := orderDomains(view.Domains, app.Config.Bulk.Order)
+	if reverse {
+		for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+			groups[i], groups[j] = groups[j], groups[i]
+		}
+	}
+
+	go func() {
+		defer cancel()
+
+		var failed atomic.Int32
+		for _, group := range groups {
+			limit := app.Config.Bulk.Concurrency
+			if limit <= 0 || limit > len(group) {
+				limit = len(group)
+			}
+
+			slots := make(chan struct{}, limit)
+			wg := sync.WaitGroup{}
+			for _, domain := range group {
+				slots <- struct{}{}
+				wg.Add(1)
+
+				go func(domain *virt.Domain) {
+					defer func() {
+						<-slots
+						wg.Done()
+					}()
+
+					name, err := domain.GetName()
+					if err == nil {
+						err = operation(app, domain)
+					}
+					if err != nil {
+						failed.Add(1)
+					}
+
+					glib.IdleAdd(func() {
+						logger := app.Logger.WithField("domain", name)
+						if err != nil {
+							logger.Errorf("%v failed for '%v': %v", action, name, err)
+						} else {
+							logger.Infof("%v succeeded for '%v'", action, name)
+						}
+					})
+				}(domain)
+			}
+			wg.Wait()
+		}
+
+		glib.IdleAdd(func() {
+			if count := failed.Load(); count > 0 {
+				app.Logger.Errorf("%v failed for %v of %v VMs; press Ctrl+L for details", action, count, len(view.Domains))
+			} else {
+				app.Logger.Infof("%v succeeded for all %v VMs", action, len(view.Domains))
+			}
+		})
+	}()
+}
+
+// Start the VMs (and the VMs they depend on) in dependency order, or shut
+// them down in reverse dependency order, in the background. Progress for
+// each VM and the overall result are written to the log.
+func (view *BulkView) runGroup(app *Application, action string, up bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.PulseProgress(ctx, fmt.Sprintf("%v: %v VMs...", action, len(view.Domains)))
+
+	runner := startup.New(app.Config, app.Virt(), app.Hooks, app.AddressSources, func(name string, message string, err error) {
+		glib.IdleAdd(func() {
+			logger := app.Logger.WithField("domain", name)
+			if err != nil {
+				logger.Errorf("%v failed for '%v': %v", action, name, err)
+			} else {
+				logger.Infof("%v: '%v' %v", action, name, message)
+			}
+		})
+	})
+
+	go func() {
+		defer cancel()
+
+		var err error
+		if up {
+			err = runner.Up(ctx, view.Domains)
+		} else {
+			err = runner.Down(ctx, view.Domains)
+		}
+
+		glib.IdleAdd(func() {
+			if err != nil {
+				app.Logger.Errorf("%v failed: %v; press Ctrl+L for details", action, err)
+			} else {
+				app.Logger.Infof("%v succeeded for all %v VMs", action, len(view.Domains))
+			}
+		})
+	}()
+}
+
+// Split domains into groups by the first name pattern they match, in the
+// order of the patterns. Domains matching no pattern form the last group.
+func orderDomains(domains []*virt.Domain, patterns []string) [][]*virt.Domain {
+	groups := make([][]*virt.Domain, len(patterns)+1)
+
+outer:
+	for _, domain := range domains {
+		name, _ := domain.GetName()
+		for idx, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				groups[idx] = append(groups[idx], domain)
+				continue outer
+			}
+		}
+		groups[len(patterns)] = append(groups[len(patterns)], domain)
+	}
+
+	nonEmpty := [][]*virt.Domain{}
+	for _, group := range groups {
+		if len(group) > 0 {
+			nonEmpty = append(nonEmpty, group)
+		}
+	}
+	return nonEmpty
+}
+
+func bulkStart(app *Application, domain *virt.Domain) error {
+	if active, err := domain.IsActive(); err != nil || active {
+		return err
+	} else if err := domain.CheckNotTemplate(); err != nil {
+		return err
+	}
+	return app.Hooks.Wrap(hooks.EventStart, domain, nil, domain.Create)
+}
+
+// Shut the VM down and wait for it to be off, so the next order group is
+// only stopped once this one is down. VMs are never forced off here.
+func bulkShutdown(app *Application, domain *virt.Domain) error {
+	if active, err := domain.IsActive(); err != nil || !active {
+		return err
+	} else if err := app.Hooks.Wrap(hooks.EventShutdown, domain, nil, domain.Shutdown); err != nil {
+		return err
+	}
+
+	timeout := time.After(app.Config.Bulk.ShutdownTimeout)
+	ticker := time.NewTicker(bulkShutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if active, err := domain.IsActive(); err != nil || !active {
+			return err
+		}
+
+		select {
+		case <-timeout:
+			return fmt.Errorf("did not shut down within %v", app.Config.Bulk.ShutdownTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+func bulkForceOff(app *Application, domain *virt.Domain) error {
+	if active, err := domain.IsActive(); err != nil || !active {
+		return err
+	}
+	return app.Hooks.Wrap(hooks.EventShutdown, domain, map[string]string{"force": "true"}, domain.Destroy)
+}
+
+func bulkSuspend(app *Application, domain *virt.Domain) error {
+	if state, _, err := domain.GetState(); err != nil || state != libvirt.DOMAIN_RUNNING {
+		return err
+	}
+	return domain.Suspend()
+}
+
+func bulkResume(app *Application, domain *virt.Domain) error {
+	if state, _, err := domain.GetState(); err != nil || state != libvirt.DOMAIN_PAUSED {
+		return err
+	}
+	return domain.Resume()
+}
+
+func (view *BulkView) snapshot(app *Application) func() {
+	return func() {
+		app.Push(NewPrompt(app, "Snapshot", "Snapshot Name>", false, func(app *Application, name string) {
+			app.Pop()
+			view.run(app, "Snapshot", false, func(app *Application, domain *virt.Domain) error {
+				if err := domain.CheckNotTemplate(); err != nil {
+					return err
+				}
+				return app.Hooks.Wrap(hooks.EventSnapshot, domain, map[string]string{"snapshot": name}, func() error {
+					return domain.Snapshot(name)
+				})
+			})
+		}))
+	}
+}
+
+// Revert every VM to a snapshot with the chosen name. The prompt offers the
+// names of snapshots which any of the VMs have.
+func (view *BulkView) revert(app *Application) func() {
+	return func() {
+		names := set.New[string]()
+		for _, domain := range view.Domains {
+			if snapshotNames, err := domain.SnapshotListNames(0); err == nil {
+				names.Add(snapshotNames...)
+			}
+		}
+
+		items := []*LabelItem{}
+		for _, name := range names.Array() {
+			items = append(items, NewLabelItem("camera-photo-symbolic", name))
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].Text < items[j].Text
+		})
+
+		app.Push(NewPrompt(app, "Revert to Snapshot", "Snapshot Name>", true, func(app *Application, name string) {
+			app.Pop()
+			view.run(app, "Revert", false, func(app *Application, domain *virt.Domain) error {
+				// Reverting a disk changes it under the linked clones stacked on it
+				if err := domain.CheckNotTemplate(); err != nil {
+					return err
+				} else if err := domain.CheckNoDependents(app.Virt()); err != nil {
+					return err
+				}
+
+				snapshot, err := domain.SnapshotLookupByName(name, 0)
+				if err != nil {
+					return err
+				}
+				defer snapshot.Free()
+
+				return app.Hooks.Wrap(hooks.EventRevert, domain, map[string]string{"snapshot": name}, func() error {
+					return snapshot.RevertToSnapshot(0)
+				})
+			})
+		}, items...))
+	}
+}
+
+func (view *BulkView) move(app *Application) func() {
+	return func() {
+		allFolders, err := app.Folders.List(app.Virt())
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		items := []*LabelItem{NewLabelItem(folderIcon, "/")}
+		for _, folder := range allFolders {
+			items = append(items, NewLabelItem(folderIcon, folder))
+		}
+
+		app.Push(NewPrompt(app, "Move to Folder", "Path>", false, func(app *Application, entry string) {
+			app.Pop()
+
+			folder := folders.Normalize(entry)
+			view.run(app, "Move", false, func(app *Application, domain *virt.Domain) error {
+				if err := domain.CheckNotTemplate(); err != nil {
+					return err
+				}
+
+				metadata := domain.GetVmmData()
+				details := map[string]string{"from": metadata.Path, "to": folder}
+				metadata.Path = folder
+
+				return app.Hooks.Wrap(hooks.EventMove, domain, details, func() error {
+					return domain.UpdateVmmData(metadata)
+				})
+			})
+		}, items...))
+	}
+}
+
+func (view *BulkView) addLabel(app *Application) func() {
+	return func() {
+		domains, err := app.Virt().EnumerateAllDomains()
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		labels := set.New[string]()
+		for _, domain := range domains {
+			labels.Add(domain.GetVmmData().Labels...)
+		}
+
+		app.Push(NewPrompt(app, "Add VM Label", "Label>", false, func(app *Application, entry string) {
+			app.Pop()
+			view.run(app, "Add Label", false, func(app *Application, domain *virt.Domain) error {
+				if err := domain.CheckNotTemplate(); err != nil {
+					return err
+				}
+
+				metadata := domain.GetVmmData()
+				labels := set.New(metadata.Labels...)
+				if labels.Has(entry) {
+					return nil
+				}
+
+				labels.Add(entry)
+				metadata.Labels = labels.Array()
+
+				return app.Hooks.Wrap(hooks.EventLabel, domain, map[string]string{"add": entry}, func() error {
+					return domain.UpdateVmmData(metadata)
+				})
+			})
+		}, labelItems(labels)...))
+	}
+}
+
+func (view *BulkView) removeLabel(app *Application) func() {
+	return func() {
+		labels := set.New[string]()
+		for _, domain := range view.Domains {
+			labels.Add(domain.GetVmmData().Labels...)
+		}
+
+		app.Push(NewPrompt(app, "Remove VM Label", "Label>", true, func(app *Application, entry string) {
+			app.Pop()
+			view.run(app, "Remove Label", false, func(app *Application, domain *virt.Domain) error {
+				if err := domain.CheckNotTemplate(); err != nil {
+					return err
+				}
+
+				metadata := domain.GetVmmData()
+				labels := set.New(metadata.Labels...)
+				if !labels.Has(entry) {
+					return nil
+				}
+
+				labels.Remove(entry)
+				metadata.Labels = labels.Array()
+
+				return app.Hooks.Wrap(hooks.EventLabel, domain, map[string]string{"remove": entry}, func() error {
+					return domain.UpdateVmmData(metadata)
+				})
+			})
+		}, labelItems(labels)...))
+	}
+}
+
+// Deleting requires picking how to treat disks, which doubles as a
+// confirmation.
+func (view *BulkView) delete(app *Application) func() {
+	const (
+		keepDisks   = "Delete VMs (keep disks)"
+		removeDisks = "Delete VMs and their disks"
+	)
+
+	return func() {
+		app.Push(NewPrompt(app, fmt.Sprintf("Delete %v VMs", len(view.Domains)), "Confirm>", true, func(app *Application, entry string) {
+			app.Pop()
+
+			removeStorage := entry == removeDisks
+			details := map[string]string{"remove_storage": fmt.Sprintf("%v", removeStorage)}
+			view.run(app, "Delete", true, func(app *Application, domain *virt.Domain) error {
+				return app.Hooks.Wrap(hooks.EventDelete, domain, details, func() error {
+					return domain.Delete(app.Virt(), removeStorage)
+				})
+			})
+		}, NewLabelItem("edit-delete-symbolic", keepDisks), NewLabelItem("edit-delete-symbolic", removeDisks)))
+	}
+}
+
+// Create sorted prompt items for a set of labels
+func labelItems(labels set.Set[string]) []*LabelItem {
+	names := labels.Array()
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+
+	items := []*LabelItem{}
+	for _, label := range names {
+		items = append(items, NewLabelItem(labelIcon, label))
+	}
+	return items
+}
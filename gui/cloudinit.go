@@ -0,0 +1,102 @@
+package gui
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/calebstewart/vroomm/cloudinit"
+)
+
+const (
+	seedIcon = "media-optical-symbolic"
+
+	noSeedItem    = "No cloud-init seed"
+	dhcpItem      = "DHCP"
+	noGatewayItem = "No gateway"
+)
+
+// Ask for the hostname and address of a cloud-init seed, one prompt
+// replacing the other. The SSH keys, user and name servers come from the
+// configuration. done receives nil if no seed is wanted, and is responsible
+// for the last prompt.
+func newSeedPrompt(app *Application, name string, done func(app *Application, vars *cloudinit.Variables)) *Prompt {
+	vars := &cloudinit.Variables{}
+
+	gateway := func(app *Application, entry string) {
+		if entry != noGatewayItem {
+			if net.ParseIP(entry) == nil {
+				app.Logger.Errorf("invalid gateway '%v'", entry)
+				return
+			}
+			vars.Gateway = entry
+		}
+		done(app, vars)
+	}
+
+	address := func(app *Application, entry string) {
+		if entry == dhcpItem {
+			done(app, vars)
+			return
+		}
+
+		ip, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			app.Logger.Errorf("invalid address '%v': expected CIDR notation (e.g. 10.0.0.5/24)", entry)
+			return
+		}
+		vars.Address = entry
+
+		// Suggest the first address of the network, which is the usual gateway
+		items := []*LabelItem{NewLabelItem("network-wired-disconnected-symbolic", noGatewayItem)}
+		if first := network.IP.To4(); first != nil {
+			first[3]++
+			if !first.Equal(ip) {
+				items = append([]*LabelItem{NewLabelItem("network-wired-symbolic", first.String())}, items...)
+			}
+		}
+		app.ReplaceTop(NewPrompt(app, "Gateway", "Gateway>", false, gateway, items...))
+	}
+
+	hostname := func(app *Application, entry string) {
+		if entry == noSeedItem {
+			done(app, nil)
+			return
+		} else if err := cloudinit.ValidateHostname(entry); err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		vars.Hostname = entry
+		app.ReplaceTop(NewPrompt(app, "Address", "Address (CIDR)>", false, address,
+			NewLabelItem("network-wired-symbolic", dhcpItem),
+		))
+	}
+
+	items := []*LabelItem{}
+	if cloudinit.ValidateHostname(name) == nil {
+		items = append(items, NewLabelItem("network-server-symbolic", name))
+	}
+	items = append(items, NewLabelItem("edit-clear-symbolic", noSeedItem))
+
+	return NewPrompt(app, "Hostname (cloud-init)", "Hostname>", false, hostname, items...)
+}
+
+// Render a cloud-init seed for the VM and insert it, replacing any older one.
+// It takes effect on the next boot.
+func (view *VirtualMachineView) attachSeed(app *Application) (string, error) {
+	app.Push(newSeedPrompt(app, view.DomainName, func(app *Application, vars *cloudinit.Variables) {
+		app.Pop()
+		if vars == nil {
+			return
+		}
+
+		app.ActivationWithPulse("Attaching cloud-init seed...", func(app *Application) (string, error) {
+			if _, err := cloudinit.New(app.Config, app.Virt()).Attach(view.Domain, *vars); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Attached cloud-init seed to '%v'", view.DomainName), nil
+		})()
+	}))
+
+	return "", nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"libvirt.org/go/libvirtxml"
 
+	"github.com/calebstewart/vroomm/cloudinit"
 	"github.com/calebstewart/vroomm/virt"
 )
 
@@ -59,12 +60,15 @@ func finishVmName(app *Application, domain *libvirtxml.Domain, metadata *virt.Vm
 		// Update the domain name
 		domain.Name = input
 		glib.IdleAdd(func() {
-			app.Push(newCreateVmMemoryPrompt(app, domain, metadata))
+			app.Push(newSeedPrompt(app, input, func(app *Application, seed *cloudinit.Variables) {
+				app.ReplaceTop(newCreateVmMemoryPrompt(app, domain, metadata, seed))
+			}))
 		})
 	}()
 }
 
-func newCreateVmMemoryPrompt(app *Application, domain *libvirtxml.Domain, metadata *virt.VmmDomainMetadata) *Prompt {
+// The seed (if any) is attached once the new VM is defined
+func newCreateVmMemoryPrompt(app *Application, domain *libvirtxml.Domain, metadata *virt.VmmDomainMetadata, seed *cloudinit.Variables) *Prompt {
 	return NewPrompt(
 		app,
 		"Memory Size",
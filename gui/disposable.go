@@ -0,0 +1,79 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+
+	"github.com/calebstewart/vroomm/disposable"
+)
+
+const (
+	disposableIcon = "edit-delete-symbolic"
+)
+
+// Start deleting disposable VMs once they shut off, including any which shut
+// off while vroomm wasn't running
+func (app *Application) startReaper() {
+	app.Reaper = disposable.New(app.Virt, app.Hooks, app.reaped)
+	app.watchDisposable()
+}
+
+// Watch for disposable VMs shutting off on the current connection, and
+// delete those which already did. Called again after reconnecting, since
+// event callbacks don't survive the old connection.
+func (app *Application) watchDisposable() {
+	app.reaperLock.Lock()
+	defer app.reaperLock.Unlock()
+
+	if app.stopReaper != nil {
+		app.stopReaper()
+		app.stopReaper = nil
+	}
+
+	if stop, err := app.Reaper.Watch(nil); err != nil {
+		app.Logger.Errorf("failed to watch disposable VMs: %v", err)
+	} else {
+		app.stopReaper = stop
+	}
+
+	go func() {
+		if err := app.Reaper.Sweep(); err != nil {
+			app.Logger.Errorf("failed to clean up disposable VMs: %v", err)
+		}
+	}()
+}
+
+// Report a reaped VM, and close its view if it is open
+func (app *Application) reaped(domain string, err error) {
+	glib.IdleAdd(func() {
+		if err != nil {
+			app.Logger.Errorf("failed to delete disposable VM '%v': %v", domain, err)
+			return
+		}
+
+		app.Logger.Infof("Deleted disposable VM '%v'", domain)
+		if view, ok := app.Top().(*VirtualMachineView); ok && view.DomainName == domain {
+			app.Pop()
+		}
+	})
+}
+
+// Create a linked clone which is deleted once it shuts off, start it and
+// show it
+func (view *VirtualMachineView) disposableClone(app *Application) (string, error) {
+	name := disposable.Name(app.Virt(), view.DomainName)
+
+	domain, err := app.Reaper.Run(view.Domain, name)
+	if err != nil {
+		return "", err
+	}
+
+	if domainView, err := NewVirtualMachineView(app, domain); err == nil {
+		glib.IdleAdd(func() {
+			app.Push(domainView)
+		})
+	}
+
+	return fmt.Sprintf("Started disposable VM '%v'; it is deleted when it shuts off", name), nil
+}
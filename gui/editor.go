@@ -0,0 +1,71 @@
+package gui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// Save the document to a temporary file and open it with xdg-open. The
+// application window is hidden while the editor runs. The edited document
+// is returned, which is identical to the original if nothing changed or
+// the editor failed to run.
+func editDocument(app *Application, pattern string, document string) (string, error) {
+	// Create a temporary file for editing the document
+	filp, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+
+	// Ensure the temporary file is removed
+	defer os.Remove(filp.Name())
+
+	// Write the document to the file
+	if count, err := filp.WriteString(document); err != nil || count != len(document) {
+		return "", fmt.Errorf("failed to save temporary definition")
+	}
+
+	// Ensure our writes get to disk
+	if err := filp.Sync(); err != nil {
+		return "", err
+	}
+
+	// Seek to the beginning for later
+	if _, err := filp.Seek(0, 0); err != nil {
+		return "", err
+	}
+
+	// Edit the file with xdg-open
+	command := exec.Command(
+		"xdg-open",
+		filp.Name(),
+	)
+	command.Stdout = nil
+	command.Stderr = nil
+	command.Stdin = nil
+
+	// Hide the application window
+	glib.IdleAdd(func() {
+		app.Window.Hide()
+	})
+
+	// Ensure we show the window when completed no matter what
+	defer func() {
+		glib.IdleAdd(func() {
+			app.Window.Show()
+		})
+	}()
+
+	if err := command.Run(); err != nil {
+		return document, nil
+	}
+
+	if newDocument, err := io.ReadAll(filp); err != nil {
+		return "", fmt.Errorf("failed to read edited definition")
+	} else {
+		return string(newDocument), nil
+	}
+}
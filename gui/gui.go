@@ -19,6 +19,9 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/calebstewart/vroomm/config"
+	"github.com/calebstewart/vroomm/disposable"
+	"github.com/calebstewart/vroomm/folders"
+	"github.com/calebstewart/vroomm/hooks"
 	"github.com/calebstewart/vroomm/resources"
 	"github.com/calebstewart/vroomm/virt"
 )
@@ -56,7 +59,14 @@ type Application struct {
 	LogView          *LogView               // A view that displays log entries interactively
 	InfoBar          *gtk.InfoBar           // The info bar displaying the most recent log message
 	ViewLock         sync.Mutex             // A lock for switching views
+	AddressSources   []virt.AddressSource   // Where to look for VM addresses, in order
+	Hooks            *hooks.Runner          // Lifecycle hooks run around VM operations
+	Folders          *folders.Manager       // Folder hierarchy operations
+	Reaper           *disposable.Reaper     // Deletes disposable VMs once they shut off
 	virtConn         *virt.Connection       // Libvirt connection object
+	virtLock         sync.Mutex             // Serializes reconnecting, since Virt is called from any goroutine
+	stopReaper       func()                 // Removes the reaper watch from the previous connection
+	reaperLock       sync.Mutex             // Guards stopReaper
 	*gtk.Application                        // GTK Application
 }
 
@@ -66,6 +76,15 @@ func NewApplication(cfg *config.Config) *Application {
 		Application: gtk.NewApplication(VroommApplicationId, gio.ApplicationFlagsNone),
 		Views:       make([]View, 0),
 		Logger:      logrus.StandardLogger(),
+		Hooks:       hooks.New(cfg),
+	}
+	app.Folders = folders.New(cfg, app.Hooks)
+
+	if sources, err := virt.ParseAddressSources(cfg.AddressSources); err != nil {
+		logrus.WithError(err).Warn("invalid address sources; using defaults")
+		app.AddressSources = virt.DefaultAddressSources
+	} else {
+		app.AddressSources = sources
 	}
 
 	app.ConnectActivate(app.activate)
@@ -74,19 +93,36 @@ func NewApplication(cfg *config.Config) *Application {
 }
 
 func (app *Application) Virt() *virt.Connection {
+	conn, reconnected := app.connect()
+
+	// Watching calls back into Virt, so it happens outside of the lock
+	if reconnected && app.Reaper != nil {
+		app.watchDisposable()
+	}
+
+	return conn
+}
+
+// Return the current connection, connecting again if it died. Also returns
+// whether a previous connection was replaced.
+func (app *Application) connect() (*virt.Connection, bool) {
+	app.virtLock.Lock()
+	defer app.virtLock.Unlock()
+
 	if app.virtConn != nil {
 		if alive, err := app.virtConn.IsAlive(); err == nil && alive {
-			return app.virtConn
+			return app.virtConn, false
 		}
 	}
 
 	if conn, err := virt.New(app.Config.ConnectionString); err != nil {
 		logrus.WithError(err).WithField("connect_uri", app.Config.ConnectionString).Error("failed to connect to libvirt")
 		app.Quit()
-		return nil
+		return nil, false
 	} else {
+		reconnected := app.virtConn != nil
 		app.virtConn = conn
-		return conn
+		return conn, reconnected
 	}
 }
 
@@ -198,6 +234,8 @@ func (app *Application) activate() {
 	mainMenu.Enter(app)
 	app.updateViewTitle()
 
+	app.startReaper()
+
 	app.Window.ShowAll()
 
 	if app.Config.LayerShell.Enabled {
@@ -272,6 +310,16 @@ func (app *Application) updateViewTitle() {
 
 // Handle key presses at the top level
 func (app *Application) keyPressEvent(event *gdk.EventKey) bool {
+	// Mark VMs for bulk actions with Space (Ctrl+Space while typing) and
+	// Ctrl+A in views which support it
+	if view, ok := app.Top().(markableView); ok {
+		if event.Keyval() == gdk.KEY_a && event.State().Has(gdk.ControlMask) && view.MarkAll() {
+			return true
+		} else if event.Keyval() == gdk.KEY_space && (event.State().Has(gdk.ControlMask) || !app.Entry.HasFocus()) && view.ToggleMark() {
+			return true
+		}
+	}
+
 	// Always handle escape to go back
 	if event.Keyval() == gdk.KEY_Escape {
 		if event.State().Has(gdk.ShiftMask) {
@@ -394,6 +442,36 @@ func (app *Application) ActivationWithPulse(message string, activate func(app *A
 	}
 }
 
+// Like ActivationWithPulse, but the activation reports real progress which
+// is shown as a fraction in the entry progress bar.
+func (app *Application) ActivationWithProgress(message string, activate func(app *Application, progress func(current uint64, end uint64)) (string, error)) func() {
+	return func() {
+		app.Logger.Info(message)
+		app.StartProgress()
+
+		go func() {
+			status, err := activate(app, func(current uint64, end uint64) {
+				glib.IdleAdd(func() {
+					if end > 0 {
+						app.Entry.SetProgressFraction(float64(current) / float64(end))
+					} else {
+						app.Entry.ProgressPulse()
+					}
+				})
+			})
+
+			glib.IdleAdd(func() {
+				app.StopProgress()
+				if err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					app.Logger.Info(status)
+				}
+			})
+		}()
+	}
+}
+
 func (app *Application) Activation(activate func(app *Application) (string, error)) func() {
 	return func() {
 		status, err := activate(app)
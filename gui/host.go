@@ -0,0 +1,213 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v3"
+
+	"github.com/calebstewart/vroomm/ring"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	hostIcon = "network-server-symbolic"
+)
+
+// A view showing the hypervisor host's hardware, versions, capabilities and
+// how much of it is committed to domains, along with live CPU usage.
+type HostView struct {
+	Info         *virt.HostInfo        // Host information (nil until loaded)
+	CPU          *ring.Buffer[float64] // Recent host CPU usage in percent
+	FreeMemory   uint64                // Most recently sampled free memory
+	FlowBoxMenu  *FlowboxMenu          // Menu for host related views
+	PropertyView *gtk.ScrolledWindow   // View for host status
+	Cancel       func()                // A method for cancelling the background sampling task
+	lastSample   *virt.HostCPUSample   // Previous CPU sample used to compute usage
+	*gtk.Box                           // Container for above widgets
+}
+
+func NewHostView(app *Application) *HostView {
+	view := &HostView{
+		CPU:          ring.New[float64](app.Config.StatsHistory),
+		FlowBoxMenu:  NewFlowboxMenu("Host"),
+		PropertyView: gtk.NewScrolledWindow(nil, nil),
+		Box:          gtk.NewBox(gtk.OrientationHorizontal, 2),
+	}
+
+	view.Box.PackStart(view.FlowBoxMenu, true, true, 0)
+	view.Box.PackStart(gtk.NewSeparator(gtk.OrientationVertical), false, false, 0)
+	view.Box.PackStart(view.PropertyView, true, true, 0)
+	view.SetName("Host")
+	view.ShowAll()
+
+	return view
+}
+
+func NewHostViewItem(app *Application) *LabelItem {
+	return NewLabelItemWithAction(hostIcon, "Host", func() {
+		app.Push(NewHostView(app))
+	})
+}
+
+func (view *HostView) Name() string {
+	return "Host"
+}
+
+func (view *HostView) Enter(app *Application) error {
+	view.FlowBoxMenu.EmptyItems()
+	view.CreateItem(app, "view-refresh-symbolic", "Reload Host Info", app.Activation(view.reload))
+	view.FlowBoxMenu.Add(NewNetworksViewItem(app))
+	view.FlowBoxMenu.Add(NewHostDevicesViewItem(app))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	view.Cancel = cancel
+
+	view.updateView()
+	go view.loadInfo(app)
+	go view.collectStats(app, ctx)
+
+	return view.FlowBoxMenu.Enter(app)
+}
+
+func (view *HostView) CreateItem(app *Application, icon string, text string, action func()) *LabelItem {
+	item := NewLabelItem(icon, text)
+	item.FlowBoxChild.ConnectActivate(action)
+	view.FlowBoxMenu.Add(item)
+	return item
+}
+
+func (view *HostView) Leave(app *Application) error {
+	view.Cancel()
+	return nil
+}
+
+func (view *HostView) Close(app *Application) error {
+	view.Cancel()
+	return nil
+}
+
+func (view *HostView) Widget() *gtk.Widget {
+	return view.FlowBoxMenu.Widget()
+}
+
+func (view *HostView) Activate(app *Application) {
+	view.FlowBoxMenu.Activate(app)
+}
+
+func (view *HostView) InvalidateFilter() {
+	view.FlowBoxMenu.InvalidateFilter()
+}
+
+func (view *HostView) reload(app *Application) (string, error) {
+	go view.loadInfo(app)
+	return "Reloading host information...", nil
+}
+
+// Query the host information in the background, and update the view from
+// the main loop. Capabilities are too expensive to query on every sample.
+func (view *HostView) loadInfo(app *Application) {
+	info, err := app.Virt().HostInfo()
+	glib.IdleAdd(func() {
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		view.Info = info
+		view.FreeMemory = info.MemoryFree
+		view.updateView()
+	})
+}
+
+// Sample host CPU usage and free memory in the background until the context
+// is cancelled.
+func (view *HostView) collectStats(app *Application, ctx context.Context) {
+	interval := app.Config.StatsInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sample, sampleErr := app.Virt().SampleHostCPU()
+		free, freeErr := app.Virt().GetFreeMemory()
+
+		glib.IdleAdd(func() {
+			if sampleErr == nil {
+				if view.lastSample != nil {
+					view.CPU.Push(sample.Usage(view.lastSample))
+				}
+				view.lastSample = &sample
+			}
+			if freeErr == nil {
+				view.FreeMemory = free
+			}
+			view.updateView()
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (view *HostView) updateView() {
+	grid := gtk.NewGrid()
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+
+	info := view.Info
+	if info == nil {
+		addPropertyRow(grid, 0, "Host:", "loading...")
+	} else {
+		addPropertyRow(grid, 0, "Hostname:", info.Hostname)
+		addPropertyRow(grid, 1, "CPU:", "%v %v, %v CPUs @ %v MHz", info.Arch, info.Model, info.CPUs, info.MHz)
+		addPropertyRow(grid, 2, "Topology:", "%v nodes, %v sockets, %v cores, %v threads", info.Nodes, info.Sockets, info.Cores, info.Threads)
+		addPropertyRow(grid, 3, "Memory:", "%v free of %v", virt.FormatSize(view.FreeMemory), virt.FormatSize(info.MemoryTotal))
+
+		row := 4
+		if usage, ok := view.CPU.Last(); ok {
+			addPropertyRow(grid, row, "CPU Usage:", "%5.1f%% %v", usage, sparkline(view.CPU.Array(), 100))
+			row++
+		}
+
+		addPropertyRow(grid, row, "Hypervisor:", "%v %v (%v)", info.Hypervisor, info.HypervisorVersion, info.VirtType)
+		addPropertyRow(grid, row+1, "Libvirt:", info.LibvirtVersion)
+		addPropertyRow(grid, row+2, "Running VMs:", "%v of %v", info.RunningDomains, info.AllDomains)
+		addPropertyRow(grid, row+3, "vCPUs Committed:", "%v running, %v total (%v)", info.RunningVCPUs, info.AllVCPUs, overcommit(float64(info.AllVCPUs), float64(info.CPUs)))
+		addPropertyRow(grid, row+4, "Memory Committed:", "%v running, %v total (%v)", virt.FormatSize(info.RunningMemory), virt.FormatSize(info.AllMemory), overcommit(float64(info.AllMemory), float64(info.MemoryTotal)))
+		addPropertyRow(grid, row+5, "Firmware:", strings.Join(info.Firmware, ", "))
+		addPropertyRow(grid, row+6, "Machine Types:", strings.Join(info.MachineTypes, ", "))
+		row += 7
+
+		for _, loader := range info.Loaders {
+			addPropertyRow(grid, row, "Loader:", loader)
+			row++
+		}
+	}
+
+	grid.ShowAll()
+
+	if view.PropertyView.Child() != nil {
+		view.PropertyView.Remove(view.PropertyView.Child())
+	}
+	view.PropertyView.Add(grid)
+}
+
+// Describe how far a committed resource exceeds what the host has
+func overcommit(committed float64, available float64) string {
+	if available <= 0 {
+		return "unknown capacity"
+	} else if committed > available {
+		return fmt.Sprintf("%.1fx overcommitted", committed/available)
+	}
+	return fmt.Sprintf("%.0f%% of host", committed/available*100)
+}
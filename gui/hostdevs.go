@@ -0,0 +1,171 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+
+	"github.com/calebstewart/vroomm/set"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	hostDeviceIcon = "drive-removable-media-symbolic"
+)
+
+// A menu listing the USB and PCI devices of the host. When opened for a
+// specific domain, activating a device toggles passing it through to that
+// domain. Otherwise, free devices prompt for a domain to attach to, and
+// held devices are returned to the host.
+type HostDevicesView struct {
+	Domain     *virt.Domain // Domain devices are attached to (may be nil)
+	DomainName string       // Name of the above domain
+	*FlowboxMenu
+}
+
+func NewHostDevicesView(domain *virt.Domain, domainName string) *HostDevicesView {
+	return &HostDevicesView{
+		Domain:      domain,
+		DomainName:  domainName,
+		FlowboxMenu: NewFlowboxMenu("Host Devices"),
+	}
+}
+
+func NewHostDevicesViewItem(app *Application) *LabelItem {
+	return NewLabelItemWithAction(hostDeviceIcon, "Host Devices", func() {
+		app.Push(NewHostDevicesView(nil, ""))
+	})
+}
+
+func (view *HostDevicesView) Enter(app *Application) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	view.EmptyItems()
+	app.PulseProgress(ctx, "Loading host devices...")
+
+	go func() {
+		defer cancel()
+
+		devices, err := app.Virt().EnumerateHostDevices()
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		glib.IdleAdd(func() {
+			for _, device := range devices {
+				view.Add(view.newHostDeviceItem(app, device))
+			}
+			view.InvalidateFilter()
+		})
+	}()
+
+	return view.FlowboxMenu.Enter(app)
+}
+
+func (view *HostDevicesView) newHostDeviceItem(app *Application, device *virt.HostDevice) *LabelItem {
+	icon := hostDeviceIcon
+	if device.Type == "pci" {
+		icon = "media-flash-symbolic"
+	}
+
+	text := fmt.Sprintf("[%v %v] %v %v", device.Type, device.Address, device.Vendor, device.Product)
+	if len(device.Holders) > 0 {
+		text = fmt.Sprintf("%v (held by %v)", text, strings.Join(device.Holders, ", "))
+	}
+
+	return NewLabelItemWithAction(icon, text, app.Activation(func(app *Application) (string, error) {
+		if view.Domain == nil {
+			return view.toggleAnyDomain(app, device)
+		}
+
+		held := set.New(device.Holders...)
+		if held.Has(view.DomainName) {
+			return view.detach(app, view.Domain, view.DomainName, device)
+		} else if len(device.Holders) > 0 {
+			return "", fmt.Errorf("Device '%v' is held by %v", device.Name, strings.Join(device.Holders, ", "))
+		} else {
+			return view.attach(app, view.Domain, view.DomainName, device)
+		}
+	}))
+}
+
+// Return a held device to the host, or prompt for a domain to attach a free
+// device to.
+func (view *HostDevicesView) toggleAnyDomain(app *Application, device *virt.HostDevice) (string, error) {
+	if len(device.Holders) > 0 {
+		for _, holder := range device.Holders {
+			if domain, err := app.Virt().LookupDomain(holder); err != nil {
+				return "", err
+			} else if status, err := view.detach(app, domain, holder, device); err != nil {
+				return "", err
+			} else {
+				app.Logger.Info(status)
+			}
+		}
+		return "", nil
+	}
+
+	domains, err := app.Virt().EnumerateAllDomains()
+	if err != nil {
+		return "", err
+	}
+
+	items := []*LabelItem{}
+	for _, domain := range domains {
+		if name, err := domain.GetName(); err == nil {
+			items = append(items, NewLabelItem("computer-symbolic", name))
+		}
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Attach Device",
+			"VM>",
+			true,
+			func(app *Application, name string) {
+				app.Pop()
+
+				if domain, err := app.Virt().LookupDomain(name); err != nil {
+					app.Logger.Error(err.Error())
+				} else if status, err := view.attach(app, domain, name, device); err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					app.Logger.Info(status)
+				}
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
+
+func (view *HostDevicesView) attach(app *Application, domain *virt.Domain, name string, device *virt.HostDevice) (string, error) {
+	if err := domain.AttachHostDevice(device); err != nil {
+		return "", err
+	}
+
+	view.Enter(app)
+	return fmt.Sprintf("Attached '%v %v' to '%v'", device.Vendor, device.Product, name), nil
+}
+
+func (view *HostDevicesView) detach(app *Application, domain *virt.Domain, name string, device *virt.HostDevice) (string, error) {
+	if err := domain.DetachHostDevice(device); err != nil {
+		return "", err
+	}
+
+	view.Enter(app)
+	return fmt.Sprintf("Detached '%v %v' from '%v'", device.Vendor, device.Product, name), nil
+}
+
+func (view *HostDevicesView) Leave(app *Application) error {
+	return nil
+}
+
+func (view *HostDevicesView) Close(app *Application) error {
+	return nil
+}
@@ -2,10 +2,15 @@ package gui
 
 import (
 	"context"
+	"fmt"
+	"sort"
 
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v3"
 	"github.com/lithammer/fuzzysearch/fuzzy"
+
+	"github.com/calebstewart/vroomm/set"
+	"github.com/calebstewart/vroomm/virt"
 )
 
 type named interface {
@@ -23,6 +28,7 @@ type Item interface {
 type FlowboxMenu struct {
 	name    string
 	items   map[string]Item
+	marked  set.Set[string]
 	FlowBox *gtk.FlowBox
 	*gtk.ScrolledWindow
 }
@@ -33,6 +39,7 @@ func NewFlowboxMenu(name string) *FlowboxMenu {
 		FlowBox:        gtk.NewFlowBox(),
 		ScrolledWindow: gtk.NewScrolledWindow(nil, nil),
 		items:          map[string]Item{},
+		marked:         set.New[string](),
 	}
 
 	menu.FlowBox.CastType(gtk.GTypeOrientable).(*gtk.Orientable).SetOrientation(gtk.OrientationHorizontal)
@@ -48,7 +55,14 @@ func NewFlowboxMenu(name string) *FlowboxMenu {
 	return menu
 }
 
+// Activate the selected item, or open the bulk action view if any VMs are
+// marked.
 func (menu *FlowboxMenu) Activate(app *Application) {
+	if domains := menu.Marked(); len(domains) > 0 {
+		app.Push(NewBulkView(fmt.Sprintf("%v Selected VMs", len(domains)), domains))
+		return
+	}
+
 	children := menu.FlowBox.SelectedChildren()
 	if len(children) == 0 {
 		children = []*gtk.FlowBoxChild{menu.FlowBox.ChildAtIndex(0)}
@@ -111,19 +125,104 @@ func (menu *FlowboxMenu) Add(item Item) {
 
 func (menu *FlowboxMenu) EmptyItems() {
 	menu.items = map[string]Item{}
+	menu.marked = set.New[string]()
 	for _, child := range menu.FlowBox.Children() {
 		menu.FlowBox.Remove(child)
 	}
 }
 
+// Items which can be marked for bulk actions
+type markableItem interface {
+	SetMarked(marked bool)
+	Domain() *virt.Domain
+}
+
+// Views which allow marking items for bulk actions
+type markableView interface {
+	ToggleMark() bool
+	MarkAll() bool
+}
+
+// Toggle the mark on the selected item. Returns false if the item cannot be
+// marked.
+func (menu *FlowboxMenu) ToggleMark() bool {
+	children := menu.FlowBox.SelectedChildren()
+	if len(children) == 0 {
+		return false
+	}
+
+	name := children[0].Name()
+	item, ok := menu.items[name].(markableItem)
+	if !ok {
+		return false
+	}
+
+	if menu.marked.Has(name) {
+		menu.marked.Remove(name)
+		item.SetMarked(false)
+	} else {
+		menu.marked.Add(name)
+		item.SetMarked(true)
+	}
+
+	return true
+}
+
+// Mark every visible item which can be marked, or clear the marks if they
+// are all marked already. Returns false if there is nothing to mark.
+func (menu *FlowboxMenu) MarkAll() bool {
+	visible := []string{}
+	for name, item := range menu.items {
+		if _, ok := item.(markableItem); ok && gtk.BaseWidget(item).ChildVisible() {
+			visible = append(visible, name)
+		}
+	}
+
+	if len(visible) == 0 {
+		return false
+	}
+
+	allMarked := true
+	for _, name := range visible {
+		allMarked = allMarked && menu.marked.Has(name)
+	}
+
+	for _, name := range visible {
+		if allMarked {
+			menu.marked.Remove(name)
+		} else {
+			menu.marked.Add(name)
+		}
+		menu.items[name].(markableItem).SetMarked(!allMarked)
+	}
+
+	return true
+}
+
+// Return the domains of all marked items, ordered by name
+func (menu *FlowboxMenu) Marked() []*virt.Domain {
+	names := menu.marked.Array()
+	sort.Strings(names)
+
+	domains := []*virt.Domain{}
+	for _, name := range names {
+		if item, ok := menu.items[name].(markableItem); ok {
+			domains = append(domains, item.Domain())
+		}
+	}
+	return domains
+}
+
 type LabelItem struct {
-	Text string
+	Text  string
+	label *gtk.Label
 	*gtk.FlowBoxChild
 }
 
 func NewLabelItem(iconName string, text string) *LabelItem {
 	item := &LabelItem{
 		Text:         text,
+		label:        gtk.NewLabel(text),
 		FlowBoxChild: gtk.NewFlowBoxChild(),
 	}
 
@@ -132,9 +231,8 @@ func NewLabelItem(iconName string, text string) *LabelItem {
 	icon := gtk.NewImageFromIconName(iconName, int(gtk.IconSizeLargeToolbar))
 	box.PackStart(icon, false, false, 0)
 
-	label := gtk.NewLabel(text)
-	label.SetHAlign(gtk.AlignStart)
-	box.PackStart(label, true, true, 0)
+	item.label.SetHAlign(gtk.AlignStart)
+	box.PackStart(item.label, true, true, 0)
 
 	item.Add(box)
 	item.SetHExpand(true)
@@ -178,6 +276,18 @@ func (menu *MainMenu) Enter(app *Application) error {
 	menu.Add(NewBrowseAllItem(app))
 	menu.Add(NewBrowseFolderItem(app, "/", ""))
 	menu.Add(NewLabelsViewItem(app))
+	menu.Add(NewTemplatesViewItem(app))
+	for _, folder := range app.Config.SmartFolders {
+		if item, err := NewSmartFolderItem(app, folder); err != nil {
+			app.Logger.Errorf("Invalid smart folder '%v': %v", folder.Name, err)
+		} else {
+			menu.Add(item)
+		}
+	}
+	menu.Add(NewHostViewItem(app))
+	menu.Add(NewNetworksViewItem(app))
+	menu.Add(NewHostDevicesViewItem(app))
+	menu.Add(NewGarbageViewItem(app))
 
 	go func() {
 		virtConn := app.Virt()
@@ -204,6 +314,14 @@ func (menu *MainMenu) Enter(app *Application) error {
 	return menu.FlowboxMenu.Enter(app)
 }
 
+// Run the entry text as a query if it starts with the query prefix, and
+// otherwise activate the selected item.
+func (menu *MainMenu) Activate(app *Application) {
+	if !runEntryQuery(app, app.Entry.Text()) {
+		menu.FlowboxMenu.Activate(app)
+	}
+}
+
 func (menu *MainMenu) Close(app *Application) error {
 	return nil
 }
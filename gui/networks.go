@@ -0,0 +1,285 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v3"
+	"libvirt.org/go/libvirt"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	networkIcon = "network-workgroup-symbolic"
+)
+
+// A menu listing all libvirt networks
+type NetworksView struct {
+	*FlowboxMenu
+}
+
+func NewNetworksView() *NetworksView {
+	return &NetworksView{
+		FlowboxMenu: NewFlowboxMenu("Networks"),
+	}
+}
+
+func NewNetworksViewItem(app *Application) *LabelItem {
+	return NewLabelItemWithAction(networkIcon, "Networks", func() {
+		app.Push(NewNetworksView())
+	})
+}
+
+func (view *NetworksView) Enter(app *Application) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	view.EmptyItems()
+	app.PulseProgress(ctx, "Loading networks...")
+
+	go func() {
+		defer cancel()
+
+		networks, err := app.Virt().EnumerateNetworks()
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		glib.IdleAdd(func() {
+			for _, network := range networks {
+				if item, err := NewNetworkItem(app, network); err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					view.Add(item)
+				}
+			}
+			view.InvalidateFilter()
+		})
+	}()
+
+	return view.FlowboxMenu.Enter(app)
+}
+
+func (view *NetworksView) Leave(app *Application) error {
+	return nil
+}
+
+func (view *NetworksView) Close(app *Application) error {
+	return nil
+}
+
+func NewNetworkItem(app *Application, network *virt.Network) (*LabelItem, error) {
+	name, err := network.GetName()
+	if err != nil {
+		return nil, err
+	}
+
+	icon := networkIcon
+	if active, err := network.IsActive(); err == nil && !active {
+		icon = "network-offline-symbolic"
+	}
+
+	return NewLabelItemWithAction(icon, name, func() {
+		if view, err := NewNetworkView(app, network); err != nil {
+			app.Logger.Error(err)
+		} else {
+			app.Push(view)
+		}
+	}), nil
+}
+
+// A view showing the state, address ranges and DHCP leases of a network
+// with actions to manage it.
+type NetworkView struct {
+	Network      *virt.Network       // The network we are interacting with
+	NetworkName  string              // Name of the network
+	FlowBoxMenu  *FlowboxMenu        // Menu for interactions with the network
+	PropertyView *gtk.ScrolledWindow // View for network status
+	*gtk.Box                         // Container for above widgets
+}
+
+func NewNetworkView(app *Application, network *virt.Network) (*NetworkView, error) {
+	name, err := network.GetName()
+	if err != nil {
+		return nil, err
+	}
+
+	view := &NetworkView{
+		Network:      network,
+		NetworkName:  name,
+		FlowBoxMenu:  NewFlowboxMenu(name),
+		PropertyView: gtk.NewScrolledWindow(nil, nil),
+		Box:          gtk.NewBox(gtk.OrientationHorizontal, 2),
+	}
+
+	view.Box.PackStart(view.FlowBoxMenu, true, true, 0)
+	view.Box.PackStart(gtk.NewSeparator(gtk.OrientationVertical), false, false, 0)
+	view.Box.PackStart(view.PropertyView, true, true, 0)
+	view.SetName(view.NetworkName)
+	view.ShowAll()
+
+	return view, nil
+}
+
+func (view *NetworkView) Name() string {
+	return view.NetworkName
+}
+
+func (view *NetworkView) updateView(app *Application) error {
+	owners, err := app.Virt().DomainsByMAC()
+	if err != nil {
+		return err
+	}
+
+	info, err := view.Network.Info(owners)
+	if err != nil {
+		return err
+	}
+
+	view.FlowBoxMenu.EmptyItems()
+
+	if info.Active {
+		view.CreateItem(app, "media-playback-stop-symbolic", "Stop", app.Activation(view.stop))
+	} else {
+		view.CreateItem(app, "media-playback-start-symbolic", "Start", app.Activation(view.start))
+	}
+
+	if info.Autostart {
+		view.CreateItem(app, "system-run-symbolic", "Disable Autostart", app.Activation(view.toggleAutostart))
+	} else {
+		view.CreateItem(app, "system-run-symbolic", "Enable Autostart", app.Activation(view.toggleAutostart))
+	}
+
+	view.CreateItem(app, "document-edit-symbolic", "Edit XML", app.ActivationWithPulse("Opening network XML w/ xdg-open...", view.editXML))
+
+	state := "Inactive"
+	if info.Active {
+		state = "Active"
+	}
+
+	grid := gtk.NewGrid()
+	grid.SetHExpand(true)
+	grid.SetVExpand(true)
+	addPropertyRow(grid, 0, "Name:", info.Name)
+	addPropertyRow(grid, 1, "State:", state)
+	addPropertyRow(grid, 2, "Autostart:", "%v", info.Autostart)
+	addPropertyRow(grid, 3, "Forward:", info.Forward)
+	addPropertyRow(grid, 4, "Bridge:", info.Bridge)
+
+	row := 5
+	for _, subnet := range info.Ranges {
+		addPropertyRow(grid, row, "Subnet:", subnet)
+		row++
+	}
+
+	for _, lease := range info.Leases {
+		owner := lease.Domain
+		if owner == "" {
+			owner = lease.Hostname
+		}
+		addPropertyRow(grid, row, fmt.Sprintf("Lease %v:", lease.Address), "%v %v (expires %v)", lease.MAC, owner, lease.Expiry.Format("15:04:05"))
+		row++
+	}
+
+	grid.ShowAll()
+
+	if view.PropertyView.Child() != nil {
+		view.PropertyView.Remove(view.PropertyView.Child())
+	}
+	view.PropertyView.Add(grid)
+
+	return nil
+}
+
+// Refresh the view from the main loop after an action completes
+func (view *NetworkView) refresh(app *Application) {
+	glib.IdleAdd(func() {
+		if err := view.updateView(app); err != nil {
+			app.Logger.Error(err.Error())
+		}
+	})
+}
+
+func (view *NetworkView) Enter(app *Application) error {
+	if err := view.updateView(app); err != nil {
+		return err
+	}
+
+	return view.FlowBoxMenu.Enter(app)
+}
+
+func (view *NetworkView) CreateItem(app *Application, icon string, text string, action func()) *LabelItem {
+	item := NewLabelItem(icon, text)
+	item.FlowBoxChild.ConnectActivate(action)
+	view.FlowBoxMenu.Add(item)
+	return item
+}
+
+func (view *NetworkView) Leave(app *Application) error {
+	return nil
+}
+
+func (view *NetworkView) Close(app *Application) error {
+	return nil
+}
+
+func (view *NetworkView) Widget() *gtk.Widget {
+	return view.FlowBoxMenu.Widget()
+}
+
+func (view *NetworkView) Activate(app *Application) {
+	view.FlowBoxMenu.Activate(app)
+}
+
+func (view *NetworkView) InvalidateFilter() {
+	view.FlowBoxMenu.InvalidateFilter()
+}
+
+func (view *NetworkView) start(app *Application) (string, error) {
+	defer view.refresh(app)
+	return fmt.Sprintf("Network '%v' Started", view.NetworkName), view.Network.Create()
+}
+
+func (view *NetworkView) stop(app *Application) (string, error) {
+	defer view.refresh(app)
+	return fmt.Sprintf("Network '%v' Stopped", view.NetworkName), view.Network.Destroy()
+}
+
+func (view *NetworkView) toggleAutostart(app *Application) (string, error) {
+	defer view.refresh(app)
+
+	autostart, err := view.Network.GetAutostart()
+	if err != nil {
+		return "", err
+	} else if err := view.Network.SetAutostart(!autostart); err != nil {
+		return "", err
+	} else if autostart {
+		return fmt.Sprintf("Disabled autostart for network '%v'", view.NetworkName), nil
+	} else {
+		return fmt.Sprintf("Enabled autostart for network '%v'", view.NetworkName), nil
+	}
+}
+
+func (view *NetworkView) editXML(app *Application) (string, error) {
+	netXml, err := view.Network.GetXMLDesc(libvirt.NETWORK_XML_INACTIVE)
+	if err != nil {
+		return "", err
+	}
+
+	if newNetXml, err := editDocument(app, "vroomm-network.*.xml", netXml); err != nil {
+		return "", err
+	} else if strings.TrimSpace(newNetXml) == strings.TrimSpace(netXml) {
+		return "Network XML Unchanged", nil
+	} else if network, err := app.Virt().NetworkDefineXML(newNetXml); err != nil {
+		return "", err
+	} else if viewNetwork, err := virt.NewNetwork(*network); err != nil {
+		return "", err
+	} else {
+		view.Network = viewNetwork
+		view.refresh(app)
+		return "Updated Network XML Definition (restart the network to apply)", nil
+	}
+}
@@ -0,0 +1,107 @@
+package gui
+
+import (
+	"context"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+
+	"github.com/calebstewart/vroomm/config"
+	"github.com/calebstewart/vroomm/query"
+)
+
+const (
+	smartFolderIcon = "folder-saved-search-symbolic"
+
+	// Entry text starting with this prefix is run as a query on the main menu
+	queryPrefix = "?"
+)
+
+// A menu showing all VMs matching a query
+type QueryView struct {
+	Query *query.Query
+	*FlowboxMenu
+}
+
+func NewQueryView(name string, q *query.Query) *QueryView {
+	return &QueryView{
+		Query:       q,
+		FlowboxMenu: NewFlowboxMenu(name),
+	}
+}
+
+func NewSmartFolderItem(app *Application, folder config.SmartFolder) (*LabelItem, error) {
+	q, err := query.Parse(folder.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	icon := folder.Icon
+	if icon == "" {
+		icon = smartFolderIcon
+	}
+
+	return NewLabelItemWithAction(icon, folder.Name, func() {
+		app.Push(NewQueryView(folder.Name, q))
+	}), nil
+}
+
+// Run the query typed after the query prefix, if any. Returns false if the
+// text is not a query.
+func runEntryQuery(app *Application, text string) bool {
+	if !strings.HasPrefix(text, queryPrefix) {
+		return false
+	}
+
+	if q, err := query.Parse(strings.TrimPrefix(text, queryPrefix)); err != nil {
+		app.Logger.Error(err.Error())
+	} else {
+		app.Push(NewQueryView(text, q))
+	}
+
+	return true
+}
+
+func (view *QueryView) Enter(app *Application) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	view.EmptyItems()
+	app.PulseProgress(ctx, "Running query...")
+
+	go func() {
+		defer cancel()
+
+		domains, err := app.Virt().EnumerateAllDomains()
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		matches, err := view.Query.Filter(domains)
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		glib.IdleAdd(func() {
+			for _, domain := range matches {
+				if item, err := NewVirtualMachineItem(app, domain); err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					view.Add(item)
+				}
+			}
+			view.InvalidateFilter()
+		})
+	}()
+
+	return view.FlowboxMenu.Enter(app)
+}
+
+func (view *QueryView) Leave(app *Application) error {
+	return nil
+}
+
+func (view *QueryView) Close(app *Application) error {
+	return nil
+}
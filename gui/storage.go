@@ -0,0 +1,121 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	garbageIcon = "user-trash-full-symbolic"
+)
+
+// A menu listing orphaned volumes and linked clones with a missing
+// backing file, with actions to clean them up.
+type GarbageView struct {
+	*FlowboxMenu
+}
+
+func NewGarbageView() *GarbageView {
+	return &GarbageView{
+		FlowboxMenu: NewFlowboxMenu("Storage Cleanup"),
+	}
+}
+
+func NewGarbageViewItem(app *Application) *LabelItem {
+	return NewLabelItemWithAction(garbageIcon, "Storage Cleanup", func() {
+		app.Push(NewGarbageView())
+	})
+}
+
+func (view *GarbageView) Enter(app *Application) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	view.EmptyItems()
+	app.PulseProgress(ctx, "Scanning storage pools...")
+
+	go func() {
+		defer cancel()
+
+		report, err := app.Virt().FindGarbage()
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		glib.IdleAdd(func() {
+			view.populate(app, report)
+			app.Logger.Infof("Found %v unused volumes (%v reclaimable)", len(report.Volumes), virt.FormatSize(report.Reclaimable()))
+		})
+	}()
+
+	return view.FlowboxMenu.Enter(app)
+}
+
+func (view *GarbageView) populate(app *Application, report *virt.GarbageReport) {
+	if len(report.Volumes) == 0 {
+		return
+	}
+
+	view.Add(NewLabelItemWithAction(
+		"edit-delete-symbolic",
+		fmt.Sprintf("Remove All (%v)", virt.FormatSize(report.Reclaimable())),
+		app.ActivationWithPulse("Removing unused volumes...", func(app *Application) (string, error) {
+			removed, err := app.Virt().CleanupGarbage(report, false)
+			glib.IdleAdd(func() {
+				view.Enter(app)
+			})
+			return fmt.Sprintf("Removed %v unused volumes", len(removed)), err
+		}),
+	))
+
+	view.Add(NewLabelItemWithAction(
+		"edit-find-symbolic",
+		"Remove All (Dry Run)",
+		app.Activation(func(app *Application) (string, error) {
+			removed, err := app.Virt().CleanupGarbage(report, true)
+			for _, volume := range removed {
+				app.Logger.Infof("Would remove %v (%v)", volume.Path, virt.FormatSize(volume.Allocation))
+			}
+			return fmt.Sprintf("Would remove %v unused volumes", len(removed)), err
+		}),
+	))
+
+	for idx := range report.Volumes {
+		volume := report.Volumes[idx]
+
+		text := fmt.Sprintf("%v/%v (%v, %v)", volume.Pool, volume.Name, volume.Reason, virt.FormatSize(volume.Allocation))
+		if !volume.Removable() {
+			text = fmt.Sprintf("%v [used by %v]", text, strings.Join(volume.UsedBy(), ", "))
+		}
+
+		view.Add(NewLabelItemWithAction(
+			"drive-harddisk-symbolic",
+			text,
+			app.Activation(func(app *Application) (string, error) {
+				if !volume.Removable() {
+					return "", fmt.Errorf("Volume '%v' is still used by %v", volume.Path, strings.Join(volume.UsedBy(), ", "))
+				} else if err := app.Virt().DeleteVolume(volume.Path); err != nil {
+					return "", err
+				}
+
+				view.Enter(app)
+				return fmt.Sprintf("Removed volume '%v'", volume.Path), nil
+			}),
+		))
+	}
+
+	view.InvalidateFilter()
+}
+
+func (view *GarbageView) Leave(app *Application) error {
+	return nil
+}
+
+func (view *GarbageView) Close(app *Application) error {
+	return nil
+}
@@ -0,0 +1,180 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+
+	"github.com/calebstewart/vroomm/cloudinit"
+	"github.com/calebstewart/vroomm/templates"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	templateIcon = "document-new-symbolic"
+
+	noLabelsItem = "No labels"
+	createItem   = "Create"
+	startItem    = "Create and Start"
+)
+
+// A menu listing every template
+type TemplatesView struct {
+	*FlowboxMenu
+}
+
+func NewTemplatesViewItem(app *Application) *LabelItem {
+	return NewLabelItemWithAction(templateIcon, "Templates", func() {
+		app.Push(&TemplatesView{
+			FlowboxMenu: NewFlowboxMenu("Templates"),
+		})
+	})
+}
+
+func (view *TemplatesView) Enter(app *Application) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	view.EmptyItems()
+	app.PulseProgress(ctx, "Loading templates...")
+
+	go func() {
+		defer cancel()
+
+		domains, err := templates.List(app.Virt())
+		if err != nil {
+			app.Logger.Error(err.Error())
+			return
+		}
+
+		glib.IdleAdd(func() {
+			for _, domain := range domains {
+				if item, err := NewVirtualMachineItem(app, domain); err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					view.Add(item)
+				}
+			}
+			view.InvalidateFilter()
+		})
+	}()
+
+	return view.FlowboxMenu.Enter(app)
+}
+
+func (view *TemplatesView) Leave(app *Application) error {
+	return nil
+}
+
+func (view *TemplatesView) Close(app *Application) error {
+	return nil
+}
+
+func (view *VirtualMachineView) markTemplate(app *Application) (string, error) {
+	if err := view.Domain.SetTemplate(true); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("'%v' is now a template", view.DomainName), nil
+}
+
+func (view *VirtualMachineView) unmarkTemplate(app *Application) (string, error) {
+	if err := view.Domain.SetTemplate(false); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("'%v' is no longer a template", view.DomainName), nil
+}
+
+// Walk through the settings of a new VM (name, clone type, folder, labels
+// and cloud-init seed), then create it in the background. Each step replaces the
+// previous prompt, so the last one returns straight to the template.
+func (view *VirtualMachineView) newFromTemplate(app *Application) (string, error) {
+	instance := templates.Instance{}
+	metadata := view.Domain.GetVmmData()
+
+	finish := func(app *Application, entry string) {
+		instance.Start = entry == startItem
+		app.Pop()
+
+		app.ActivationWithPulse(fmt.Sprintf("Creating '%v' from template...", instance.Name), func(app *Application) (string, error) {
+			builder := templates.New(app.Config, app.Virt(), app.Hooks)
+			domain, err := builder.Create(view.Domain, instance)
+			if domain != nil {
+				if domainView, viewErr := NewVirtualMachineView(app, domain); viewErr == nil {
+					glib.IdleAdd(func() {
+						app.Push(domainView)
+					})
+				}
+			}
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Created '%v' from template '%v'", instance.Name, view.DomainName), nil
+		})()
+	}
+
+	labels := func(app *Application, entry string) {
+		instance.Labels = []string{}
+		if entry != noLabelsItem {
+			for _, label := range strings.Split(entry, ",") {
+				if label = strings.TrimSpace(label); label != "" {
+					instance.Labels = append(instance.Labels, label)
+				}
+			}
+		}
+		app.ReplaceTop(newSeedPrompt(app, instance.Name, func(app *Application, vars *cloudinit.Variables) {
+			instance.Seed = vars
+			app.ReplaceTop(NewPrompt(app, "Finish", "Create>", true, finish,
+				NewLabelItem(templateIcon, createItem),
+				NewLabelItem("media-playback-start-symbolic", startItem),
+			))
+		}))
+	}
+
+	folder := func(app *Application, entry string) {
+		items := []*LabelItem{NewLabelItem(labelIcon, noLabelsItem)}
+		if len(metadata.Labels) > 0 {
+			items = append(items, NewLabelItem(labelIcon, strings.Join(metadata.Labels, ", ")))
+		}
+
+		instance.Folder = entry
+		app.ReplaceTop(NewPrompt(app, "Labels (comma separated)", "Labels>", false, labels, items...))
+	}
+
+	cloneType := func(app *Application, entry string) {
+		instance.Linked = entry == "Linked Clone"
+
+		items := []*LabelItem{NewLabelItem(folderIcon, metadata.Path)}
+		if allFolders, err := app.Folders.List(app.Virt()); err == nil {
+			for _, existing := range allFolders {
+				if existing != metadata.Path {
+					items = append(items, NewLabelItem(folderIcon, existing))
+				}
+			}
+		}
+		app.ReplaceTop(NewPrompt(app, "Folder", "Path>", false, folder, items...))
+	}
+
+	app.Push(NewPrompt(app, fmt.Sprintf("New from '%v'", view.DomainName), "VM Name>", false, func(app *Application, name string) {
+		if _, err := app.Virt().LookupDomainByName(name); err == nil {
+			app.Logger.Errorf("Virtual Machine '%v' already exists", name)
+			return
+		}
+
+		instance.Name = name
+		app.ReplaceTop(NewPrompt(app, "Clone Type", "Clone>", true, cloneType,
+			NewLabelItem("edit-copy-symbolic", "Linked Clone"),
+			NewLabelItem("edit-copy-symbolic", "Full Clone"),
+		))
+	}))
+
+	return "", nil
+}
+
+// The icon of a VM item
+func domainIcon(domain *virt.Domain) string {
+	if domain.IsTemplate() {
+		return templateIcon
+	}
+	return "computer-symbolic"
+}
@@ -0,0 +1,242 @@
+package gui
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/calebstewart/vroomm/set"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var (
+	commandPlaceholder = regexp.MustCompile(`\{[a-z_]+\}`)
+)
+
+// Return the viewers available for the domain in order of preference: the
+// ones picked in the VM metadata, then the defaults for the guest OS family,
+// then every other configured viewer.
+func (view *VirtualMachineView) viewerNames(app *Application) []string {
+	names := []string{}
+	seen := set.New[string]()
+
+	add := func(candidates ...string) {
+		for _, name := range candidates {
+			if _, ok := app.Config.Viewers[name]; ok && !seen.Has(name) {
+				names = append(names, name)
+				seen.Add(name)
+			}
+		}
+	}
+
+	add(view.Domain.GetVmmData().Viewers...)
+	add(app.Config.OSViewers[view.Domain.OSFamily()]...)
+	add(app.Config.OSViewers[virt.OSFamilyUnknown]...)
+
+	others := []string{}
+	for name := range app.Config.Viewers {
+		others = append(others, name)
+	}
+	sort.Strings(others)
+	add(others...)
+
+	return names
+}
+
+// Expand the placeholders of a viewer or action command template. Values
+// are only resolved when the template uses them, so a VNC viewer doesn't fail
+// on a guest without network addresses.
+func (view *VirtualMachineView) expandCommand(app *Application, template []string) ([]string, error) {
+	resolvers := map[string]func() (string, error){
+		"{uuid}": view.Domain.GetUUIDString,
+		"{name}": func() (string, error) { return view.DomainName, nil },
+		"{uri}":  func() (string, error) { return app.Config.ConnectionString, nil },
+		"{host}": func() (string, error) {
+			if uri, err := url.Parse(app.Config.ConnectionString); err == nil && uri.Hostname() != "" {
+				return uri.Hostname(), nil
+			}
+			return "localhost", nil
+		},
+		"{ip}": func() (string, error) {
+			addresses, err := view.Domain.ResolveAddresses(app.AddressSources)
+			if err != nil {
+				return "", err
+			}
+			for _, address := range addresses {
+				if !strings.Contains(address.Address, ":") {
+					return address.Address, nil
+				}
+			}
+			if len(addresses) > 0 {
+				return addresses[0].Address, nil
+			}
+			return "", fmt.Errorf("no known addresses for '%v'", view.DomainName)
+		},
+		"{spice_port}": func() (string, error) {
+			port, err := view.Domain.GraphicsPort("spice")
+			return strconv.Itoa(port), err
+		},
+		"{vnc_port}": func() (string, error) {
+			port, err := view.Domain.GraphicsPort("vnc")
+			return strconv.Itoa(port), err
+		},
+		"{shm}": func() (string, error) {
+			width, height, _ := view.lookingGlassSettings(app)
+			if description, err := view.Domain.GetDescription(0); err != nil {
+				return "", err
+			} else if status := virt.InspectLookingGlass(description, width, height); !status.Ready() {
+				return "", fmt.Errorf("Looking Glass is not usable: %v (use 'Setup Looking Glass')", status)
+			} else {
+				return status.Path(), nil
+			}
+		},
+	}
+
+	values := map[string]string{}
+	resolve := func(placeholder string) (string, error) {
+		if value, ok := values[placeholder]; ok {
+			return value, nil
+		} else if resolver, ok := resolvers[placeholder]; !ok {
+			return "", fmt.Errorf("unknown command placeholder '%v'", placeholder)
+		} else if value, err := resolver(); err != nil {
+			return "", err
+		} else {
+			values[placeholder] = value
+			return value, nil
+		}
+	}
+
+	command := []string{}
+	for _, arg := range template {
+		// Expands to any number of arguments, so it must stand alone
+		if arg == "{lg_args}" {
+			_, _, args := view.lookingGlassSettings(app)
+			command = append(command, args...)
+			continue
+		}
+
+		var resolveErr error
+		expanded := commandPlaceholder.ReplaceAllStringFunc(arg, func(placeholder string) string {
+			value, err := resolve(placeholder)
+			if err != nil && resolveErr == nil {
+				resolveErr = err
+			}
+			return value
+		})
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+
+		command = append(command, expanded)
+	}
+
+	if len(command) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	return command, nil
+}
+
+func (view *VirtualMachineView) launchViewer(app *Application, name string) (string, error) {
+	template, ok := app.Config.Viewers[name]
+	if !ok {
+		return "", fmt.Errorf("unknown viewer '%v'", name)
+	}
+
+	args, err := view.expandCommand(app, template)
+	if err != nil {
+		return "", err
+	}
+
+	command := exec.Command(args[0], args[1:]...)
+
+	// Ensure we have no stdio
+	command.Stderr = nil
+	command.Stdout = nil
+	command.Stdin = nil
+
+	if err := command.Start(); err != nil {
+		return "", err
+	}
+
+	// We should exit since we just spawned an interactive application
+	app.Quit()
+
+	return fmt.Sprintf("Started %v", name), nil
+}
+
+func (view *VirtualMachineView) viewerItems(app *Application) []*LabelItem {
+	items := []*LabelItem{}
+	for _, name := range view.viewerNames(app) {
+		items = append(items, NewLabelItem("computer-symbolic", name))
+	}
+	return items
+}
+
+func (view *VirtualMachineView) openViewer(app *Application) (string, error) {
+	if names := view.viewerNames(app); len(names) == 0 {
+		return "", fmt.Errorf("no viewers configured")
+	} else {
+		return view.launchViewer(app, names[0])
+	}
+}
+
+func (view *VirtualMachineView) openViewerWith(app *Application) (string, error) {
+	app.Push(
+		NewPrompt(
+			app,
+			"Open With",
+			"Viewer>",
+			true,
+			func(app *Application, name string) {
+				app.Pop()
+
+				if status, err := view.launchViewer(app, name); err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					app.Logger.Info(status)
+				}
+			},
+			view.viewerItems(app)...,
+		),
+	)
+
+	return "", nil
+}
+
+// Move the chosen viewer to the front of the VM's preferred viewers
+func (view *VirtualMachineView) setDefaultViewer(app *Application) (string, error) {
+	app.Push(
+		NewPrompt(
+			app,
+			"Default Viewer",
+			"Viewer>",
+			true,
+			func(app *Application, name string) {
+				app.Pop()
+
+				metadata := view.Domain.GetVmmData()
+				viewers := []string{name}
+				for _, viewer := range metadata.Viewers {
+					if viewer != name {
+						viewers = append(viewers, viewer)
+					}
+				}
+				metadata.Viewers = viewers
+
+				if err := view.Domain.UpdateVmmData(metadata); err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					app.Logger.Infof("Default viewer for '%v' is now %v", view.DomainName, name)
+				}
+			},
+			view.viewerItems(app)...,
+		),
+	)
+
+	return "", nil
+}
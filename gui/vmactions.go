@@ -0,0 +1,106 @@
+package gui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/calebstewart/vroomm/config"
+)
+
+// Add menu items for the configured custom actions which apply to the VM
+func (view *VirtualMachineView) addCustomActions(app *Application, state string) {
+	metadata := view.Domain.GetVmmData()
+
+	for idx := range app.Config.Actions {
+		action := &app.Config.Actions[idx]
+		if !action.Matches(metadata.Path, metadata.Labels, state) {
+			continue
+		}
+
+		icon := action.Icon
+		if icon == "" {
+			icon = "system-run-symbolic"
+		}
+
+		view.CreateItem(app, icon, action.Name, app.ActivationWithPulse(fmt.Sprintf("Starting %v...", action.Name), func(app *Application) (string, error) {
+			return view.runCustomAction(app, action)
+		}))
+	}
+}
+
+// Run a custom action in the background. The domain details are passed in
+// the environment, and the output is streamed to the log view.
+func (view *VirtualMachineView) runCustomAction(app *Application, action *config.Action) (string, error) {
+	args, err := view.expandCommand(app, action.Command)
+	if err != nil {
+		return "", err
+	}
+
+	uuid, err := view.Domain.GetUUIDString()
+	if err != nil {
+		return "", err
+	}
+
+	ip := ""
+	if addresses, err := view.Domain.ResolveAddresses(app.AddressSources); err == nil && len(addresses) > 0 {
+		ip = addresses[0].Address
+	}
+
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = nil
+	command.Env = append(
+		os.Environ(),
+		"VROOMM_NAME="+view.DomainName,
+		"VROOMM_UUID="+uuid,
+		"VROOMM_IP="+ip,
+		"VROOMM_URI="+app.Config.ConnectionString,
+	)
+
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+
+	if err := command.Start(); err != nil {
+		return "", err
+	}
+
+	logger := app.Logger.WithField("action", action.Name)
+	go func() {
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		go streamActionOutput(&wg, stdout, logger, logrus.InfoLevel)
+		go streamActionOutput(&wg, stderr, logger, logrus.WarnLevel)
+
+		// All output must be read before waiting, which closes the pipes
+		wg.Wait()
+
+		if err := command.Wait(); err != nil {
+			logger.Errorf("%v: %v failed: %v", view.DomainName, action.Name, err)
+		} else {
+			logger.Infof("%v: %v finished", view.DomainName, action.Name)
+		}
+	}()
+
+	return fmt.Sprintf("%v: started %v (%v)", view.DomainName, action.Name, strings.Join(args, " ")), nil
+}
+
+func streamActionOutput(wg *sync.WaitGroup, reader io.Reader, logger *logrus.Entry, level logrus.Level) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		logger.Log(level, scanner.Text())
+	}
+}
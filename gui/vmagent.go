@@ -0,0 +1,70 @@
+package gui
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	guestCommandTimeout = 5 * time.Minute
+)
+
+// Prompt for a shell command and run it in the guest through the guest
+// agent. The output is written to the log view.
+func (view *VirtualMachineView) runGuestCommand(app *Application) (string, error) {
+	app.Push(
+		NewPrompt(
+			app,
+			"Run in Guest",
+			"Command>",
+			false,
+			func(app *Application, entry string) {
+				app.Pop()
+
+				// Run through the guest's shell so pipes and quoting work as typed
+				path, args := "/bin/sh", []string{"-c", entry}
+				if view.Domain.OSFamily() == virt.OSFamilyWindows {
+					path, args = "cmd.exe", []string{"/c", entry}
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), guestCommandTimeout)
+				app.PulseProgress(ctx, fmt.Sprintf("Running '%v' in %v...", entry, view.DomainName))
+
+				go func() {
+					defer cancel()
+					result, err := view.Domain.GuestExec(ctx, path, args, nil)
+
+					glib.IdleAdd(func() {
+						if err != nil {
+							app.Logger.Error(err.Error())
+							return
+						}
+
+						logger := app.Logger.WithField("domain", view.DomainName)
+						for _, output := range [][]byte{result.Stdout, result.Stderr} {
+							scanner := bufio.NewScanner(bytes.NewReader(output))
+							for scanner.Scan() {
+								logger.Info(scanner.Text())
+							}
+						}
+
+						if result.ExitCode != 0 || result.Signal != 0 {
+							logger.Errorf("'%v' failed (exit code %v, signal %v); press Ctrl+L for output", entry, result.ExitCode, result.Signal)
+						} else {
+							logger.Infof("'%v' finished; press Ctrl+L for output", entry)
+						}
+					})
+				}()
+			},
+		),
+	)
+
+	return "", nil
+}
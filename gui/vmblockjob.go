@@ -0,0 +1,65 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Flatten every disk backed by another image so the domain no longer depends
+// on its parent (e.g. the golden image of a linked clone).
+func (view *VirtualMachineView) detachFromParent(app *Application, progress func(current uint64, end uint64)) (string, error) {
+	targets, err := view.Domain.BackedDisks(app.Virt())
+	if err != nil {
+		return "", err
+	} else if len(targets) == 0 {
+		return "", fmt.Errorf("Virtual Machine '%v' has no disks with a backing image", view.DomainName)
+	}
+
+	for _, target := range targets {
+		app.Logger.Infof("Flattening disk '%v' of '%v'...", target, view.DomainName)
+		if err := view.Domain.Flatten(app.Virt(), target, progress); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("Detached '%v' from its parent (%v)", view.DomainName, strings.Join(targets, ", ")), nil
+}
+
+func (view *VirtualMachineView) commitOverlay(app *Application) (string, error) {
+	targets, err := view.Domain.BackedDisks(app.Virt())
+	if err != nil {
+		return "", err
+	} else if len(targets) == 0 {
+		return "", fmt.Errorf("Virtual Machine '%v' has no disks with a backing image", view.DomainName)
+	}
+
+	items := []*LabelItem{}
+	for _, target := range targets {
+		items = append(items, NewLabelItem("drive-harddisk-symbolic", target))
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Merge Disk Overlay",
+			"Disk>",
+			true,
+			func(app *Application, target string) {
+				app.Pop()
+				app.ActivationWithProgress(
+					fmt.Sprintf("Committing overlay of '%v' into its backing image...", target),
+					func(app *Application, progress func(current uint64, end uint64)) (string, error) {
+						if err := view.Domain.Commit(app.Virt(), target, progress); err != nil {
+							return "", err
+						} else {
+							return fmt.Sprintf("Merged overlay of disk '%v' of '%v'", target, view.DomainName), nil
+						}
+					},
+				)()
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
@@ -0,0 +1,41 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Start a command in the configured terminal emulator
+func runInTerminal(app *Application, args ...string) error {
+	if len(app.Config.Terminal) == 0 {
+		return fmt.Errorf("no terminal emulator configured")
+	}
+
+	args = append(append([]string{}, app.Config.Terminal[1:]...), args...)
+	command := exec.Command(app.Config.Terminal[0], args...)
+
+	// Ensure we have no stdio
+	command.Stderr = nil
+	command.Stdout = nil
+	command.Stdin = nil
+
+	return command.Start()
+}
+
+// Open `vroomm console` for the domain in a terminal emulator
+func (view *VirtualMachineView) serialConsole(app *Application) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	if err := runInTerminal(app, executable, "--connect", app.Config.ConnectionString, "console", view.DomainName); err != nil {
+		return "", err
+	}
+
+	// We should exit since we just spawned an interactive application
+	app.Quit()
+
+	return "Opened Serial Console", nil
+}
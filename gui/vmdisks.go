@@ -0,0 +1,240 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	ejectMediaItem = "Eject"
+)
+
+// Build a list of prompt items for the disks of the given device type, along
+// with a map from item text back to the disk target device.
+func (view *VirtualMachineView) diskItems(device string) ([]*LabelItem, map[string]string, error) {
+	disks, err := view.Domain.Disks(device)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := []*LabelItem{}
+	targets := map[string]string{}
+	for idx := range disks {
+		text := disks[idx].Target.Dev
+		if path := virt.DiskSourcePath(&disks[idx]); path != "" {
+			text = fmt.Sprintf("%v: %v", text, path)
+		}
+
+		icon := "drive-harddisk-symbolic"
+		if disks[idx].Device == "cdrom" {
+			icon = "media-optical-symbolic"
+		}
+
+		targets[text] = disks[idx].Target.Dev
+		items = append(items, NewLabelItem(icon, text))
+	}
+
+	return items, targets, nil
+}
+
+func (view *VirtualMachineView) addDisk(app *Application) (string, error) {
+	pools, err := app.Virt().StoragePoolNames()
+	if err != nil {
+		return "", err
+	}
+
+	items := []*LabelItem{}
+	for _, pool := range pools {
+		items = append(items, NewLabelItem("drive-multidisk-symbolic", pool))
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Select Storage Pool",
+			"Pool>",
+			true,
+			func(app *Application, pool string) {
+				app.Push(
+					NewPrompt(
+						app,
+						fmt.Sprintf("New Disk in '%v'", pool),
+						"Size (e.g. 20G)>",
+						false,
+						func(app *Application, input string) {
+							size, err := virt.ParseSize(input)
+							if err != nil {
+								app.Logger.Error(err.Error())
+								return
+							}
+
+							app.Pop()
+							app.Pop()
+							app.ActivationWithPulse(
+								"Creating disk...",
+								func(app *Application) (string, error) {
+									if target, err := view.Domain.AddDisk(app.Virt(), pool, size, "virtio"); err != nil {
+										return "", err
+									} else {
+										return fmt.Sprintf("Attached new %v disk '%v' to '%v'", virt.FormatSize(size), target, view.DomainName), nil
+									}
+								},
+							)()
+						},
+					),
+				)
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
+
+func (view *VirtualMachineView) resizeDisk(app *Application) (string, error) {
+	items, targets, err := view.diskItems("disk")
+	if err != nil {
+		return "", err
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Select Disk",
+			"Disk>",
+			true,
+			func(app *Application, entry string) {
+				target := targets[entry]
+				app.Push(
+					NewPrompt(
+						app,
+						fmt.Sprintf("Resize '%v'", target),
+						"New Size (e.g. 40G)>",
+						false,
+						func(app *Application, input string) {
+							size, err := virt.ParseSize(input)
+							if err != nil {
+								app.Logger.Error(err.Error())
+								return
+							}
+
+							app.Pop()
+							app.Pop()
+							app.ActivationWithPulse(
+								"Resizing disk...",
+								func(app *Application) (string, error) {
+									if err := view.Domain.ResizeDisk(app.Virt(), target, size); err != nil {
+										return "", err
+									} else {
+										return fmt.Sprintf("Resized disk '%v' of '%v' to %v", target, view.DomainName, virt.FormatSize(size)), nil
+									}
+								},
+							)()
+						},
+					),
+				)
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
+
+func (view *VirtualMachineView) detachDisk(app *Application) (string, error) {
+	items, targets, err := view.diskItems("")
+	if err != nil {
+		return "", err
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Detach Disk",
+			"Disk>",
+			true,
+			func(app *Application, entry string) {
+				target := targets[entry]
+				app.Pop()
+				app.ActivationWithPulse(
+					"Detaching disk...",
+					func(app *Application) (string, error) {
+						if err := view.Domain.DetachDisk(target); err != nil {
+							return "", err
+						} else {
+							return fmt.Sprintf("Detached disk '%v' from '%v'", target, view.DomainName), nil
+						}
+					},
+				)()
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
+
+func (view *VirtualMachineView) changeMedia(app *Application) (string, error) {
+	items, targets, err := view.diskItems("cdrom")
+	if err != nil {
+		return "", err
+	} else if len(items) == 0 {
+		return "", fmt.Errorf("Virtual Machine '%v' has no CD-ROM drives", view.DomainName)
+	}
+
+	media, err := app.Virt().ListInstallMedia()
+	if err != nil {
+		return "", err
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Select CD-ROM Drive",
+			"Drive>",
+			true,
+			func(app *Application, entry string) {
+				target := targets[entry]
+
+				mediaItems := []*LabelItem{NewLabelItem("media-eject-symbolic", ejectMediaItem)}
+				for _, path := range media {
+					mediaItems = append(mediaItems, NewLabelItem("media-optical-symbolic", path))
+				}
+
+				app.Push(
+					NewPrompt(
+						app,
+						fmt.Sprintf("Media for '%v'", target),
+						"ISO>",
+						false,
+						func(app *Application, path string) {
+							if path == ejectMediaItem {
+								path = ""
+							}
+
+							app.Pop()
+							app.Pop()
+							app.ActivationWithPulse(
+								"Changing media...",
+								func(app *Application) (string, error) {
+									if err := view.Domain.ChangeMedia(target, path); err != nil {
+										return "", err
+									} else if path == "" {
+										return fmt.Sprintf("Ejected media from '%v' of '%v'", target, view.DomainName), nil
+									} else {
+										return fmt.Sprintf("Inserted '%v' into '%v' of '%v'", path, target, view.DomainName), nil
+									}
+								},
+							)()
+						},
+						mediaItems...,
+					),
+				)
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
@@ -0,0 +1,90 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v3"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Start watching for guest agent events, and load the initial guest info.
+// The guest info is only reloaded when the agent (re)connects, since some
+// agent queries (e.g. filesystems) are too expensive to run every second.
+func (view *VirtualMachineView) watchGuestInfo(app *Application) {
+	stop, err := view.Domain.WatchGuestAgent(app.Virt(), func(connected bool) {
+		if connected {
+			go view.refreshGuestInfo(app)
+		} else {
+			glib.IdleAdd(func() {
+				view.GuestInfo = nil
+			})
+		}
+	})
+	if err != nil {
+		app.Logger.Warnf("Unable to watch guest agent events for '%v': %v", view.DomainName, err)
+		stop = func() {}
+	}
+	view.StopWatch = stop
+
+	go view.refreshGuestInfo(app)
+}
+
+// Query the guest agent in the background, and store the result from the
+// main loop. The property grid picks it up on its next update.
+func (view *VirtualMachineView) refreshGuestInfo(app *Application) {
+	info, err := view.Domain.GuestInfo()
+	glib.IdleAdd(func() {
+		if err != nil {
+			view.GuestInfo = nil
+		} else {
+			view.GuestInfo = info
+		}
+	})
+}
+
+func (view *VirtualMachineView) reloadGuestInfo(app *Application) (string, error) {
+	go view.refreshGuestInfo(app)
+	return "Reloading guest information...", nil
+}
+
+// Add the guest info rows to the property grid, returning the next free row
+func (view *VirtualMachineView) addGuestInfoRows(grid *gtk.Grid, row int) int {
+	info := view.GuestInfo
+	if info == nil {
+		addPropertyRow(grid, row, "Guest Agent:", "not connected")
+		return row + 1
+	}
+
+	addPropertyRow(grid, row, "Guest OS:", "%v (%v)", info.OS, info.Kernel)
+	addPropertyRow(grid, row+1, "Hostname:", info.Hostname)
+	addPropertyRow(grid, row+2, "Time Zone:", info.TimeZone)
+	row += 3
+
+	users := []string{}
+	for _, user := range info.Users {
+		name := user.Name
+		if user.Domain != "" {
+			name = fmt.Sprintf("%v\\%v", user.Domain, user.Name)
+		}
+		users = append(users, fmt.Sprintf("%v (since %v)", name, user.LoginTime.Format("Jan 2 15:04")))
+	}
+	if len(users) == 0 {
+		users = append(users, "none")
+	}
+	addPropertyRow(grid, row, "Users:", strings.Join(users, ", "))
+	row++
+
+	for _, fs := range info.Filesystems {
+		usage := fmt.Sprintf("%v", fs.Type)
+		if fs.Total > 0 {
+			usage = fmt.Sprintf("%v, %v of %v used (%.0f%%)", fs.Type, virt.FormatSize(fs.Used), virt.FormatSize(fs.Total), float64(fs.Used)*100/float64(fs.Total))
+		}
+		addPropertyRow(grid, row, fmt.Sprintf("FS %v:", fs.MountPoint), usage)
+		row++
+	}
+
+	return row
+}
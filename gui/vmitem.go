@@ -4,20 +4,46 @@ import (
 	"github.com/calebstewart/vroomm/virt"
 )
 
-func NewVirtualMachineItem(app *Application, domain *virt.Domain) (*LabelItem, error) {
+// A menu item opening the view of a VM. VM items can be marked for bulk
+// actions.
+type VirtualMachineItem struct {
+	domain *virt.Domain
+	*LabelItem
+}
+
+func NewVirtualMachineItem(app *Application, domain *virt.Domain) (*VirtualMachineItem, error) {
 	if domainName, err := domain.GetName(); err != nil {
 		return nil, err
 	} else {
-		return NewLabelItemWithAction(
-			"computer-symbolic",
-			domainName,
-			func() {
-				if view, err := NewVirtualMachineView(app, domain); err != nil {
-					app.Logger.Error(err)
-				} else {
-					app.Push(view)
-				}
-			},
-		), nil
+		return &VirtualMachineItem{
+			domain: domain,
+			LabelItem: NewLabelItemWithAction(
+				domainIcon(domain),
+				domainName,
+				func() {
+					if view, err := NewVirtualMachineView(app, domain); err != nil {
+						app.Logger.Error(err)
+					} else {
+						app.Push(view)
+					}
+				},
+			),
+		}, nil
+	}
+}
+
+func (item *VirtualMachineItem) Domain() *virt.Domain {
+	return item.domain
+}
+
+// Show whether the item is marked with a check mark and the "marked" style
+// class.
+func (item *VirtualMachineItem) SetMarked(marked bool) {
+	if marked {
+		item.label.SetText("✔ " + item.Text)
+		item.StyleContext().AddClass("marked")
+	} else {
+		item.label.SetText(item.Text)
+		item.StyleContext().RemoveClass("marked")
 	}
 }
@@ -0,0 +1,45 @@
+package gui
+
+import (
+	"fmt"
+)
+
+// Resolve the display resolution and client arguments for the domain,
+// preferring the VM metadata over the application configuration.
+func (view *VirtualMachineView) lookingGlassSettings(app *Application) (uint, uint, []string) {
+	width := app.Config.LookingGlass.Width
+	height := app.Config.LookingGlass.Height
+	args := append([]string{}, app.Config.LookingGlass.Args...)
+
+	if metadata := view.Domain.GetVmmData().LookingGlass; metadata != nil {
+		if metadata.Width != 0 && metadata.Height != 0 {
+			width, height = metadata.Width, metadata.Height
+		}
+		args = append(args, metadata.Args...)
+	}
+
+	return width, height, args
+}
+
+func (view *VirtualMachineView) setupLookingGlass(app *Application) (string, error) {
+	width, height, _ := view.lookingGlassSettings(app)
+
+	before, err := view.Domain.LookingGlass(width, height)
+	if err != nil {
+		return "", err
+	} else if before.Ready() {
+		return fmt.Sprintf("Looking Glass already configured for %vx%v: %v", width, height, before), nil
+	}
+
+	status, err := view.Domain.SetupLookingGlass(app.Virt(), width, height)
+	if err != nil {
+		return "", err
+	}
+
+	message := fmt.Sprintf("Configured %v for %vx%v", status.Path(), width, height)
+	if active, err := view.Domain.IsActive(); err == nil && active {
+		message += " (restart the VM to apply)"
+	}
+
+	return message, nil
+}
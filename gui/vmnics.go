@@ -0,0 +1,227 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var (
+	nicModels = []string{"virtio", "e1000e", "rtl8139"}
+)
+
+// Build a list of prompt items for the NICs of the domain, along with a map
+// from item text back to the NIC MAC address.
+func (view *VirtualMachineView) nicItems() ([]*LabelItem, map[string]string, error) {
+	interfaces, err := view.Domain.Interfaces()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := []*LabelItem{}
+	macs := map[string]string{}
+	for idx := range interfaces {
+		if interfaces[idx].MAC == nil {
+			continue
+		}
+
+		kind, source := virt.InterfaceSource(&interfaces[idx])
+		link := "up"
+		if !virt.InterfaceLinkUp(&interfaces[idx]) {
+			link = "down"
+		}
+
+		text := fmt.Sprintf("%v: %v %v (link %v)", interfaces[idx].MAC.Address, kind, source, link)
+		macs[text] = interfaces[idx].MAC.Address
+		items = append(items, NewLabelItem("network-wired-symbolic", text))
+	}
+
+	return items, macs, nil
+}
+
+// Prompt for a libvirt network (listed) or a host bridge (typed as
+// "bridge:<name>"), and pass the selection to the action.
+func promptInterfaceSource(app *Application, title string, action func(app *Application, kind string, source string)) error {
+	networks, err := app.Virt().EnumerateNetworks()
+	if err != nil {
+		return err
+	}
+
+	items := []*LabelItem{}
+	for _, network := range networks {
+		if name, err := network.GetName(); err == nil {
+			items = append(items, NewLabelItem(networkIcon, name))
+		}
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			title,
+			"Network (or bridge:<dev>)>",
+			false,
+			func(app *Application, entry string) {
+				if bridge, ok := strings.CutPrefix(entry, "bridge:"); ok {
+					action(app, virt.InterfaceSourceBridge, bridge)
+				} else {
+					action(app, virt.InterfaceSourceNetwork, entry)
+				}
+			},
+			items...,
+		),
+	)
+
+	return nil
+}
+
+func (view *VirtualMachineView) attachNic(app *Application) (string, error) {
+	return "", promptInterfaceSource(app, "Attach NIC", func(app *Application, kind string, source string) {
+		items := []*LabelItem{}
+		for _, model := range nicModels {
+			items = append(items, NewLabelItem("network-wired-symbolic", model))
+		}
+
+		app.Push(
+			NewPrompt(
+				app,
+				fmt.Sprintf("NIC Model for %v '%v'", kind, source),
+				"Model>",
+				false,
+				func(app *Application, model string) {
+					app.Pop()
+					app.Pop()
+					app.ActivationWithPulse(
+						"Attaching NIC...",
+						func(app *Application) (string, error) {
+							if mac, err := view.Domain.AttachInterface(kind, source, model); err != nil {
+								return "", err
+							} else {
+								return fmt.Sprintf("Attached NIC %v on %v '%v' to '%v'", mac, kind, source, view.DomainName), nil
+							}
+						},
+					)()
+				},
+				items...,
+			),
+		)
+	})
+}
+
+func (view *VirtualMachineView) detachNic(app *Application) (string, error) {
+	items, macs, err := view.nicItems()
+	if err != nil {
+		return "", err
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Detach NIC",
+			"NIC>",
+			true,
+			func(app *Application, entry string) {
+				mac := macs[entry]
+				app.Pop()
+				app.ActivationWithPulse(
+					"Detaching NIC...",
+					func(app *Application) (string, error) {
+						if err := view.Domain.DetachInterface(mac); err != nil {
+							return "", err
+						} else {
+							return fmt.Sprintf("Detached NIC %v from '%v'", mac, view.DomainName), nil
+						}
+					},
+				)()
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
+
+func (view *VirtualMachineView) switchNicNetwork(app *Application) (string, error) {
+	items, macs, err := view.nicItems()
+	if err != nil {
+		return "", err
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Select NIC",
+			"NIC>",
+			true,
+			func(app *Application, entry string) {
+				mac := macs[entry]
+				err := promptInterfaceSource(app, fmt.Sprintf("Move NIC %v", mac), func(app *Application, kind string, source string) {
+					app.Pop()
+					app.Pop()
+					app.ActivationWithPulse(
+						"Moving NIC...",
+						func(app *Application) (string, error) {
+							if err := view.Domain.SetInterfaceSource(mac, kind, source); err != nil {
+								return "", err
+							} else {
+								return fmt.Sprintf("Moved NIC %v of '%v' to %v '%v'", mac, view.DomainName, kind, source), nil
+							}
+						},
+					)()
+				})
+				if err != nil {
+					app.Logger.Error(err.Error())
+				}
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
+
+func (view *VirtualMachineView) toggleNicLink(app *Application) (string, error) {
+	items, macs, err := view.nicItems()
+	if err != nil {
+		return "", err
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Toggle NIC Link",
+			"NIC>",
+			true,
+			func(app *Application, entry string) {
+				mac := macs[entry]
+				app.Pop()
+
+				iface, err := view.Domain.LookupInterface(mac)
+				if err != nil {
+					app.Logger.Error(err.Error())
+					return
+				}
+
+				up := !virt.InterfaceLinkUp(iface)
+				state := "down"
+				if up {
+					state = "up"
+				}
+
+				app.ActivationWithPulse(
+					fmt.Sprintf("Setting link %v...", state),
+					func(app *Application) (string, error) {
+						if err := view.Domain.SetInterfaceLink(mac, up); err != nil {
+							return "", err
+						} else {
+							return fmt.Sprintf("Link of NIC %v on '%v' is now %v", mac, view.DomainName, state), nil
+						}
+					},
+				)()
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
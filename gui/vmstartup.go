@@ -0,0 +1,127 @@
+package gui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/calebstewart/vroomm/set"
+	"github.com/calebstewart/vroomm/startup"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Summarize startup dependencies for the property grid
+func describeStartup(metadata *virt.StartupMetadata) string {
+	text := strings.Join(metadata.DependsOn, ", ")
+	if metadata.Wait != virt.WaitNone {
+		text += fmt.Sprintf(" (wait for %v)", metadata.Wait)
+	}
+	if metadata.Delay > 0 {
+		text += fmt.Sprintf(", then %vs delay", metadata.Delay)
+	}
+	return text
+}
+
+func (view *VirtualMachineView) addDependency(app *Application) (string, error) {
+	domains, err := app.Virt().EnumerateAllDomains()
+	if err != nil {
+		return "", err
+	}
+
+	existing := set.New[string]()
+	if metadata := view.Domain.GetVmmData().Startup; metadata != nil {
+		existing.Add(metadata.DependsOn...)
+	}
+
+	items := []*LabelItem{}
+	for _, domain := range domains {
+		if name, err := domain.GetName(); err == nil && name != view.DomainName && !existing.Has(name) {
+			items = append(items, NewLabelItem("computer-symbolic", name))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Text < items[j].Text
+	})
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Add Startup Dependency",
+			"Start After>",
+			true,
+			func(app *Application, entry string) {
+				app.Pop()
+
+				if cycle, err := startup.DependsOn(app.Virt(), entry, view.DomainName); err != nil {
+					app.Logger.Error(err.Error())
+					return
+				} else if cycle {
+					app.Logger.Errorf("'%v' already depends on '%v'", entry, view.DomainName)
+					return
+				}
+
+				info := view.Domain.GetVmmData()
+				if info.Startup == nil {
+					info.Startup = &virt.StartupMetadata{}
+				}
+				info.Startup.DependsOn = append(info.Startup.DependsOn, entry)
+
+				if err := view.Domain.UpdateVmmData(info); err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					app.Logger.Infof("VM '%v' now starts after '%v'", view.DomainName, entry)
+				}
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
+
+func (view *VirtualMachineView) removeDependency(app *Application) (string, error) {
+	metadata := view.Domain.GetVmmData().Startup
+	if metadata == nil || len(metadata.DependsOn) == 0 {
+		return "", fmt.Errorf("VM '%v' has no startup dependencies", view.DomainName)
+	}
+
+	items := []*LabelItem{}
+	for _, name := range metadata.DependsOn {
+		items = append(items, NewLabelItem("computer-symbolic", name))
+	}
+
+	app.Push(
+		NewPrompt(
+			app,
+			"Remove Startup Dependency",
+			"Dependency>",
+			true,
+			func(app *Application, entry string) {
+				app.Pop()
+
+				info := view.Domain.GetVmmData()
+				dependsOn := []string{}
+				for _, name := range info.Startup.DependsOn {
+					if name != entry {
+						dependsOn = append(dependsOn, name)
+					}
+				}
+				info.Startup.DependsOn = dependsOn
+
+				// Drop the startup settings entirely once nothing is left
+				if len(dependsOn) == 0 && info.Startup.Wait == virt.WaitNone && info.Startup.Delay == 0 && info.Startup.Timeout == 0 {
+					info.Startup = nil
+				}
+
+				if err := view.Domain.UpdateVmmData(info); err != nil {
+					app.Logger.Error(err.Error())
+				} else {
+					app.Logger.Infof("VM '%v' no longer depends on '%v'", view.DomainName, entry)
+				}
+			},
+			items...,
+		),
+	)
+
+	return "", nil
+}
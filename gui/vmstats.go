@@ -0,0 +1,109 @@
+package gui
+
+import (
+	"context"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v3"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+var (
+	sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+)
+
+// Render values as a unicode sparkline. Values are scaled against max, or
+// against the largest value if max is zero.
+func sparkline(values []float64, max float64) string {
+	if max <= 0 {
+		for _, value := range values {
+			if value > max {
+				max = value
+			}
+		}
+	}
+
+	line := make([]rune, 0, len(values))
+	for _, value := range values {
+		level := 0
+		if max > 0 {
+			level = int(value / max * float64(len(sparkBlocks)-1))
+		}
+		if level < 0 {
+			level = 0
+		} else if level >= len(sparkBlocks) {
+			level = len(sparkBlocks) - 1
+		}
+		line = append(line, sparkBlocks[level])
+	}
+
+	return string(line)
+}
+
+// Sample the domain's resource usage in the background until the context is
+// cancelled. Samples are added to the history from the main loop.
+func (view *VirtualMachineView) collectStats(app *Application, ctx context.Context) {
+	interval := app.Config.StatsInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if active, err := view.Domain.IsActive(); err == nil && active {
+			if samples, err := app.Virt().SampleDomains(view.Domain); err == nil && len(samples) > 0 {
+				sample := samples[0]
+				glib.IdleAdd(func() {
+					view.addStatsSample(&sample)
+				})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (view *VirtualMachineView) addStatsSample(sample *virt.DomainSample) {
+	if view.lastSample != nil {
+		view.Stats.Push(sample.Rates(view.lastSample))
+	}
+	view.lastSample = sample
+}
+
+// Add current resource usage and sparklines to the property grid, returning
+// the next free row.
+func (view *VirtualMachineView) addStatsRows(grid *gtk.Grid, row int) int {
+	current, ok := view.Stats.Last()
+	if !ok {
+		return row
+	}
+
+	history := view.Stats.Array()
+	series := func(value func(rates *virt.DomainRates) float64) []float64 {
+		values := make([]float64, 0, len(history))
+		for idx := range history {
+			values = append(values, value(&history[idx]))
+		}
+		return values
+	}
+
+	cpu := series(func(rates *virt.DomainRates) float64 { return rates.CPU })
+	memory := series(func(rates *virt.DomainRates) float64 { return float64(rates.MemoryUsed) })
+	disk := series(func(rates *virt.DomainRates) float64 { return rates.BlockRead + rates.BlockWrite })
+	network := series(func(rates *virt.DomainRates) float64 { return rates.NetRx + rates.NetTx })
+
+	addPropertyRow(grid, row, "CPU Usage:", "%5.1f%% %v", current.CPU, sparkline(cpu, 100))
+	addPropertyRow(grid, row+1, "Memory Usage:", "%v / %v %v", virt.FormatSize(current.MemoryUsed), virt.FormatSize(current.MemoryTotal), sparkline(memory, float64(current.MemoryTotal)))
+	addPropertyRow(grid, row+2, "Disk I/O:", "%v/s read, %v/s write %v", virt.FormatSize(uint64(current.BlockRead)), virt.FormatSize(uint64(current.BlockWrite)), sparkline(disk, 0))
+	addPropertyRow(grid, row+3, "Network I/O:", "%v/s rx, %v/s tx %v", virt.FormatSize(uint64(current.NetRx)), virt.FormatSize(uint64(current.NetTx)), sparkline(network, 0))
+
+	return row + 4
+}
@@ -4,9 +4,6 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
 	"os/user"
 	"strings"
 	"time"
@@ -17,17 +14,24 @@ import (
 	"libvirt.org/go/libvirt"
 	"libvirt.org/go/libvirtxml"
 
+	"github.com/calebstewart/vroomm/cloudinit"
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/ring"
 	"github.com/calebstewart/vroomm/set"
 	"github.com/calebstewart/vroomm/virt"
 )
 
 type VirtualMachineView struct {
-	Domain       *virt.Domain        // The domain we are interacting with
-	DomainName   string              // Name of the domain
-	FlowBoxMenu  *FlowboxMenu        // Menu for interactions with the VM
-	PropertyView *gtk.ScrolledWindow // View for VM status
-	Cancel       func()              // A method for cancelling the background update task
-	*gtk.Box                         // Container for above widgets
+	Domain       *virt.Domain                   // The domain we are interacting with
+	DomainName   string                         // Name of the domain
+	FlowBoxMenu  *FlowboxMenu                   // Menu for interactions with the VM
+	PropertyView *gtk.ScrolledWindow            // View for VM status
+	Cancel       func()                         // A method for cancelling the background update task
+	StopWatch    func()                         // A method for stopping the guest agent event watch
+	GuestInfo    *virt.GuestInfo                // Guest information from the agent (nil if unavailable)
+	Stats        *ring.Buffer[virt.DomainRates] // Recent resource usage
+	lastSample   *virt.DomainSample             // Previous stats sample used to compute rates
+	*gtk.Box                                    // Container for above widgets
 }
 
 func NewVirtualMachineView(app *Application, domain *virt.Domain) (*VirtualMachineView, error) {
@@ -42,6 +46,7 @@ func NewVirtualMachineView(app *Application, domain *virt.Domain) (*VirtualMachi
 		FlowBoxMenu:  NewFlowboxMenu(name),
 		PropertyView: gtk.NewScrolledWindow(nil, nil),
 		Box:          gtk.NewBox(gtk.OrientationHorizontal, 2),
+		Stats:        ring.New[virt.DomainRates](app.Config.StatsHistory),
 	}
 
 	view.Box.PackStart(view.FlowBoxMenu, true, true, 0)
@@ -66,9 +71,9 @@ func (view *VirtualMachineView) updateView(app *Application) error {
 		return err
 	}
 
-	interfaces, err := view.Domain.ListAllInterfaceAddresses(libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_AGENT)
+	addresses, err := view.Domain.ResolveAddresses(app.AddressSources)
 	if err != nil {
-		interfaces = []libvirt.DomainInterface{}
+		addresses = []virt.InterfaceAddress{}
 	}
 
 	state, _, err := view.Domain.GetState()
@@ -83,6 +88,8 @@ func (view *VirtualMachineView) updateView(app *Application) error {
 	view.FlowBoxMenu.EmptyItems()
 
 	prettyState := ""
+	template := view.Domain.IsTemplate()
+	disposable := view.Domain.IsDisposable()
 
 	switch state {
 	case libvirt.DOMAIN_BLOCKED:
@@ -91,34 +98,77 @@ func (view *VirtualMachineView) updateView(app *Application) error {
 		fallthrough
 	case libvirt.DOMAIN_RUNNING:
 		prettyState = "Running"
-		view.CreateItem(app, "computer-symbolic", "Open Viewer", app.ActivationWithPulse("Opening with virt-viewer...", view.openViewer))
-		view.CreateItem(app, "system-search-symbolic", "Open Looking Glass", app.ActivationWithPulse("Opening with looking-glass...", view.openLookingGlass))
+		if viewers := view.viewerNames(app); len(viewers) > 0 {
+			view.CreateItem(app, "computer-symbolic", fmt.Sprintf("Open Viewer (%v)", viewers[0]), app.ActivationWithPulse(fmt.Sprintf("Opening with %v...", viewers[0]), view.openViewer))
+		}
+		view.CreateItem(app, "computer-symbolic", "Open With...", app.Activation(view.openViewerWith))
+		view.CreateItem(app, "utilities-terminal-symbolic", "Serial Console", app.Activation(view.serialConsole))
+		view.CreateItem(app, "utilities-terminal-symbolic", "Run in Guest", app.Activation(view.runGuestCommand))
+		view.CreateItem(app, "view-refresh-symbolic", "Reload Guest Info", app.Activation(view.reloadGuestInfo))
 
-		if len(interfaces) > 0 {
+		if len(addresses) > 0 {
 			view.CreateItem(app, "utilities-terminal-symbolic", "Open SSH Connection", app.Activation(view.openSSHConnection))
 		}
 
 		view.CreateItem(app, "system-shutdown-symbolic", "Shutdown", app.ActivationWithPulse("Requesting VM Shutdown...", view.shutDown))
 		view.CreateItem(app, "face-shutmouth-symbolic", "Force Off", app.ActivationWithPulse("Forcing VM Off...", view.forceOff))
 		view.CreateItem(app, "media-floppy-symbolic", "Save State", app.ActivationWithPulse("Saving VM State...", view.saveState))
+		view.CreateItem(app, "drive-harddisk-symbolic", "Merge Disk Overlay", app.Activation(view.commitOverlay))
 	case libvirt.DOMAIN_CRASHED:
 		fallthrough
 	case libvirt.DOMAIN_SHUTOFF:
 		fallthrough
 	case libvirt.DOMAIN_SHUTDOWN:
 		prettyState = "Off"
-		view.CreateItem(app, "media-playback-start-symbolic", "Start", app.ActivationWithPulse("Starting VM...", view.start))
+		if template {
+			prettyState = "Template"
+			view.CreateItem(app, templateIcon, "New from Template", app.Activation(view.newFromTemplate))
+		} else {
+			view.CreateItem(app, "media-playback-start-symbolic", "Start", app.ActivationWithPulse("Starting VM...", view.start))
+			view.CreateItem(app, seedIcon, "Cloud-init Seed", app.Activation(view.attachSeed))
+		}
 	}
 
-	view.CreateItem(app, "edit-copy-symbolic", "Linked Clone", app.Activation(view.linkedClone))
-	view.CreateItem(app, "edit-copy-symbolic", "Full Clone", app.Activation(view.fullClone))
-	view.CreateItem(app, "camera-photo-symbolic", "Take Snapshot", app.Activation(view.snapshot))
-	view.CreateItem(app, "document-open-recent-symbolic", "Restore Snapshot", app.Activation(view.restoreSnapshot))
-	view.CreateItem(app, "user-trash-symbolic", "Delete Snapshot", app.Activation(view.deleteSnapshot))
-	view.CreateItem(app, "folder-symbolic", "Move To...", app.Activation(view.move))
+	if !template {
+		view.CreateItem(app, "edit-copy-symbolic", "Linked Clone", app.Activation(view.linkedClone))
+		view.CreateItem(app, "edit-copy-symbolic", "Full Clone", app.Activation(view.fullClone))
+	}
+	if !disposable {
+		view.CreateItem(app, disposableIcon, "Disposable Clone", app.ActivationWithPulse("Creating disposable VM...", view.disposableClone))
+	}
+	if !template {
+		view.CreateItem(app, "camera-photo-symbolic", "Take Snapshot", app.Activation(view.snapshot))
+		view.CreateItem(app, "document-open-recent-symbolic", "Restore Snapshot", app.Activation(view.restoreSnapshot))
+		view.CreateItem(app, "user-trash-symbolic", "Delete Snapshot", app.Activation(view.deleteSnapshot))
+		view.CreateItem(app, "folder-symbolic", "Move To...", app.Activation(view.move))
+	}
 	view.CreateItem(app, "user-bookmarks-symbolic", "Add Label", app.Activation(view.addLabel))
 	view.CreateItem(app, "user-bookmarks-symbolic", "Remove Label", app.Activation(view.removeLabel))
-	view.CreateItem(app, "document-edit-symbolic", "Edit XML", app.ActivationWithPulse("Opening VM XML w/ xdg-open...", view.editXML))
+
+	// Templates only change on purpose, after being unmarked
+	if template {
+		view.CreateItem(app, templateIcon, "Unmark Template", app.Activation(view.unmarkTemplate))
+	} else {
+		view.CreateItem(app, templateIcon, "Mark as Template", app.Activation(view.markTemplate))
+		view.CreateItem(app, "go-up-symbolic", "Add Dependency", app.Activation(view.addDependency))
+		view.CreateItem(app, "go-down-symbolic", "Remove Dependency", app.Activation(view.removeDependency))
+		view.CreateItem(app, "drive-harddisk-symbolic", "Add Disk", app.Activation(view.addDisk))
+		view.CreateItem(app, "drive-harddisk-symbolic", "Resize Disk", app.Activation(view.resizeDisk))
+		view.CreateItem(app, "drive-harddisk-symbolic", "Detach Disk", app.Activation(view.detachDisk))
+		view.CreateItem(app, "media-optical-symbolic", "Change CD Media", app.Activation(view.changeMedia))
+		view.CreateItem(app, "network-wired-symbolic", "Attach NIC", app.Activation(view.attachNic))
+		view.CreateItem(app, "network-wired-symbolic", "Detach NIC", app.Activation(view.detachNic))
+		view.CreateItem(app, "network-wired-symbolic", "Switch NIC Network", app.Activation(view.switchNicNetwork))
+		view.CreateItem(app, "network-wired-disconnected-symbolic", "Toggle NIC Link", app.Activation(view.toggleNicLink))
+		view.CreateItem(app, "preferences-desktop-display-symbolic", "Set Default Viewer", app.Activation(view.setDefaultViewer))
+		view.CreateItem(app, "video-display-symbolic", "Setup Looking Glass", app.Activation(view.setupLookingGlass))
+		view.CreateItem(app, hostDeviceIcon, "Host Devices", func() {
+			app.Push(NewHostDevicesView(view.Domain, view.DomainName))
+		})
+		view.CreateItem(app, "edit-cut-symbolic", "Detach from Parent", app.ActivationWithProgress("Detaching VM from its parent image...", view.detachFromParent))
+		view.CreateItem(app, "document-edit-symbolic", "Edit XML", app.ActivationWithPulse("Opening VM XML w/ xdg-open...", view.editXML))
+		view.addCustomActions(app, prettyState)
+	}
 
 	if selectedIndex > -1 {
 		child := view.FlowBoxMenu.FlowBox.ChildAtIndex(selectedIndex)
@@ -134,21 +184,72 @@ func (view *VirtualMachineView) updateView(app *Application) error {
 	addPropertyRow(grid, 2, "CPU:", "%v", domXml.VCPU.Value)
 	addPropertyRow(grid, 3, "Memory:", "%v-%v", domXml.Memory.Value, domXml.Memory.Unit)
 
-	for idx, iface := range interfaces {
-		if iface.Name == "lo" {
-			continue
+	// Group addresses by interface, preserving the order they were reported in
+	ifaceNames := []string{}
+	ifaceAddresses := map[string][]string{}
+	for _, addr := range addresses {
+		label := fmt.Sprintf("Interface %v (%v)", addr.Interface, addr.Source)
+		if _, ok := ifaceAddresses[label]; !ok {
+			ifaceNames = append(ifaceNames, label)
 		}
+		ifaceAddresses[label] = append(ifaceAddresses[label], fmt.Sprintf("%v/%v", addr.Address, addr.Prefix))
+	}
 
-		// Collect all the addresses
-		addresses := []string{}
-		for _, addr := range iface.Addrs {
-			addresses = append(addresses, fmt.Sprintf("%v/%v", addr.Addr, addr.Prefix))
-		}
+	row := view.addStatsRows(grid, 4)
+
+	lgWidth, lgHeight, _ := view.lookingGlassSettings(app)
+	addPropertyRow(grid, row, "Looking Glass:", virt.InspectLookingGlass(&domXml, lgWidth, lgHeight).String())
+	row++
+
+	if startup := view.Domain.GetVmmData().Startup; startup != nil && len(startup.DependsOn) > 0 {
+		addPropertyRow(grid, row, "Depends On:", describeStartup(startup))
+		row++
+	}
 
-		// Add the interface
-		addPropertyRow(grid, 4+idx, fmt.Sprintf("Interface %v", iface.Name), strings.Join(addresses, ", "))
+	if disposable {
+		addPropertyRow(grid, row, "Disposable:", "Deleted when shut off")
+		row++
 	}
 
+	for _, label := range ifaceNames {
+		addPropertyRow(grid, row, label, strings.Join(ifaceAddresses[label], ", "))
+		row++
+	}
+
+	// Show where each NIC is plugged in, and whether that network is even running
+	if domXml.Devices != nil {
+		for idx := range domXml.Devices.Interfaces {
+			iface := &domXml.Devices.Interfaces[idx]
+			if iface.MAC == nil {
+				continue
+			}
+
+			kind, source := virt.InterfaceSource(iface)
+			status := fmt.Sprintf("%v %v", kind, source)
+			if kind == virt.InterfaceSourceNetwork {
+				if network, err := app.Virt().LookupNetwork(source); err != nil {
+					status += " (missing)"
+				} else {
+					if active, err := network.IsActive(); err == nil && active {
+						status += " (active)"
+					} else {
+						status += " (inactive)"
+					}
+					network.Free()
+				}
+			}
+
+			if !virt.InterfaceLinkUp(iface) {
+				status += ", link down"
+			}
+
+			addPropertyRow(grid, row, fmt.Sprintf("NIC %v:", iface.MAC.Address), status)
+			row++
+		}
+	}
+
+	row = view.addGuestInfoRows(grid, row)
+
 	grid.ShowAll()
 
 	if view.PropertyView.Child() != nil {
@@ -166,6 +267,8 @@ func (view *VirtualMachineView) Enter(app *Application) error {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	view.Cancel = cancel
+	view.watchGuestInfo(app)
+	go view.collectStats(app, ctx)
 
 	go func() {
 		for {
@@ -195,11 +298,13 @@ func (view *VirtualMachineView) CreateItem(app *Application, icon string, text s
 
 func (view *VirtualMachineView) Leave(app *Application) error {
 	view.Cancel()
+	view.StopWatch()
 	return nil
 }
 
 func (view *VirtualMachineView) Close(app *Application) error {
 	view.Cancel()
+	view.StopWatch()
 	return nil
 }
 
@@ -215,74 +320,13 @@ func (view *VirtualMachineView) InvalidateFilter() {
 	view.FlowBoxMenu.InvalidateFilter()
 }
 
-func (view *VirtualMachineView) openViewer(app *Application) (string, error) {
-	uuid, err := view.Domain.GetUUIDString()
-	if err != nil {
-		return "", err
-	}
-
-	// Setup the virt-viewer command
-	command := exec.Command(
-		"virt-viewer",
-		"--connect",
-		app.Config.ConnectionString,
-		"--auto-resize=always",
-		"--cursor=auto",
-		"--wait",
-		"--reconnect",
-		"--shared",
-		"--uuid",
-		uuid,
-	)
-
-	// Ensure we have no stdio
-	command.Stderr = nil
-	command.Stdout = nil
-	command.Stdin = nil
-
-	if err := command.Start(); err != nil {
-		return "", err
-	}
-
-	// We should exit since we just spawned an interactive application
-	app.Quit()
-
-	return "Started virt-viewer", nil
-}
-
-func (view *VirtualMachineView) openLookingGlass(app *Application) (string, error) {
-	uuid, err := view.Domain.GetUUIDString()
-	if err != nil {
-		return "", err
-	}
-
-	// Setup the virt-viewer command
-	command := exec.Command(
-		"looking-glass-client",
-		uuid,
-	)
-
-	// Ensure we have no stdio
-	command.Stderr = nil
-	command.Stdout = nil
-	command.Stdin = nil
-
-	if err := command.Start(); err != nil {
-		return "", err
-	}
-
-	// We should exit since we just spawned an interactive application
-	app.Quit()
-
-	return "Started Looking Glass Client", nil
-}
-
 func (view *VirtualMachineView) shutDown(app *Application) (string, error) {
-	return "Virtual Machine Powered Off", view.Domain.Shutdown()
+	return "Virtual Machine Powered Off", app.Hooks.Wrap(hooks.EventShutdown, view.Domain, nil, view.Domain.Shutdown)
 }
 
 func (view *VirtualMachineView) forceOff(app *Application) (string, error) {
-	return "Virtual Machine Forced Off", view.Domain.Destroy()
+	details := map[string]string{"force": "true"}
+	return "Virtual Machine Forced Off", app.Hooks.Wrap(hooks.EventShutdown, view.Domain, details, view.Domain.Destroy)
 }
 
 func (view *VirtualMachineView) saveState(app *Application) (string, error) {
@@ -290,30 +334,27 @@ func (view *VirtualMachineView) saveState(app *Application) (string, error) {
 }
 
 func (view *VirtualMachineView) start(app *Application) (string, error) {
-	return "Virtual Machine Started", view.Domain.Create()
+	if err := view.Domain.CheckNotTemplate(); err != nil {
+		return "", err
+	}
+	return "Virtual Machine Started", app.Hooks.Wrap(hooks.EventStart, view.Domain, nil, view.Domain.Create)
 }
 
 func (view *VirtualMachineView) openSSHConnection(app *Application) (string, error) {
 
-	interfaces, err := view.Domain.ListAllInterfaceAddresses(libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_AGENT)
+	addresses, err := view.Domain.ResolveAddresses(app.AddressSources)
 	if err != nil {
 		return "", err
-	} else if len(interfaces) == 0 {
+	} else if len(addresses) == 0 {
 		return "", fmt.Errorf("No interfaces found for domain '%v'", view.DomainName)
 	}
 
-	ifaceMap := map[string]*libvirt.DomainIPAddress{}
+	ifaceMap := map[string]*virt.InterfaceAddress{}
 	ifaceAddrItems := []*LabelItem{}
-	for ifaceIdx := range interfaces {
-		for addrIdx := range interfaces[ifaceIdx].Addrs {
-			// Ignore loopback interfaces
-			if interfaces[ifaceIdx].Name == "lo" {
-				continue
-			}
-			itemLabel := fmt.Sprintf("%v: %v", interfaces[ifaceIdx].Name, interfaces[ifaceIdx].Addrs[addrIdx].Addr)
-			ifaceMap[itemLabel] = &interfaces[ifaceIdx].Addrs[addrIdx]
-			ifaceAddrItems = append(ifaceAddrItems, NewLabelItem("network-server-symbolic", itemLabel))
-		}
+	for idx := range addresses {
+		itemLabel := fmt.Sprintf("%v: %v (%v)", addresses[idx].Interface, addresses[idx].Address, addresses[idx].Source)
+		ifaceMap[itemLabel] = &addresses[idx]
+		ifaceAddrItems = append(ifaceAddrItems, NewLabelItem("network-server-symbolic", itemLabel))
 	}
 
 	currentUser, err := user.Current()
@@ -330,13 +371,13 @@ func (view *VirtualMachineView) openSSHConnection(app *Application) (string, err
 				app.Push(
 					NewPrompt(
 						app,
-						fmt.Sprintf("Select User for %v", addr.Addr),
+						fmt.Sprintf("Select User for %v", addr.Address),
 						"User>",
 						false,
 						func(app *Application, username string) {
 							app.Pop()
 							app.Pop()
-							connStr := fmt.Sprintf("ssh://%v@%v", username, addr.Addr)
+							connStr := fmt.Sprintf("ssh://%v@%v", username, addr.Address)
 							app.ActivationWithPulse(
 								fmt.Sprintf("Opening %v...", connStr),
 								func(app *Application) (string, error) {
@@ -360,69 +401,70 @@ func (view *VirtualMachineView) openSSHConnection(app *Application) (string, err
 }
 
 func (view *VirtualMachineView) linkedClone(app *Application) (string, error) {
-	prompt := NewPrompt(app, "Linked Clone", "Clone Name>", false, func(app *Application, name string) {
-		conn := app.Virt()
-		if _, err := conn.LookupDomainByName(name); err == nil {
-			app.Logger.Errorf("Virtual Machine '%v' already exists", name)
-			return
-		}
-
-		app.ActivationWithPulse(
-			"Creating linked VM clone...",
-			func(app *Application) (string, error) {
-				domain, err := view.Domain.Clone(app.Virt(), name, true)
-				if err != nil {
-					return "", err
-				}
-
-				domainView, err := NewVirtualMachineView(app, domain)
-				if err == nil {
-					glib.IdleAdd(func() {
-						app.ReplaceTop(domainView)
-					})
-				}
-
-				return fmt.Sprintf("Virtual Machine '%v' Cloned to '%v'", view.DomainName, name), nil
-			},
-		)()
-	})
-
-	app.Push(prompt)
-
+	app.Push(view.clonePrompt(app, "Linked Clone", true))
 	return "", nil
 }
 
 func (view *VirtualMachineView) fullClone(app *Application) (string, error) {
-	prompt := NewPrompt(app, "Full Clone", "Clone Name>", false, func(app *Application, name string) {
+	app.Push(view.clonePrompt(app, "Full Clone", false))
+	return "", nil
+}
+
+// Ask for the name of the clone and its cloud-init seed, then clone the VM in
+// the background and show the clone.
+func (view *VirtualMachineView) clonePrompt(app *Application, title string, linked bool) *Prompt {
+	return NewPrompt(app, title, "Clone Name>", false, func(app *Application, name string) {
 		conn := app.Virt()
 		if _, err := conn.LookupDomainByName(name); err == nil {
 			app.Logger.Errorf("Virtual Machine '%v' already exists", name)
 			return
 		}
 
-		app.ActivationWithPulse(
-			"Creating full VM clone...",
-			func(app *Application) (string, error) {
-				domain, err := view.Domain.Clone(app.Virt(), name, true)
-				if err != nil {
-					return "", err
-				}
+		app.ReplaceTop(newSeedPrompt(app, name, func(app *Application, vars *cloudinit.Variables) {
+			message := "Creating full VM clone..."
+			if linked {
+				message = "Creating linked VM clone..."
+			}
 
-				domainView, err := NewVirtualMachineView(app, domain)
-				if err == nil {
-					glib.IdleAdd(func() {
-						app.ReplaceTop(domainView)
-					})
-				}
+			app.ActivationWithPulse(message, func(app *Application) (string, error) {
+				return view.clone(app, name, linked, vars)
+			})()
+		}))
+	})
+}
 
-				return fmt.Sprintf("Virtual Machine '%v' Cloned to '%v'", view.DomainName, name), nil
-			},
-		)()
+// Clone the VM, attach a seed if vars is set, and replace the prompt with
+// the clone. The clone is kept if attaching the seed fails.
+func (view *VirtualMachineView) clone(app *Application, name string, linked bool, vars *cloudinit.Variables) (string, error) {
+	var domain *virt.Domain
+	details := map[string]string{"clone": name, "linked": fmt.Sprintf("%v", linked)}
+	err := app.Hooks.Wrap(hooks.EventClone, view.Domain, details, func() (err error) {
+		if domain, err = view.Domain.Clone(app.Virt(), name, linked); err == nil {
+			details["clone_uuid"], _ = domain.GetUUIDString()
+		}
+		return err
 	})
+	if err != nil {
+		return "", err
+	}
 
-	app.Push(prompt)
+	if vars != nil {
+		if _, err = cloudinit.New(app.Config, app.Virt()).Attach(domain, *vars); err != nil {
+			err = fmt.Errorf("cloned '%v' to '%v', but failed to attach cloud-init seed: %w", view.DomainName, name, err)
+		}
+	}
 
-	return "", nil
+	domainView, viewErr := NewVirtualMachineView(app, domain)
+	if viewErr == nil {
+		glib.IdleAdd(func() {
+			app.ReplaceTop(domainView)
+		})
+	}
+
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Virtual Machine '%v' Cloned to '%v'", view.DomainName, name), nil
 }
 
 // Simple snapshot interface. It does not provide a way to specify the snapshot description
@@ -453,43 +495,12 @@ func (view *VirtualMachineView) doSnapshot(app *Application, name string) {
 	go func() {
 		defer cancel()
 
-		domainDescription := libvirtxml.Domain{}
-		if xmlDescr, err := view.Domain.GetXMLDesc(libvirt.DOMAIN_XML_SECURE); err != nil {
-			app.Logger.Error(err)
-			return
-		} else if err := xml.Unmarshal([]byte(xmlDescr), &domainDescription); err != nil {
-			app.Logger.Error(err)
-			return
-		}
-
-		domainSnapshot := libvirtxml.DomainSnapshot{
-			Name:        name,
-			Description: "",
-			Parent:      nil,
-			Disks: &libvirtxml.DomainSnapshotDisks{
-				Disks: []libvirtxml.DomainSnapshotDisk{},
-			},
-			Domain: &domainDescription,
-		}
-
-		for _, disk := range domainDescription.Devices.Disks {
-			snapshot := ""
-			if disk.ReadOnly != nil {
-				snapshot = "no"
-			}
-
-			domainSnapshot.Disks.Disks = append(domainSnapshot.Disks.Disks, libvirtxml.DomainSnapshotDisk{
-				Name:     disk.Target.Dev,
-				Snapshot: snapshot,
-			})
-		}
-
-		if snapshotXml, err := xml.Marshal(&domainSnapshot); err != nil {
-			app.Logger.Error(err.Error())
-		} else if _, err := view.Domain.CreateSnapshotXML(string(snapshotXml), 0); err != nil {
+		if err := app.Hooks.Wrap(hooks.EventSnapshot, view.Domain, map[string]string{"snapshot": name}, func() error {
+			return view.Domain.Snapshot(name)
+		}); err != nil {
 			app.Logger.Error(err.Error())
 		} else {
-			app.Logger.Infof("Created Snapshot '%v' of '%v'", name, domainDescription.Name)
+			app.Logger.Infof("Created Snapshot '%v' of '%v'", name, view.DomainName)
 		}
 	}()
 }
@@ -546,7 +557,9 @@ func (view *VirtualMachineView) restoreSnapshot(app *Application) (string, error
 				func(app *Application) (string, error) {
 					if domainName, err := domain.GetName(); err != nil {
 						return "", err
-					} else if err := snapshot.RevertToSnapshot(0); err != nil {
+					} else if err := app.Hooks.Wrap(hooks.EventRevert, domain, map[string]string{"snapshot": snapshotName}, func() error {
+						return snapshot.RevertToSnapshot(0)
+					}); err != nil {
 						return "", err
 					} else {
 						return fmt.Sprintf(
@@ -565,20 +578,13 @@ func (view *VirtualMachineView) restoreSnapshot(app *Application) (string, error
 
 func (view *VirtualMachineView) move(app *Application) (string, error) {
 
-	virtConn := app.Virt()
-	domains, err := virtConn.EnumerateAllDomains()
+	folders, err := app.Folders.List(app.Virt())
 	if err != nil {
 		return "", err
 	}
 
-	folders := set.New[string]()
-	for _, domain := range domains {
-		info := domain.GetVmmData()
-		folders.Add(info.Path)
-	}
-
-	items := []*LabelItem{}
-	for folder := range folders {
+	items := []*LabelItem{NewLabelItem(folderIcon, "/")}
+	for _, folder := range folders {
 		items = append(items, NewLabelItem(folderIcon, folder))
 	}
 
@@ -590,10 +596,20 @@ func (view *VirtualMachineView) move(app *Application) (string, error) {
 			false,
 			func(app *Application, entry string) {
 				info := view.Domain.GetVmmData()
+				details := map[string]string{"from": info.Path}
 				info.Path = strings.TrimSuffix(entry, "/") + "/"
-				view.Domain.UpdateVmmData(info)
-				app.Logger.Infof("Moved '%v' to '%v'", view.DomainName, info.Path)
+				details["to"] = info.Path
+
 				app.Pop()
+
+				app.ActivationWithPulse("Moving VM...", func(app *Application) (string, error) {
+					if err := app.Hooks.Wrap(hooks.EventMove, view.Domain, details, func() error {
+						return view.Domain.UpdateVmmData(info)
+					}); err != nil {
+						return "", err
+					}
+					return fmt.Sprintf("Moved '%v' to '%v'", view.DomainName, info.Path), nil
+				})()
 			},
 			items...,
 		),
@@ -638,10 +654,16 @@ func (view *VirtualMachineView) addLabel(app *Application) (string, error) {
 				labels.Add(entry)
 				info.Labels = labels.Array()
 
-				view.Domain.UpdateVmmData(info)
-
-				app.Logger.Infof("Added lable '%v' to VM '%v'", entry, view.DomainName)
 				app.Pop()
+
+				app.ActivationWithPulse("Adding VM label...", func(app *Application) (string, error) {
+					if err := app.Hooks.Wrap(hooks.EventLabel, view.Domain, map[string]string{"add": entry}, func() error {
+						return view.Domain.UpdateVmmData(info)
+					}); err != nil {
+						return "", err
+					}
+					return fmt.Sprintf("Added lable '%v' to VM '%v'", entry, view.DomainName), nil
+				})()
 			},
 			items...,
 		),
@@ -676,10 +698,16 @@ func (view *VirtualMachineView) removeLabel(app *Application) (string, error) {
 					}
 				}
 
-				view.Domain.UpdateVmmData(info)
-
-				app.Logger.Infof("Removed VM label '%v' from '%v'", entry, view.DomainName)
 				app.Pop()
+
+				app.ActivationWithPulse("Removing VM label...", func(app *Application) (string, error) {
+					if err := app.Hooks.Wrap(hooks.EventLabel, view.Domain, map[string]string{"remove": entry}, func() error {
+						return view.Domain.UpdateVmmData(info)
+					}); err != nil {
+						return "", err
+					}
+					return fmt.Sprintf("Removed VM label '%v' from '%v'", entry, view.DomainName), nil
+				})()
 			},
 			items...,
 		),
@@ -696,58 +724,9 @@ func (view *VirtualMachineView) editXML(app *Application) (string, error) {
 		return "", err
 	}
 
-	// Create a temporary file for editing the XML
-	filp, err := os.CreateTemp("", "vroomm-domain.*.xml")
-	if err != nil {
-		return "", err
-	}
-
-	// Ensure the temporary file is removed
-	defer os.Remove(filp.Name())
-
-	// Write the domain XML to the file
-	if count, err := filp.WriteString(domXml); err != nil || count != len(domXml) {
-		return "", fmt.Errorf("failed to save temporary domain definition")
-	}
-
-	// Ensure our writes get to disk
-	if err := filp.Sync(); err != nil {
-		return "", err
-	}
-
-	// Seek to the beginning for later
-	if _, err := filp.Seek(0, 0); err != nil {
+	if newDomXml, err := editDocument(app, "vroomm-domain.*.xml", domXml); err != nil {
 		return "", err
-	}
-
-	// Edit the file with xdg-open
-	command := exec.Command(
-		"xdg-open",
-		filp.Name(),
-	)
-	command.Stdout = nil
-	command.Stderr = nil
-	command.Stdin = nil
-
-	// Hide the application window
-	glib.IdleAdd(func() {
-		app.Window.Hide()
-	})
-
-	// Ensure we show the window when completed no matter what
-	defer func() {
-		glib.IdleAdd(func() {
-			app.Window.Show()
-		})
-	}()
-
-	if err := command.Run(); err != nil {
-		return "", nil
-	}
-
-	if newDomXmlBytes, err := io.ReadAll(filp); err != nil {
-		return "", fmt.Errorf("failed to read edited domain definition")
-	} else if newDomXml := string(newDomXmlBytes); newDomXml == domXml {
+	} else if newDomXml == domXml {
 		return "Virtual Machine XML Unchanged", nil
 	} else if domain, err := app.Virt().DomainDefineXML(newDomXml); err != nil {
 		return "", err
@@ -0,0 +1,213 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/sirupsen/logrus"
+
+	"github.com/calebstewart/vroomm/config"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// A vroomm operation which hooks can be attached to
+type Event string
+
+const (
+	EventStart    Event = "start"
+	EventShutdown Event = "shutdown"
+	EventClone    Event = "clone"
+	EventSnapshot Event = "snapshot"
+	EventRevert   Event = "revert"
+	EventMove     Event = "move"
+	EventLabel    Event = "label"
+	EventDelete   Event = "delete"
+)
+
+// Whether a hook runs before or after the operation
+type Phase string
+
+const (
+	PhasePre  Phase = "pre"
+	PhasePost Phase = "post"
+)
+
+// The domain an operation applies to
+type Domain struct {
+	Name   string   `json:"name"`
+	UUID   string   `json:"uuid"`
+	Path   string   `json:"path"`
+	Labels []string `json:"labels"`
+}
+
+// The JSON document passed to hooks on stdin
+type Payload struct {
+	Event   Event             `json:"event"`
+	Phase   Phase             `json:"phase"`
+	URI     string            `json:"uri"`
+	Domain  Domain            `json:"domain"`
+	Details map[string]string `json:"details,omitempty"` // Operation specific values (e.g. clone name)
+	Error   string            `json:"error,omitempty"`   // Why the operation failed (post hooks only)
+}
+
+// Runs hook executables from hooks.d and hook commands from the config.
+// Executables in hooks.d/<event>/ run for both phases, with the phase as
+// their first argument.
+type Runner struct {
+	Dir      string        // Root hooks.d directory
+	URI      string        // Libvirt connection URI passed to hooks
+	Commands []config.Hook // Hook commands from the config
+	Timeout  time.Duration // How long a single hook may run before it is killed (0 means no limit)
+}
+
+func New(cfg *config.Config) *Runner {
+	return &Runner{
+		Dir:      filepath.Join(xdg.ConfigHome, "vroomm", "hooks.d"),
+		URI:      cfg.ConnectionString,
+		Commands: cfg.Hooks,
+		Timeout:  cfg.HookTimeout,
+	}
+}
+
+// Run pre-hooks, the operation and then post-hooks. A failing pre-hook aborts
+// the operation. Post-hooks see the operation error (if any), and their own
+// failures are only logged since the operation already happened. The
+// operation may add to details before post-hooks run.
+func (r *Runner) Wrap(event Event, domain *virt.Domain, details map[string]string, operation func() error) error {
+	if details == nil {
+		details = map[string]string{}
+	}
+
+	if err := r.Run(PhasePre, event, domain, details, nil); err != nil {
+		return fmt.Errorf("%v aborted by hook: %w", event, err)
+	}
+
+	opErr := operation()
+
+	if err := r.Run(PhasePost, event, domain, details, opErr); err != nil {
+		logrus.WithError(err).WithField("event", event).Warn("post-hook failed")
+	}
+
+	return opErr
+}
+
+// Run all hooks for a single phase of an event, stopping at the first
+// failure.
+func (r *Runner) Run(phase Phase, event Event, domain *virt.Domain, details map[string]string, opErr error) error {
+	commands := r.commands(phase, event)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	payload := Payload{
+		Event:   event,
+		Phase:   phase,
+		URI:     r.URI,
+		Details: details,
+	}
+	if opErr != nil {
+		payload.Error = opErr.Error()
+	}
+
+	if domain != nil {
+		metadata := domain.GetVmmData()
+		payload.Domain.Path = metadata.Path
+		payload.Domain.Labels = metadata.Labels
+		payload.Domain.Name, _ = domain.GetName()
+		payload.Domain.UUID, _ = domain.GetUUIDString()
+	}
+
+	document, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for _, command := range commands {
+		if err := runHook(command, phase, event, document, r.Timeout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Collect the commands for a phase of an event. Executables in hooks.d run
+// first, in lexical order, followed by commands from the config.
+func (r *Runner) commands(phase Phase, event Event) [][]string {
+	commands := [][]string{}
+
+	dir := filepath.Join(r.Dir, string(event))
+	if entries, err := os.ReadDir(dir); err == nil {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+
+		for _, entry := range entries {
+			if info, err := entry.Info(); err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+				continue
+			}
+			commands = append(commands, []string{filepath.Join(dir, entry.Name()), string(phase)})
+		}
+	}
+
+	for _, hook := range r.Commands {
+		if hook.Event != string(event) || len(hook.Command) == 0 {
+			continue
+		} else if hook.Phase != "" && hook.Phase != string(phase) {
+			continue
+		}
+		commands = append(commands, hook.Command)
+	}
+
+	return commands
+}
+
+func runHook(args []string, phase Phase, event Event, document []byte, timeout time.Duration) error {
+	output := bytes.Buffer{}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	command := exec.CommandContext(ctx, args[0], args[1:]...)
+	command.WaitDelay = time.Second // Don't wait on children still holding the output open
+	command.Stdin = bytes.NewReader(document)
+	command.Stdout = &output
+	command.Stderr = &output
+	command.Env = append(
+		os.Environ(),
+		"VROOMM_HOOK_EVENT="+string(event),
+		"VROOMM_HOOK_PHASE="+string(phase),
+	)
+
+	logger := logrus.WithField("hook", args[0]).WithField("event", event).WithField("phase", phase)
+
+	err := command.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v", timeout)
+	}
+	if text := strings.TrimSpace(output.String()); text != "" {
+		logger.Debug(text)
+		if err != nil {
+			err = errors.Join(err, errors.New(text))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %w", filepath.Base(args[0]), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/calebstewart/vroomm/folders"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Ways a VM can be cloned from its source
+const (
+	CloneLinked = "linked" // Copy-on-write overlay on top of the source disks
+	CloneFull   = "full"   // Independent copy of the source disks
+)
+
+// A set of VMs which can be rebuilt from a checked in file, such as:
+//
+//	name = "web-lab"
+//
+//	[[vm]]
+//	name      = "web-db"
+//	source    = "debian-12-base"
+//	folder    = "/labs/web/"
+//	labels    = ["web-lab"]
+//	memory    = "4GiB"
+//	vcpus     = 2
+//	networks  = ["default", "bridge:br0"]
+//	snapshots = ["clean"]
+type Manifest struct {
+	Name string `toml:"name"` // Identifies the VMs belonging to this manifest
+	VMs  []VM   `toml:"vm"`   // VMs to create
+}
+
+// A single VM of a manifest. Optional settings which are left out are taken
+// from the clone source as-is.
+type VM struct {
+	Name      string   `toml:"name"`      // Domain name
+	Source    string   `toml:"source"`    // Domain to clone from
+	Clone     string   `toml:"clone"`     // linked (default) or full
+	Folder    string   `toml:"folder"`    // Folder path (default /)
+	Labels    []string `toml:"labels"`    // Labels, replacing any from the source
+	Memory    string   `toml:"memory"`    // Memory size such as "4GiB" (optional)
+	VCPUs     uint     `toml:"vcpus"`     // Number of vCPUs (optional)
+	Networks  []string `toml:"networks"`  // NIC sources in order; networks by name or "bridge:<dev>" (optional)
+	Snapshots []string `toml:"snapshots"` // Snapshots taken after the VM is created
+}
+
+// A NIC source parsed from a manifest network entry
+type network struct {
+	kind   string
+	source string
+}
+
+// Load and validate a manifest file
+func Load(path string) (*Manifest, error) {
+	filp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer filp.Close()
+
+	manifest := &Manifest{}
+	decoder := toml.NewDecoder(filp)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(manifest); err != nil {
+		// The strict mode error only names the problem fields in its details
+		strictErr := &toml.StrictMissingError{}
+		if errors.As(err, &strictErr) {
+			return nil, fmt.Errorf("%v: unknown fields:\n%v", path, strictErr.String())
+		}
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	return manifest, nil
+}
+
+// Check the manifest for mistakes which can be found without libvirt, and
+// fill in defaults.
+func (manifest *Manifest) Validate() error {
+	if manifest.Name == "" {
+		return errors.New("manifest name is required")
+	}
+
+	names := map[string]bool{}
+	for idx := range manifest.VMs {
+		vm := &manifest.VMs[idx]
+
+		if vm.Name == "" {
+			return fmt.Errorf("vm %v: name is required", idx+1)
+		} else if names[vm.Name] {
+			return fmt.Errorf("vm '%v': defined more than once", vm.Name)
+		} else if vm.Source == "" {
+			return fmt.Errorf("vm '%v': source is required", vm.Name)
+		} else if vm.Source == vm.Name {
+			return fmt.Errorf("vm '%v': cannot be cloned from itself", vm.Name)
+		}
+		names[vm.Name] = true
+
+		switch vm.Clone {
+		case "":
+			vm.Clone = CloneLinked
+		case CloneLinked, CloneFull:
+		default:
+			return fmt.Errorf("vm '%v': unknown clone mode '%v' (expected linked or full)", vm.Name, vm.Clone)
+		}
+
+		vm.Folder = folders.Normalize(vm.Folder)
+		if vm.Labels == nil {
+			vm.Labels = []string{}
+		}
+
+		if _, err := vm.memory(); err != nil {
+			return fmt.Errorf("vm '%v': %w", vm.Name, err)
+		} else if _, err := vm.networks(); err != nil {
+			return fmt.Errorf("vm '%v': %w", vm.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Memory size in bytes, or zero to keep the memory of the source
+func (vm *VM) memory() (uint64, error) {
+	if vm.Memory == "" {
+		return 0, nil
+	}
+	return virt.ParseSize(vm.Memory)
+}
+
+// Parsed NIC sources, or nil to keep the NICs of the source
+func (vm *VM) networks() ([]network, error) {
+	if vm.Networks == nil {
+		return nil, nil
+	}
+
+	networks := []network{}
+	for _, entry := range vm.Networks {
+		if bridge, ok := strings.CutPrefix(entry, "bridge:"); ok && bridge != "" {
+			networks = append(networks, network{kind: virt.InterfaceSourceBridge, source: bridge})
+		} else if !ok && entry != "" {
+			networks = append(networks, network{kind: virt.InterfaceSourceNetwork, source: entry})
+		} else {
+			return nil, fmt.Errorf("invalid network '%v'", entry)
+		}
+	}
+
+	return networks, nil
+}
+
+func (n network) String() string {
+	if n.kind == virt.InterfaceSourceBridge {
+		return "bridge:" + n.source
+	}
+	return n.source
+}
@@ -0,0 +1,571 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/set"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// What applying a plan does to a single VM
+type Action string
+
+const (
+	ActionCreate    Action = "create"    // Clone the VM from its source
+	ActionReplace   Action = "replace"   // Delete the VM and clone it again (the source or clone mode changed)
+	ActionUpdate    Action = "update"    // Change folder, labels, resources, networks or snapshots in place
+	ActionDelete    Action = "delete"    // Delete the VM and its disks
+	ActionOrphaned  Action = "orphaned"  // Created by the manifest but no longer in it; only deleted when pruning
+	ActionUnchanged Action = "unchanged" // Already matches the manifest
+)
+
+// A planned change to a single VM, with a human readable description of
+// each difference.
+type Change struct {
+	Action  Action
+	Name    string
+	Details []string
+	vm      *VM
+	domain  *virt.Domain
+}
+
+// The changes needed to make libvirt match a manifest
+type Plan struct {
+	Manifest *Manifest
+	Changes  []Change
+}
+
+// Reconciles libvirt domains against manifests
+type Reconciler struct {
+	Conn  *virt.Connection // Libvirt connection
+	Hooks *hooks.Runner    // Hooks run around clone, move, label, snapshot and delete
+}
+
+func New(conn *virt.Connection, runner *hooks.Runner) *Reconciler {
+	return &Reconciler{
+		Conn:  conn,
+		Hooks: runner,
+	}
+}
+
+// Number of changes which applying the plan would make
+func (plan *Plan) Pending() int {
+	count := 0
+	for _, change := range plan.Changes {
+		if change.Action != ActionUnchanged && change.Action != ActionOrphaned {
+			count++
+		}
+	}
+	return count
+}
+
+// Compare the manifest against the current domains. VMs which were created
+// by the manifest but have since been removed from it are only deleted if
+// prune is set. A domain with the same name as a manifest VM which was not
+// created by the manifest is an error, so existing VMs are never adopted or
+// overwritten.
+func (r *Reconciler) Plan(manifest *Manifest, prune bool) (*Plan, error) {
+	owned, existing, err := r.ownedDomains(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Manifest: manifest,
+		Changes:  []Change{},
+	}
+
+	for idx := range manifest.VMs {
+		vm := &manifest.VMs[idx]
+		change := Change{
+			Name: vm.Name,
+			vm:   vm,
+		}
+
+		domain, isOwned := owned[vm.Name]
+		if !isOwned && existing.Has(vm.Name) {
+			return nil, fmt.Errorf("'%v' already exists and was not created by manifest '%v'", vm.Name, manifest.Name)
+		}
+
+		if !isOwned || r.needsReplace(domain, vm) {
+			if _, err := r.Conn.LookupDomain(vm.Source); err != nil {
+				return nil, fmt.Errorf("'%v': clone source '%v' not found", vm.Name, vm.Source)
+			}
+		}
+
+		if !isOwned {
+			change.Action = ActionCreate
+			change.Details = describe(vm)
+		} else if r.needsReplace(domain, vm) {
+			previous := domain.GetVmmData().Manifest
+			change.Action = ActionReplace
+			change.domain = domain
+			change.Details = append(
+				[]string{fmt.Sprintf("source: %v (%v) -> %v (%v)", previous.Source, cloneMode(previous.Linked), vm.Source, vm.Clone)},
+				describe(vm)[1:]...,
+			)
+		} else {
+			change.domain = domain
+			if change.Details, err = r.diff(domain, vm); err != nil {
+				return nil, fmt.Errorf("'%v': %w", vm.Name, err)
+			} else if len(change.Details) > 0 {
+				change.Action = ActionUpdate
+			} else {
+				change.Action = ActionUnchanged
+			}
+		}
+
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	// Domains created by the manifest which it no longer mentions
+	wanted := set.New[string]()
+	for _, vm := range manifest.VMs {
+		wanted.Add(vm.Name)
+	}
+
+	orphans := []string{}
+	for name := range owned {
+		if !wanted.Has(name) {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+
+	for _, name := range orphans {
+		change := Change{
+			Action: ActionOrphaned,
+			Name:   name,
+			domain: owned[name],
+		}
+		if prune {
+			change.Action = ActionDelete
+		} else {
+			change.Details = []string{"no longer in the manifest; apply with --prune to delete"}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	return plan, nil
+}
+
+// Plan deleting every domain created by the manifest, including those which
+// have since been removed from it.
+func (r *Reconciler) DestroyPlan(manifest *Manifest) (*Plan, error) {
+	owned, _, err := r.ownedDomains(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range owned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	plan := &Plan{
+		Manifest: manifest,
+		Changes:  []Change{},
+	}
+	for _, name := range names {
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete,
+			Name:   name,
+			domain: owned[name],
+		})
+	}
+
+	return plan, nil
+}
+
+// Apply the plan, calling report before each change. Deletions happen
+// first, so pruned VMs free their names and storage before new clones are
+// made. Applying stops at the first failure; applying the manifest again
+// picks up where it left off.
+func (r *Reconciler) Apply(plan *Plan, report func(change *Change)) error {
+	ordered := []*Change{}
+	for idx := range plan.Changes {
+		if plan.Changes[idx].Action == ActionDelete {
+			ordered = append(ordered, &plan.Changes[idx])
+		}
+	}
+	for idx := range plan.Changes {
+		switch plan.Changes[idx].Action {
+		case ActionCreate, ActionReplace, ActionUpdate:
+			ordered = append(ordered, &plan.Changes[idx])
+		}
+	}
+
+	for _, change := range ordered {
+		if report != nil {
+			report(change)
+		}
+
+		var err error
+		switch change.Action {
+		case ActionDelete:
+			err = r.delete(change.domain)
+		case ActionReplace:
+			if err = r.delete(change.domain); err == nil {
+				err = r.create(plan.Manifest, change.vm)
+			}
+		case ActionCreate:
+			err = r.create(plan.Manifest, change.vm)
+		case ActionUpdate:
+			err = r.reconcile(change.domain, change.vm, false)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%v '%v': %w", change.Action, change.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Find the domains created by the manifest (by name), along with the names
+// of all domains.
+func (r *Reconciler) ownedDomains(manifest *Manifest) (map[string]*virt.Domain, set.Set[string], error) {
+	domains, err := r.Conn.EnumerateAllDomains()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	owned := map[string]*virt.Domain{}
+	existing := set.New[string]()
+	for _, domain := range domains {
+		name, err := domain.GetName()
+		if err != nil {
+			return nil, nil, err
+		}
+		existing.Add(name)
+
+		if metadata := domain.GetVmmData().Manifest; metadata != nil && metadata.Name == manifest.Name {
+			owned[name] = domain
+		}
+	}
+
+	return owned, existing, nil
+}
+
+// Whether the VM was cloned from a different source or in a different mode
+// than the manifest now asks for
+func (r *Reconciler) needsReplace(domain *virt.Domain, vm *VM) bool {
+	metadata := domain.GetVmmData().Manifest
+	return metadata.Source != vm.Source || cloneMode(metadata.Linked) != vm.Clone
+}
+
+// Describe everything a new VM gets, for create and replace changes
+func describe(vm *VM) []string {
+	details := []string{
+		fmt.Sprintf("source: %v (%v)", vm.Source, vm.Clone),
+		fmt.Sprintf("folder: %v", vm.Folder),
+	}
+	if len(vm.Labels) > 0 {
+		details = append(details, fmt.Sprintf("labels: %v", strings.Join(vm.Labels, ", ")))
+	}
+	if memory, _ := vm.memory(); memory > 0 {
+		details = append(details, fmt.Sprintf("memory: %v", virt.FormatSize(memory)))
+	}
+	if vm.VCPUs > 0 {
+		details = append(details, fmt.Sprintf("vcpus: %v", vm.VCPUs))
+	}
+	if vm.Networks != nil {
+		details = append(details, fmt.Sprintf("networks: %v", strings.Join(vm.Networks, ", ")))
+	}
+	for _, snapshot := range vm.Snapshots {
+		details = append(details, fmt.Sprintf("snapshot: +%v", snapshot))
+	}
+	return details
+}
+
+// Describe the differences between an existing VM and the manifest
+func (r *Reconciler) diff(domain *virt.Domain, vm *VM) ([]string, error) {
+	details := []string{}
+	metadata := domain.GetVmmData()
+
+	if metadata.Path != vm.Folder {
+		details = append(details, fmt.Sprintf("folder: %v -> %v", metadata.Path, vm.Folder))
+	}
+
+	if added, removed := labelChanges(metadata.Labels, vm.Labels); len(added) > 0 || len(removed) > 0 {
+		details = append(details, fmt.Sprintf("labels: %v -> %v", strings.Join(metadata.Labels, ", "), strings.Join(vm.Labels, ", ")))
+	}
+
+	memory, vcpus, err := domain.Resources()
+	if err != nil {
+		return nil, err
+	}
+	if wanted, _ := vm.memory(); wanted > 0 && wanted != memory {
+		details = append(details, fmt.Sprintf("memory: %v -> %v", virt.FormatSize(memory), virt.FormatSize(wanted)))
+	}
+	if vm.VCPUs > 0 && vm.VCPUs != vcpus {
+		details = append(details, fmt.Sprintf("vcpus: %v -> %v", vcpus, vm.VCPUs))
+	}
+
+	if wanted, _ := vm.networks(); wanted != nil {
+		current, err := currentNetworks(domain)
+		if err != nil {
+			return nil, err
+		}
+		if !sameNetworks(current, wanted) {
+			details = append(details, fmt.Sprintf("networks: %v -> %v", joinNetworks(current), joinNetworks(wanted)))
+		}
+	}
+
+	missing, err := missingSnapshots(domain, vm)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range missing {
+		details = append(details, fmt.Sprintf("snapshot: +%v", snapshot))
+	}
+
+	return details, nil
+}
+
+// Clone a new VM from its source and configure it. If configuring fails,
+// the new VM is deleted again so a later apply starts from scratch.
+func (r *Reconciler) create(manifest *Manifest, vm *VM) error {
+	source, err := r.Conn.LookupDomain(vm.Source)
+	if err != nil {
+		return err
+	}
+
+	var domain *virt.Domain
+	linked := vm.Clone == CloneLinked
+	details := map[string]string{"clone": vm.Name, "linked": fmt.Sprintf("%v", linked)}
+	if err := r.Hooks.Wrap(hooks.EventClone, source, details, func() (err error) {
+		if domain, err = source.Clone(r.Conn, vm.Name, linked); err == nil {
+			details["clone_uuid"], _ = domain.GetUUIDString()
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err := domain.ResetMACs(r.Conn); err != nil {
+		return errors.Join(err, domain.Delete(r.Conn, true))
+	}
+
+	// Mark the VM as owned by the manifest first, so that it is found by a
+	// later apply or destroy even if configuring it fails below. Sources are
+	// often templates, but the clone is a regular VM.
+	metadata := domain.GetVmmData()
+	metadata.Manifest = &virt.ManifestMetadata{
+		Name:   manifest.Name,
+		Source: vm.Source,
+		Linked: linked,
+	}
+	metadata.Path = vm.Folder
+	metadata.Labels = vm.Labels
+	metadata.Template = false
+	if err := domain.UpdateVmmData(metadata); err != nil {
+		return errors.Join(err, domain.Delete(r.Conn, true))
+	}
+
+	if err := r.reconcile(domain, vm, true); err != nil {
+		return errors.Join(err, domain.Delete(r.Conn, true))
+	}
+
+	return nil
+}
+
+// Bring a VM in line with the manifest. Folder and label hooks are only run
+// for existing VMs, since a fresh clone had its metadata set directly.
+func (r *Reconciler) reconcile(domain *virt.Domain, vm *VM, created bool) error {
+	if !created {
+		if err := r.updateMetadata(domain, vm); err != nil {
+			return err
+		}
+	}
+
+	memory, _ := vm.memory()
+	if current, vcpus, err := domain.Resources(); err != nil {
+		return err
+	} else if (memory > 0 && memory != current) || (vm.VCPUs > 0 && vm.VCPUs != vcpus) {
+		if err := domain.SetResources(r.Conn, memory, vm.VCPUs); err != nil {
+			return err
+		}
+	}
+
+	if wanted, _ := vm.networks(); wanted != nil {
+		if err := setNetworks(domain, wanted); err != nil {
+			return err
+		}
+	}
+
+	missing, err := missingSnapshots(domain, vm)
+	if err != nil {
+		return err
+	}
+	for _, name := range missing {
+		if err := r.Hooks.Wrap(hooks.EventSnapshot, domain, map[string]string{"snapshot": name}, func() error {
+			return domain.Snapshot(name)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Move and relabel an existing VM, running move and label hooks
+func (r *Reconciler) updateMetadata(domain *virt.Domain, vm *VM) error {
+	metadata := domain.GetVmmData()
+
+	if metadata.Path != vm.Folder {
+		details := map[string]string{"from": metadata.Path, "to": vm.Folder}
+		metadata.Path = vm.Folder
+		if err := r.Hooks.Wrap(hooks.EventMove, domain, details, func() error {
+			return domain.UpdateVmmData(metadata)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if added, removed := labelChanges(metadata.Labels, vm.Labels); len(added) > 0 || len(removed) > 0 {
+		details := map[string]string{"add": strings.Join(added, ","), "remove": strings.Join(removed, ",")}
+		metadata.Labels = vm.Labels
+		if err := r.Hooks.Wrap(hooks.EventLabel, domain, details, func() error {
+			return domain.UpdateVmmData(metadata)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) delete(domain *virt.Domain) error {
+	return r.Hooks.Wrap(hooks.EventDelete, domain, map[string]string{"remove_storage": "true"}, func() error {
+		return domain.Delete(r.Conn, true)
+	})
+}
+
+// Compute labels to add and remove to get from current to wanted
+func labelChanges(current []string, wanted []string) ([]string, []string) {
+	currentSet, wantedSet := set.New(current...), set.New(wanted...)
+
+	added, removed := []string{}, []string{}
+	for _, label := range wanted {
+		if !currentSet.Has(label) {
+			added = append(added, label)
+		}
+	}
+	for _, label := range current {
+		if !wantedSet.Has(label) {
+			removed = append(removed, label)
+		}
+	}
+	return added, removed
+}
+
+// Return the sources of the NICs of the domain, in order
+func currentNetworks(domain *virt.Domain) ([]network, error) {
+	interfaces, err := domain.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	networks := []network{}
+	for idx := range interfaces {
+		kind, source := virt.InterfaceSource(&interfaces[idx])
+		networks = append(networks, network{kind: kind, source: source})
+	}
+	return networks, nil
+}
+
+// Move existing NICs to the wanted sources (in order), attaching new NICs or
+// detaching left over ones as needed. MAC addresses of kept NICs stay the
+// same.
+func setNetworks(domain *virt.Domain, wanted []network) error {
+	interfaces, err := domain.Interfaces()
+	if err != nil {
+		return err
+	}
+
+	for idx, want := range wanted {
+		if idx >= len(interfaces) {
+			if _, err := domain.AttachInterface(want.kind, want.source, "virtio"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		iface := &interfaces[idx]
+		if iface.MAC == nil {
+			return fmt.Errorf("interface %v has no MAC address", idx)
+		} else if kind, source := virt.InterfaceSource(iface); kind == want.kind && source == want.source {
+			continue
+		} else if err := domain.SetInterfaceSource(iface.MAC.Address, want.kind, want.source); err != nil {
+			return err
+		}
+	}
+
+	for idx := len(wanted); idx < len(interfaces); idx++ {
+		if interfaces[idx].MAC == nil {
+			return fmt.Errorf("interface %v has no MAC address", idx)
+		} else if err := domain.DetachInterface(interfaces[idx].MAC.Address); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sameNetworks(a []network, b []network) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func joinNetworks(networks []network) string {
+	if len(networks) == 0 {
+		return "(none)"
+	}
+
+	names := []string{}
+	for _, n := range networks {
+		names = append(names, n.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// Snapshots listed in the manifest which the domain does not have yet
+func missingSnapshots(domain *virt.Domain, vm *VM) ([]string, error) {
+	if len(vm.Snapshots) == 0 {
+		return nil, nil
+	}
+
+	names, err := domain.SnapshotListNames(0)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := set.New(names...)
+	missing := []string{}
+	for _, name := range vm.Snapshots {
+		if !existing.Has(name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+func cloneMode(linked bool) string {
+	if linked {
+		return CloneLinked
+	}
+	return CloneFull
+}
@@ -0,0 +1,152 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+func TestLabelChanges(t *testing.T) {
+	tests := []struct {
+		current []string
+		wanted  []string
+		added   []string
+		removed []string
+	}{
+		{current: nil, wanted: nil, added: []string{}, removed: []string{}},
+		{current: []string{"a", "b"}, wanted: []string{"b", "a"}, added: []string{}, removed: []string{}},
+		{current: []string{}, wanted: []string{"a", "b"}, added: []string{"a", "b"}, removed: []string{}},
+		{current: []string{"a", "b"}, wanted: []string{}, added: []string{}, removed: []string{"a", "b"}},
+		{current: []string{"a", "b"}, wanted: []string{"b", "c"}, added: []string{"c"}, removed: []string{"a"}},
+	}
+
+	for _, test := range tests {
+		added, removed := labelChanges(test.current, test.wanted)
+		if !reflect.DeepEqual(added, test.added) || !reflect.DeepEqual(removed, test.removed) {
+			t.Errorf("labelChanges(%v, %v) = %v, %v; want %v, %v", test.current, test.wanted, added, removed, test.added, test.removed)
+		}
+	}
+}
+
+func TestSameNetworks(t *testing.T) {
+	def := network{kind: virt.InterfaceSourceNetwork, source: "default"}
+	br0 := network{kind: virt.InterfaceSourceBridge, source: "br0"}
+	named := network{kind: virt.InterfaceSourceNetwork, source: "br0"}
+
+	tests := []struct {
+		a, b []network
+		same bool
+		text string
+	}{
+		{a: []network{}, b: []network{}, same: true, text: "(none)"},
+		{a: []network{def, br0}, b: []network{def, br0}, same: true, text: "default, bridge:br0"},
+		{a: []network{def, br0}, b: []network{br0, def}, same: false, text: "default, bridge:br0"},
+		{a: []network{br0}, b: []network{named}, same: false, text: "bridge:br0"},
+		{a: []network{def}, b: []network{def, def}, same: false, text: "default"},
+	}
+
+	for _, test := range tests {
+		if got := sameNetworks(test.a, test.b); got != test.same {
+			t.Errorf("sameNetworks(%v, %v) = %v, want %v", test.a, test.b, got, test.same)
+		}
+		if got := joinNetworks(test.a); got != test.text {
+			t.Errorf("joinNetworks(%v) = %q, want %q", test.a, got, test.text)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		vm   VM
+		want []string
+	}{
+		{
+			vm:   VM{Source: "base", Clone: CloneLinked, Folder: "/"},
+			want: []string{"source: base (linked)", "folder: /"},
+		},
+		{
+			vm: VM{
+				Source:    "base",
+				Clone:     CloneFull,
+				Folder:    "/labs/web/",
+				Labels:    []string{"web", "lab"},
+				Memory:    "4GiB",
+				VCPUs:     2,
+				Networks:  []string{"default", "bridge:br0"},
+				Snapshots: []string{"clean"},
+			},
+			want: []string{
+				"source: base (full)",
+				"folder: /labs/web/",
+				"labels: web, lab",
+				"memory: 4.0 GiB",
+				"vcpus: 2",
+				"networks: default, bridge:br0",
+				"snapshot: +clean",
+			},
+		},
+		{
+			vm:   VM{Source: "base", Clone: CloneLinked, Folder: "/", Networks: []string{}},
+			want: []string{"source: base (linked)", "folder: /", "networks: "},
+		},
+	}
+
+	for _, test := range tests {
+		if got := describe(&test.vm); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("describe(%+v) = %q, want %q", test.vm, got, test.want)
+		}
+	}
+}
+
+func TestPending(t *testing.T) {
+	plan := &Plan{
+		Changes: []Change{
+			{Action: ActionCreate},
+			{Action: ActionReplace},
+			{Action: ActionUpdate},
+			{Action: ActionDelete},
+			{Action: ActionOrphaned},
+			{Action: ActionUnchanged},
+		},
+	}
+
+	if got := plan.Pending(); got != 4 {
+		t.Errorf("Pending() = %v, want 4", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+		wantErr  bool
+	}{
+		{name: "empty", manifest: Manifest{Name: "lab"}},
+		{name: "unnamed", manifest: Manifest{}, wantErr: true},
+		{name: "defaults", manifest: Manifest{Name: "lab", VMs: []VM{{Name: "a", Source: "base"}}}},
+		{name: "no source", manifest: Manifest{Name: "lab", VMs: []VM{{Name: "a"}}}, wantErr: true},
+		{name: "self", manifest: Manifest{Name: "lab", VMs: []VM{{Name: "a", Source: "a"}}}, wantErr: true},
+		{name: "duplicate", manifest: Manifest{Name: "lab", VMs: []VM{{Name: "a", Source: "base"}, {Name: "a", Source: "base"}}}, wantErr: true},
+		{name: "clone mode", manifest: Manifest{Name: "lab", VMs: []VM{{Name: "a", Source: "base", Clone: "deep"}}}, wantErr: true},
+		{name: "memory", manifest: Manifest{Name: "lab", VMs: []VM{{Name: "a", Source: "base", Memory: "lots"}}}, wantErr: true},
+		{name: "network", manifest: Manifest{Name: "lab", VMs: []VM{{Name: "a", Source: "base", Networks: []string{"bridge:"}}}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := test.manifest.Validate()
+		if test.wantErr && err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		} else if !test.wantErr && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		}
+	}
+
+	manifest := Manifest{Name: "lab", VMs: []VM{{Name: "a", Source: "base", Folder: "labs"}}}
+	if err := manifest.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if vm := manifest.VMs[0]; vm.Clone != CloneLinked || vm.Folder != "/labs/" || vm.Labels == nil {
+		t.Errorf("defaults not filled in: %+v", vm)
+	}
+}
@@ -0,0 +1,66 @@
+package query
+
+import (
+	"libvirt.org/go/libvirt"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Collect the queryable properties of a domain
+func NewSubject(domain *virt.Domain) (*Subject, error) {
+	name, err := domain.GetName()
+	if err != nil {
+		return nil, err
+	}
+
+	uuid, err := domain.GetUUIDString()
+	if err != nil {
+		return nil, err
+	}
+
+	state, _, err := domain.GetState()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := domain.GetVmmData()
+
+	return &Subject{
+		Name:   name,
+		UUID:   uuid,
+		Labels: metadata.Labels,
+		State:  StateName(state),
+		Path:   metadata.Path,
+		OS:     domain.OSFamily(),
+	}, nil
+}
+
+// Return the domains matching the query
+func (query *Query) Filter(domains []*virt.Domain) ([]*virt.Domain, error) {
+	matches := []*virt.Domain{}
+	for _, domain := range domains {
+		if subject, err := NewSubject(domain); err != nil {
+			return nil, err
+		} else if query.Match(subject) {
+			matches = append(matches, domain)
+		}
+	}
+
+	return matches, nil
+}
+
+// Simplify a libvirt domain state to the name used in queries
+func StateName(state libvirt.DomainState) string {
+	switch state {
+	case libvirt.DOMAIN_RUNNING, libvirt.DOMAIN_BLOCKED:
+		return "running"
+	case libvirt.DOMAIN_PAUSED:
+		return "paused"
+	case libvirt.DOMAIN_PMSUSPENDED:
+		return "suspended"
+	case libvirt.DOMAIN_CRASHED:
+		return "crashed"
+	default:
+		return "off"
+	}
+}
@@ -0,0 +1,234 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Fields which can be used as term keys
+const (
+	FieldName  = "name"
+	FieldUUID  = "uuid"
+	FieldLabel = "label"
+	FieldState = "state"
+	FieldPath  = "path"
+	FieldOS    = "os"
+)
+
+var (
+	fields = []string{FieldName, FieldUUID, FieldLabel, FieldState, FieldPath, FieldOS}
+)
+
+// The properties of a VM which a query is evaluated against
+type Subject struct {
+	Name   string   // Domain name
+	UUID   string   // Domain UUID
+	Labels []string // Labels from vroomm metadata
+	State  string   // Simplified state (running, paused, suspended, off, crashed)
+	Path   string   // Folder path from vroomm metadata (with a trailing slash)
+	OS     string   // Guest OS family (windows, linux, default)
+}
+
+// A single "key:value" condition. A term matches if any of its
+// comma-separated patterns match, and is inverted by a leading "-".
+type Term struct {
+	Field    string           // Field the term applies to
+	Negate   bool             // Whether the term must not match
+	Patterns []string         // Original patterns, for display
+	matchers []*regexp.Regexp // Compiled patterns
+}
+
+// A parsed query. A subject matches if every term matches, so the empty
+// query matches everything.
+type Query struct {
+	Text  string // Original query text
+	Terms []Term // Terms which must all match
+}
+
+// Parse a query such as `label:ci state:running path:/work/** name:web*`.
+// Terms without a key match the name. Values may be double quoted to include
+// spaces. Patterns support "*" and "?" within a path component and "**"
+// across components.
+func Parse(text string) (*Query, error) {
+	words, err := split(text)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{
+		Text:  strings.TrimSpace(text),
+		Terms: []Term{},
+	}
+
+	for _, word := range words {
+		term := Term{
+			Field: FieldName,
+		}
+
+		if strings.HasPrefix(word, "-") {
+			term.Negate = true
+			word = word[1:]
+		}
+
+		if key, value, found := strings.Cut(word, ":"); found && isField(strings.ToLower(key)) {
+			term.Field, word = strings.ToLower(key), value
+		} else if found {
+			return nil, fmt.Errorf("unknown query field '%v' (expected one of %v)", key, strings.Join(fields, ", "))
+		}
+
+		if word == "" {
+			return nil, fmt.Errorf("empty value for query field '%v'", term.Field)
+		}
+
+		for _, pattern := range strings.Split(word, ",") {
+			if pattern == "" {
+				continue
+			}
+			if term.Field == FieldPath {
+				pattern = normalizePath(pattern)
+			}
+			term.Patterns = append(term.Patterns, pattern)
+			term.matchers = append(term.matchers, compile(pattern, term.Field == FieldPath))
+		}
+
+		query.Terms = append(query.Terms, term)
+	}
+
+	return query, nil
+}
+
+// Check whether the subject satisfies every term of the query
+func (query *Query) Match(subject *Subject) bool {
+	for idx := range query.Terms {
+		if !query.Terms[idx].Match(subject) {
+			return false
+		}
+	}
+	return true
+}
+
+func (query *Query) String() string {
+	return query.Text
+}
+
+// Check whether the subject satisfies the term
+func (term *Term) Match(subject *Subject) bool {
+	values := []string{}
+	switch term.Field {
+	case FieldName:
+		values = append(values, subject.Name)
+	case FieldUUID:
+		values = append(values, subject.UUID)
+	case FieldLabel:
+		values = append(values, subject.Labels...)
+	case FieldState:
+		values = append(values, subject.State)
+	case FieldPath:
+		values = append(values, normalizePath(subject.Path))
+	case FieldOS:
+		values = append(values, subject.OS)
+	}
+
+	matched := false
+outer:
+	for _, matcher := range term.matchers {
+		for _, value := range values {
+			if matcher.MatchString(value) {
+				matched = true
+				break outer
+			}
+		}
+	}
+
+	return matched != term.Negate
+}
+
+func isField(key string) bool {
+	for _, field := range fields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Folder paths always start and end with a slash, except for patterns which
+// end in a wildcard and may match deeper folders.
+func normalizePath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if !strings.HasSuffix(path, "/") && !strings.HasSuffix(path, "*") {
+		path += "/"
+	}
+	return path
+}
+
+// Compile a glob pattern into an anchored regular expression. Names, labels
+// and states are matched case-insensitively; paths are not. Folder paths end
+// in a slash, so a path pattern ending in a wildcard (e.g. "/work/*") also
+// matches the trailing slash of the subfolders it names.
+func compile(pattern string, path bool) *regexp.Regexp {
+	var builder strings.Builder
+
+	builder.WriteString("^")
+	if !path {
+		builder.WriteString("(?i)")
+	}
+
+	for idx := 0; idx < len(pattern); idx++ {
+		switch {
+		case strings.HasPrefix(pattern[idx:], "**"):
+			builder.WriteString(".*")
+			idx++
+		case pattern[idx] == '*':
+			builder.WriteString("[^/]*")
+		case pattern[idx] == '?':
+			builder.WriteString("[^/]")
+		default:
+			builder.WriteString(regexp.QuoteMeta(pattern[idx : idx+1]))
+		}
+	}
+
+	if path && strings.HasSuffix(pattern, "*") {
+		builder.WriteString("/?")
+	}
+	builder.WriteString("$")
+
+	return regexp.MustCompile(builder.String())
+}
+
+// Split the query into whitespace separated words, honoring double quotes
+func split(text string) ([]string, error) {
+	words := []string{}
+	current := strings.Builder{}
+	quoted := false
+	inWord := false
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case unicode.IsSpace(r) && !quoted:
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in query '%v'", text)
+	} else if inWord {
+		words = append(words, current.String())
+	}
+
+	return words, nil
+}
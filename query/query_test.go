@@ -0,0 +1,114 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text    string
+		terms   int
+		wantErr bool
+	}{
+		{text: "", terms: 0},
+		{text: "web*", terms: 1},
+		{text: "label:ci -state:running", terms: 2},
+		{text: `name:"my vm" path:/work/**`, terms: 2},
+		{text: "label:a,b", terms: 1},
+		{text: "color:red", wantErr: true},
+		{text: "label:", wantErr: true},
+		{text: `name:"open`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		query, err := Parse(test.text)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q): expected an error", test.text)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.text, err)
+			continue
+		}
+
+		if len(query.Terms) != test.terms {
+			t.Errorf("Parse(%q): got %v terms, want %v", test.text, len(query.Terms), test.terms)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	subject := &Subject{
+		Name:   "Web-01",
+		UUID:   "0d5e4a7c-1b2f-4c3d-8e9f-a0b1c2d3e4f5",
+		Labels: []string{"ci", "linux"},
+		State:  "running",
+		Path:   "/work/web/",
+		OS:     "linux",
+	}
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "", want: true},
+		{text: "web-01", want: true},
+		{text: "web*", want: true},
+		{text: "web-0?", want: true},
+		{text: "db*", want: false},
+		{text: "name:db,web*", want: true},
+		{text: "uuid:0d5e4a7c-*", want: true},
+		{text: "label:ci", want: true},
+		{text: "label:windows", want: false},
+		{text: "-label:windows", want: true},
+		{text: "state:running os:linux", want: true},
+		{text: "state:running -os:linux", want: false},
+		{text: "path:/work/web", want: true},
+		{text: "path:work/web/", want: true},
+		{text: "path:/work", want: false},
+		{text: "path:/Work/web", want: false},
+		{text: "path:/work/*", want: true},
+		{text: "path:/work/w*", want: true},
+		{text: "path:/*", want: false},
+		{text: "path:/**", want: true},
+		{text: "path:/work/**", want: true},
+		{text: "path:/other/**", want: false},
+	}
+
+	for _, test := range tests {
+		query, err := Parse(test.text)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.text, err)
+			continue
+		}
+
+		if got := query.Match(subject); got != test.want {
+			t.Errorf("%q matching %+v: got %v, want %v", test.text, subject, got, test.want)
+		}
+	}
+}
+
+func TestMatchFolderWildcard(t *testing.T) {
+	query, err := Parse("path:/work/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/work/", want: true},
+		{path: "/work/web/", want: true},
+		{path: "/work/web/old/", want: false},
+		{path: "/", want: false},
+		{path: "/workshop/", want: false},
+	}
+
+	for _, test := range tests {
+		if got := query.Match(&Subject{Path: test.path}); got != test.want {
+			t.Errorf("path:/work/* matching %q: got %v, want %v", test.path, got, test.want)
+		}
+	}
+}
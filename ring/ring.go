@@ -0,0 +1,51 @@
+package ring
+
+// A fixed size buffer which overwrites the oldest value when full
+type Buffer[V any] struct {
+	values []V
+	start  int
+	length int
+}
+
+func New[V any](capacity int) *Buffer[V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Buffer[V]{
+		values: make([]V, capacity),
+	}
+}
+
+func (b *Buffer[V]) Push(values ...V) {
+	for _, v := range values {
+		if b.length < len(b.values) {
+			b.values[(b.start+b.length)%len(b.values)] = v
+			b.length++
+		} else {
+			b.values[b.start] = v
+			b.start = (b.start + 1) % len(b.values)
+		}
+	}
+}
+
+func (b *Buffer[V]) Len() int {
+	return b.length
+}
+
+// Return the most recent value, and whether the buffer had any values
+func (b *Buffer[V]) Last() (V, bool) {
+	var zero V
+	if b.length == 0 {
+		return zero, false
+	}
+	return b.values[(b.start+b.length-1)%len(b.values)], true
+}
+
+// Return the values from oldest to newest
+func (b *Buffer[V]) Array() []V {
+	result := make([]V, 0, b.length)
+	for i := 0; i < b.length; i++ {
+		result = append(result, b.values[(b.start+i)%len(b.values)])
+	}
+	return result
+}
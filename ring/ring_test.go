@@ -0,0 +1,53 @@
+package ring
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	tests := []struct {
+		capacity int
+		push     []int
+		want     []int
+	}{
+		{capacity: 3, push: nil, want: []int{}},
+		{capacity: 3, push: []int{1, 2}, want: []int{1, 2}},
+		{capacity: 3, push: []int{1, 2, 3}, want: []int{1, 2, 3}},
+		{capacity: 3, push: []int{1, 2, 3, 4, 5}, want: []int{3, 4, 5}},
+		{capacity: 3, push: []int{1, 2, 3, 4, 5, 6, 7}, want: []int{5, 6, 7}},
+		{capacity: 0, push: []int{1, 2}, want: []int{2}},
+	}
+
+	for _, test := range tests {
+		buffer := New[int](test.capacity)
+		buffer.Push(test.push...)
+
+		if got := buffer.Array(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("capacity %v, pushed %v: got %v, want %v", test.capacity, test.push, got, test.want)
+		}
+		if buffer.Len() != len(test.want) {
+			t.Errorf("capacity %v, pushed %v: got length %v, want %v", test.capacity, test.push, buffer.Len(), len(test.want))
+		}
+
+		last, ok := buffer.Last()
+		if len(test.want) == 0 {
+			if ok {
+				t.Errorf("capacity %v: empty buffer returned last value %v", test.capacity, last)
+			}
+		} else if !ok || last != test.want[len(test.want)-1] {
+			t.Errorf("capacity %v, pushed %v: got last %v (%v), want %v", test.capacity, test.push, last, ok, test.want[len(test.want)-1])
+		}
+	}
+}
+
+func TestBufferPushOneAtATime(t *testing.T) {
+	buffer := New[int](2)
+	for value := 1; value <= 5; value++ {
+		buffer.Push(value)
+	}
+
+	if got := buffer.Array(); !reflect.DeepEqual(got, []int{4, 5}) {
+		t.Errorf("got %v, want [4 5]", got)
+	}
+}
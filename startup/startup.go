@@ -0,0 +1,338 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/calebstewart/vroomm/config"
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+const (
+	shutdownPollInterval = time.Second
+)
+
+// Receives progress of a single domain. The error is set if the step failed.
+type Reporter func(domain string, message string, err error)
+
+// Starts groups of domains in dependency order and shuts them down in
+// reverse.
+type Runner struct {
+	Conn            *virt.Connection     // Libvirt connection
+	Hooks           *hooks.Runner        // Hooks run around start and shutdown
+	AddressSources  []virt.AddressSource // Where to look for addresses when waiting for an IP
+	ShutdownTimeout time.Duration        // How long to wait for a graceful shutdown before forcing a domain off
+	Report          Reporter             // Progress callback (may be nil)
+}
+
+// A domain and its startup metadata
+type node struct {
+	name    string
+	domain  *virt.Domain
+	startup *virt.StartupMetadata
+}
+
+func New(cfg *config.Config, conn *virt.Connection, runner *hooks.Runner, sources []virt.AddressSource, report Reporter) *Runner {
+	return &Runner{
+		Conn:            conn,
+		Hooks:           runner,
+		AddressSources:  sources,
+		ShutdownTimeout: cfg.Bulk.ShutdownTimeout,
+		Report:          report,
+	}
+}
+
+// Order domains into levels, where every domain only depends on domains in
+// earlier levels. If withDependencies is set, dependencies outside of the
+// given domains are added, and otherwise they are ignored. Fails if a
+// dependency does not exist or the dependencies form a cycle.
+func Plan(conn *virt.Connection, domains []*virt.Domain, withDependencies bool) ([][]*virt.Domain, error) {
+	nodes := map[string]*node{}
+	pending := []*virt.Domain{}
+	pending = append(pending, domains...)
+
+	for len(pending) > 0 {
+		domain := pending[0]
+		pending = pending[1:]
+
+		name, err := domain.GetName()
+		if err != nil {
+			return nil, err
+		} else if _, ok := nodes[name]; ok {
+			continue
+		}
+
+		nodes[name] = &node{
+			name:    name,
+			domain:  domain,
+			startup: domain.GetVmmData().Startup,
+		}
+
+		if !withDependencies || nodes[name].startup == nil {
+			continue
+		}
+
+		for _, dependency := range nodes[name].startup.DependsOn {
+			if _, ok := nodes[dependency]; ok {
+				continue
+			} else if dependencyDomain, err := conn.LookupDomain(dependency); err != nil {
+				return nil, fmt.Errorf("'%v' depends on unknown domain '%v'", name, dependency)
+			} else {
+				pending = append(pending, dependencyDomain)
+			}
+		}
+	}
+
+	return order(nodes)
+}
+
+// Order the nodes into levels of domains by their dependencies within the
+// nodes. Dependencies on domains outside of the nodes are ignored.
+func order(nodes map[string]*node) ([][]*virt.Domain, error) {
+	// Count the dependencies of each domain within the plan
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for name, node := range nodes {
+		remaining[name] = 0
+		if node.startup == nil {
+			continue
+		}
+		for _, dependency := range node.startup.DependsOn {
+			if _, ok := nodes[dependency]; ok && dependency != name {
+				remaining[name]++
+				dependents[dependency] = append(dependents[dependency], name)
+			} else if dependency == name {
+				return nil, fmt.Errorf("'%v' depends on itself", name)
+			}
+		}
+	}
+
+	levels := [][]*virt.Domain{}
+	for len(remaining) > 0 {
+		ready := []string{}
+		for name, count := range remaining {
+			if count == 0 {
+				ready = append(ready, name)
+			}
+		}
+
+		if len(ready) == 0 {
+			cycle := []string{}
+			for name := range remaining {
+				cycle = append(cycle, name)
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("dependency cycle between %v", strings.Join(cycle, ", "))
+		}
+
+		sort.Strings(ready)
+		level := []*virt.Domain{}
+		for _, name := range ready {
+			level = append(level, nodes[name].domain)
+			delete(remaining, name)
+			for _, dependent := range dependents[name] {
+				remaining[dependent]--
+			}
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
+
+// Check whether a domain depends on another, directly or through other
+// dependencies. Adding a dependency on a domain which depends on this one
+// would create a cycle.
+func DependsOn(conn *virt.Connection, name string, dependency string) (bool, error) {
+	visited := map[string]bool{}
+	pending := []string{name}
+
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		domain, err := conn.LookupDomain(current)
+		if err != nil {
+			if current == name {
+				return false, err
+			}
+			// Missing dependencies are reported when starting
+			continue
+		}
+
+		if startup := domain.GetVmmData().Startup; startup != nil {
+			for _, next := range startup.DependsOn {
+				if next == dependency {
+					return true, nil
+				}
+				pending = append(pending, next)
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Start the domains (and any domains they depend on) in dependency order.
+// Domains in the same level start concurrently. Each domain must become
+// ready before the next level starts, so startup stops at the first level
+// with a failure.
+func (r *Runner) Up(ctx context.Context, domains []*virt.Domain) error {
+	levels, err := Plan(r.Conn, domains, true)
+	if err != nil {
+		return err
+	}
+
+	for _, level := range levels {
+		if err := r.forEach(level, func(name string, domain *virt.Domain) error {
+			return r.up(ctx, name, domain)
+		}); err != nil {
+			return fmt.Errorf("startup stopped: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Shut the domains down in reverse dependency order. Dependencies outside
+// of the given domains are left running. Shutdown continues past failures,
+// since leaving more domains running would not help.
+func (r *Runner) Down(ctx context.Context, domains []*virt.Domain) error {
+	levels, err := Plan(r.Conn, domains, false)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for idx := len(levels) - 1; idx >= 0; idx-- {
+		if err := r.forEach(levels[idx], func(name string, domain *virt.Domain) error {
+			return r.down(ctx, name, domain)
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run the step for every domain of a level concurrently, and report the
+// result of each.
+func (r *Runner) forEach(level []*virt.Domain, step func(name string, domain *virt.Domain) error) error {
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(level))
+
+	for idx, domain := range level {
+		wg.Add(1)
+		go func(idx int, domain *virt.Domain) {
+			defer wg.Done()
+
+			name, err := domain.GetName()
+			if err == nil {
+				err = step(name, domain)
+			}
+			if err != nil {
+				errs[idx] = fmt.Errorf("%v: %w", name, err)
+				r.report(name, "", err)
+			}
+		}(idx, domain)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (r *Runner) up(ctx context.Context, name string, domain *virt.Domain) error {
+	startup := domain.GetVmmData().Startup
+
+	if active, err := domain.IsActive(); err != nil {
+		return err
+	} else if active {
+		r.report(name, "already running", nil)
+	} else if err := domain.CheckNotTemplate(); err != nil {
+		return err
+	} else {
+		if err := r.Hooks.Wrap(hooks.EventStart, domain, nil, domain.Create); err != nil {
+			return err
+		}
+		r.report(name, "started", nil)
+	}
+
+	if startup == nil {
+		return nil
+	}
+
+	if startup.Wait != virt.WaitNone {
+		waitCtx, cancel := context.WithTimeout(ctx, startup.ReadyTimeout())
+		defer cancel()
+
+		if err := domain.WaitReady(waitCtx, startup.Wait, r.AddressSources); err != nil {
+			return err
+		}
+		r.report(name, fmt.Sprintf("ready (%v)", startup.Wait), nil)
+	}
+
+	if startup.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(startup.Delay) * time.Second):
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) down(ctx context.Context, name string, domain *virt.Domain) error {
+	if active, err := domain.IsActive(); err != nil {
+		return err
+	} else if !active {
+		r.report(name, "already off", nil)
+		return nil
+	}
+
+	if err := r.Hooks.Wrap(hooks.EventShutdown, domain, nil, domain.Shutdown); err != nil {
+		return err
+	}
+
+	timeout := time.After(r.ShutdownTimeout)
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if active, err := domain.IsActive(); err != nil {
+			return err
+		} else if !active {
+			r.report(name, "shut down", nil)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			details := map[string]string{"force": "true"}
+			if err := r.Hooks.Wrap(hooks.EventShutdown, domain, details, domain.Destroy); err != nil {
+				return err
+			}
+			r.report(name, fmt.Sprintf("did not shut down within %v; forced off", r.ShutdownTimeout), nil)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) report(name string, message string, err error) {
+	if r.Report != nil {
+		r.Report(name, message, err)
+	}
+}
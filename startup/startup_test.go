@@ -0,0 +1,84 @@
+package startup
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/calebstewart/vroomm/virt"
+)
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    map[string][]string // Domains and their dependencies (nil means no startup metadata)
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "independent",
+			deps: map[string][]string{"b": nil, "a": nil, "c": {}},
+			want: [][]string{{"a", "b", "c"}},
+		},
+		{
+			name: "chain",
+			deps: map[string][]string{"web": {"app"}, "app": {"db"}, "db": nil},
+			want: [][]string{{"db"}, {"app"}, {"web"}},
+		},
+		{
+			name: "diamond",
+			deps: map[string][]string{"web": {"api", "auth"}, "api": {"db"}, "auth": {"db"}, "db": nil},
+			want: [][]string{{"db"}, {"api", "auth"}, {"web"}},
+		},
+		{
+			name: "outside dependency",
+			deps: map[string][]string{"web": {"db"}},
+			want: [][]string{{"web"}},
+		},
+		{
+			name:    "self",
+			deps:    map[string][]string{"a": {"a"}},
+			wantErr: true,
+		},
+		{
+			name:    "cycle",
+			deps:    map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}, "d": nil},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		nodes := map[string]*node{}
+		names := map[*virt.Domain]string{}
+		for name, deps := range test.deps {
+			nodes[name] = &node{name: name, domain: &virt.Domain{}}
+			if deps != nil {
+				nodes[name].startup = &virt.StartupMetadata{DependsOn: deps}
+			}
+			names[nodes[name].domain] = name
+		}
+
+		levels, err := order(nodes)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected an error", test.name)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		got := [][]string{}
+		for _, level := range levels {
+			levelNames := []string{}
+			for _, domain := range level {
+				levelNames = append(levelNames, names[domain])
+			}
+			got = append(got, levelNames)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
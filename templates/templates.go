@@ -0,0 +1,134 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/calebstewart/vroomm/cloudinit"
+	"github.com/calebstewart/vroomm/config"
+	"github.com/calebstewart/vroomm/folders"
+	"github.com/calebstewart/vroomm/hooks"
+	"github.com/calebstewart/vroomm/virt"
+)
+
+// Per-instance settings for a VM created from a template
+type Instance struct {
+	Name   string               // Domain name
+	Linked bool                 // Linked (copy-on-write) clone instead of a full copy
+	Folder string               // Folder of the new VM
+	Labels []string             // Labels of the new VM
+	Seed   *cloudinit.Variables // Cloud-init seed settings (no seed if nil)
+	Start  bool                 // Start the VM once it is created
+}
+
+// Creates VMs from templates
+type Builder struct {
+	Conn   *virt.Connection  // Libvirt connection
+	Hooks  *hooks.Runner     // Hooks run around the clone and start
+	Seeder *cloudinit.Seeder // Builds cloud-init seeds
+}
+
+func New(cfg *config.Config, conn *virt.Connection, runner *hooks.Runner) *Builder {
+	return &Builder{
+		Conn:   conn,
+		Hooks:  runner,
+		Seeder: cloudinit.New(cfg, conn),
+	}
+}
+
+// Return all templates, sorted by name
+func List(conn *virt.Connection) ([]*virt.Domain, error) {
+	domains, err := conn.EnumerateAllDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	templates := []*virt.Domain{}
+	names := map[*virt.Domain]string{}
+	for _, domain := range domains {
+		if !domain.IsTemplate() {
+			continue
+		} else if name, err := domain.GetName(); err != nil {
+			return nil, err
+		} else {
+			names[domain] = name
+			templates = append(templates, domain)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return names[templates[i]] < names[templates[j]]
+	})
+	return templates, nil
+}
+
+// Clone a new VM from a template and customize it. A failure to start the
+// new VM is returned along with the VM, which is kept.
+func (b *Builder) Create(template *virt.Domain, instance Instance) (*virt.Domain, error) {
+	if !template.IsTemplate() {
+		return nil, errors.New("VM is not a template")
+	} else if instance.Name == "" {
+		return nil, errors.New("a name is required")
+	} else if _, err := b.Conn.LookupDomainByName(instance.Name); err == nil {
+		return nil, fmt.Errorf("VM '%v' already exists", instance.Name)
+	} else if instance.Seed != nil {
+		// Catch bad seed settings before cloning; the clone is named after the instance
+		vars := *instance.Seed
+		if vars.Hostname == "" {
+			vars.Hostname = instance.Name
+		}
+		if err := vars.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var domain *virt.Domain
+	details := map[string]string{"clone": instance.Name, "linked": fmt.Sprintf("%v", instance.Linked), "template": "true"}
+	if err := b.Hooks.Wrap(hooks.EventClone, template, details, func() (err error) {
+		if domain, err = template.Clone(b.Conn, instance.Name, instance.Linked); err == nil {
+			details["clone_uuid"], _ = domain.GetUUIDString()
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := b.customize(domain, instance); err != nil {
+		return nil, errors.Join(err, domain.Delete(b.Conn, true))
+	}
+
+	if instance.Start {
+		if err := b.Hooks.Wrap(hooks.EventStart, domain, nil, domain.Create); err != nil {
+			return domain, fmt.Errorf("created '%v', but failed to start it: %w", instance.Name, err)
+		}
+	}
+
+	return domain, nil
+}
+
+func (b *Builder) customize(domain *virt.Domain, instance Instance) error {
+	if err := domain.ResetMACs(b.Conn); err != nil {
+		return err
+	}
+
+	labels := instance.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+
+	metadata := domain.GetVmmData()
+	metadata.Template = false
+	metadata.Path = folders.Normalize(instance.Folder)
+	metadata.Labels = labels
+	if err := domain.UpdateVmmData(metadata); err != nil {
+		return err
+	}
+
+	if instance.Seed == nil {
+		return nil
+	}
+
+	_, err := b.Seeder.Attach(domain, *instance.Seed)
+	return err
+}
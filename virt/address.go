@@ -0,0 +1,91 @@
+package virt
+
+import (
+	"errors"
+	"fmt"
+
+	"libvirt.org/go/libvirt"
+)
+
+// Where libvirt should look for a domain's IP addresses
+type AddressSource string
+
+const (
+	AddressSourceAgent AddressSource = "agent" // Ask the QEMU guest agent
+	AddressSourceLease AddressSource = "lease" // Look up DHCP leases of libvirt networks
+	AddressSourceARP   AddressSource = "arp"   // Look up the host ARP table
+)
+
+var (
+	DefaultAddressSources = []AddressSource{AddressSourceAgent, AddressSourceLease, AddressSourceARP}
+
+	addressSourceFlags = map[AddressSource]libvirt.DomainInterfaceAddressesSource{
+		AddressSourceAgent: libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_AGENT,
+		AddressSourceLease: libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_LEASE,
+		AddressSourceARP:   libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_ARP,
+	}
+)
+
+// A single address of a domain interface and where it was discovered
+type InterfaceAddress struct {
+	Interface string        // Interface name (guest name for the agent, host device otherwise)
+	MAC       string        // Hardware address of the interface
+	Address   string        // IP address
+	Prefix    uint          // Network prefix length
+	Source    AddressSource // Where the address came from
+}
+
+// Parse a list of address source names, as found in the configuration
+func ParseAddressSources(names []string) ([]AddressSource, error) {
+	sources := []AddressSource{}
+	for _, name := range names {
+		source := AddressSource(name)
+		if _, ok := addressSourceFlags[source]; !ok {
+			return nil, fmt.Errorf("unknown address source '%v'", name)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// Query each address source in order, and return the addresses from the
+// first source which reports any. Loopback interfaces are ignored. An error
+// is only returned if every source failed.
+func (dom *Domain) ResolveAddresses(sources []AddressSource) ([]InterfaceAddress, error) {
+	errs := []error{}
+
+	for _, source := range sources {
+		interfaces, err := dom.ListAllInterfaceAddresses(addressSourceFlags[source])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", source, err))
+			continue
+		}
+
+		addresses := []InterfaceAddress{}
+		for _, iface := range interfaces {
+			if iface.Name == "lo" {
+				continue
+			}
+
+			for _, addr := range iface.Addrs {
+				addresses = append(addresses, InterfaceAddress{
+					Interface: iface.Name,
+					MAC:       iface.Hwaddr,
+					Address:   addr.Addr,
+					Prefix:    addr.Prefix,
+					Source:    source,
+				})
+			}
+		}
+
+		if len(addresses) > 0 {
+			return addresses, nil
+		}
+	}
+
+	if len(errs) == len(sources) && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return []InterfaceAddress{}, nil
+}
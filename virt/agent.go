@@ -0,0 +1,210 @@
+package virt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"libvirt.org/go/libvirt"
+)
+
+const (
+	agentPollInterval = 200 * time.Millisecond
+	agentFileChunk    = 48 * 1024
+)
+
+// The result of a command run in the guest through the guest agent
+type GuestExecResult struct {
+	ExitCode  int    // Exit code of the process
+	Signal    int    // Signal which terminated the process (if any)
+	Stdout    []byte // Captured standard output
+	Stderr    []byte // Captured standard error
+	Truncated bool   // Whether the agent truncated the captured output
+}
+
+type agentRequest struct {
+	Execute   string `json:"execute"`
+	Arguments any    `json:"arguments,omitempty"`
+}
+
+type agentResponse struct {
+	Return json.RawMessage `json:"return"`
+}
+
+// Run a raw guest agent command and decode its return value into result,
+// which may be nil.
+func (dom *Domain) agentCommand(command string, arguments any, result any) error {
+	request, err := json.Marshal(agentRequest{
+		Execute:   command,
+		Arguments: arguments,
+	})
+	if err != nil {
+		return err
+	}
+
+	responseText, err := dom.QemuAgentCommand(string(request), libvirt.DOMAIN_QEMU_AGENT_COMMAND_DEFAULT, 0)
+	if err != nil {
+		return err
+	}
+
+	response := agentResponse{}
+	if err := json.Unmarshal([]byte(responseText), &response); err != nil {
+		return fmt.Errorf("%v: malformed agent response: %w", command, err)
+	} else if result != nil {
+		if err := json.Unmarshal(response.Return, result); err != nil {
+			return fmt.Errorf("%v: malformed agent response: %w", command, err)
+		}
+	}
+
+	return nil
+}
+
+// Run a program in the guest and wait for it to exit, capturing its output.
+// The stdin data (if any) is passed to the program as its standard input.
+func (dom *Domain) GuestExec(ctx context.Context, path string, args []string, stdin []byte) (*GuestExecResult, error) {
+	arguments := map[string]any{
+		"path":           path,
+		"arg":            args,
+		"capture-output": true,
+	}
+	if len(stdin) > 0 {
+		arguments["input-data"] = base64.StdEncoding.EncodeToString(stdin)
+	}
+
+	started := struct {
+		PID int `json:"pid"`
+	}{}
+	if err := dom.agentCommand("guest-exec", arguments, &started); err != nil {
+		return nil, err
+	}
+
+	for {
+		status := struct {
+			Exited       bool   `json:"exited"`
+			ExitCode     int    `json:"exitcode"`
+			Signal       int    `json:"signal"`
+			OutData      string `json:"out-data"`
+			ErrData      string `json:"err-data"`
+			OutTruncated bool   `json:"out-truncated"`
+			ErrTruncated bool   `json:"err-truncated"`
+		}{}
+
+		if err := dom.agentCommand("guest-exec-status", map[string]any{"pid": started.PID}, &status); err != nil {
+			return nil, err
+		}
+
+		if status.Exited {
+			result := &GuestExecResult{
+				ExitCode:  status.ExitCode,
+				Signal:    status.Signal,
+				Truncated: status.OutTruncated || status.ErrTruncated,
+			}
+
+			var err error
+			if result.Stdout, err = base64.StdEncoding.DecodeString(status.OutData); err != nil {
+				return nil, err
+			} else if result.Stderr, err = base64.StdEncoding.DecodeString(status.ErrData); err != nil {
+				return nil, err
+			}
+
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("guest process %v: %w", started.PID, ctx.Err())
+		case <-time.After(agentPollInterval):
+		}
+	}
+}
+
+func (dom *Domain) guestFileOpen(path string, mode string) (int, error) {
+	var handle int
+	err := dom.agentCommand("guest-file-open", map[string]any{"path": path, "mode": mode}, &handle)
+	return handle, err
+}
+
+func (dom *Domain) guestFileClose(handle int) error {
+	return dom.agentCommand("guest-file-close", map[string]any{"handle": handle}, nil)
+}
+
+// Copy a file out of the guest, returning the number of bytes copied
+func (dom *Domain) GuestReadFile(path string, writer io.Writer) (int64, error) {
+	handle, err := dom.guestFileOpen(path, "r")
+	if err != nil {
+		return 0, err
+	}
+	defer dom.guestFileClose(handle)
+
+	total := int64(0)
+	for {
+		chunk := struct {
+			Count int    `json:"count"`
+			Data  string `json:"buf-b64"`
+			EOF   bool   `json:"eof"`
+		}{}
+
+		if err := dom.agentCommand("guest-file-read", map[string]any{"handle": handle, "count": agentFileChunk}, &chunk); err != nil {
+			return total, err
+		}
+
+		if data, err := base64.StdEncoding.DecodeString(chunk.Data); err != nil {
+			return total, err
+		} else if n, err := writer.Write(data); err != nil {
+			return total + int64(n), err
+		} else {
+			total += int64(n)
+		}
+
+		if chunk.EOF || chunk.Count == 0 {
+			return total, nil
+		}
+	}
+}
+
+// Copy a file into the guest, replacing it if it exists. The number of bytes
+// copied is returned.
+func (dom *Domain) GuestWriteFile(path string, reader io.Reader) (int64, error) {
+	handle, err := dom.guestFileOpen(path, "w")
+	if err != nil {
+		return 0, err
+	}
+
+	total := int64(0)
+	buffer := make([]byte, agentFileChunk)
+	for {
+		n, readErr := reader.Read(buffer)
+		if n > 0 {
+			written := struct {
+				Count int `json:"count"`
+			}{}
+
+			if err := dom.agentCommand("guest-file-write", map[string]any{
+				"handle":  handle,
+				"buf-b64": base64.StdEncoding.EncodeToString(buffer[:n]),
+			}, &written); err != nil {
+				dom.guestFileClose(handle)
+				return total, err
+			}
+			total += int64(written.Count)
+		}
+
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			dom.guestFileClose(handle)
+			return total, readErr
+		}
+	}
+
+	// Closing flushes the file, so its error matters here
+	return total, dom.guestFileClose(handle)
+}
+
+// Set the password of a user account in the guest
+func (dom *Domain) SetGuestPassword(user string, password string) error {
+	return dom.SetUserPassword(user, password, 0)
+}
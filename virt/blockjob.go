@@ -0,0 +1,328 @@
+package virt
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// Called periodically while a block job runs with the number of bytes
+// processed so far and the total number of bytes to process. Either value
+// may be zero before the hypervisor has calculated the job size.
+type BlockJobProgress func(current uint64, end uint64)
+
+// Return the target device names of all disks backed by a volume which
+// has a backing file (i.e. linked clones and external snapshot overlays).
+func (dom *Domain) BackedDisks(virt *Connection) ([]string, error) {
+	disks, err := dom.Disks("disk")
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []string{}
+	for idx := range disks {
+		if backing, err := volumeBackingPath(virt, DiskSourcePath(&disks[idx])); err != nil {
+			return nil, err
+		} else if backing != "" {
+			targets = append(targets, disks[idx].Target.Dev)
+		}
+	}
+
+	return targets, nil
+}
+
+// Make the disk independent of its backing chain. For running domains, this
+// pulls all data from the backing chain into the active image with a block
+// pull job. For stopped domains, the disk is copied into a new standalone
+// volume (which flattens the chain), the domain is pointed at the new volume
+// and the old overlay is removed. Internal qcow2 snapshots stored in the old
+// overlay do not survive an offline copy.
+func (dom *Domain) Flatten(virt *Connection, target string, progress BlockJobProgress) error {
+	disk, err := dom.LookupDisk(target)
+	if err != nil {
+		return err
+	}
+
+	path := DiskSourcePath(disk)
+	if backing, err := volumeBackingPath(virt, path); err != nil {
+		return err
+	} else if backing == "" {
+		return fmt.Errorf("disk '%v' has no backing file", target)
+	}
+
+	if active, err := dom.IsActive(); err != nil {
+		return err
+	} else if active {
+		return dom.runBlockJob(virt, target, progress, false, func() error {
+			return dom.BlockPull(target, 0, 0)
+		})
+	}
+
+	return dom.flattenOffline(virt, target, path)
+}
+
+// Merge the active overlay of a running domain's disk into its immediate
+// backing image, then switch the domain over to the backing image. The
+// backing image must not be shared with any other volume or domain,
+// otherwise everything else built on it would be corrupted. The now unused overlay volume
+// is removed afterwards.
+func (dom *Domain) Commit(virt *Connection, target string, progress BlockJobProgress) error {
+	if active, err := dom.IsActive(); err != nil {
+		return err
+	} else if !active {
+		return fmt.Errorf("block commit requires a running domain")
+	}
+
+	disk, err := dom.LookupDisk(target)
+	if err != nil {
+		return err
+	}
+
+	path := DiskSourcePath(disk)
+	backing, err := volumeBackingPath(virt, path)
+	if err != nil {
+		return err
+	} else if backing == "" {
+		return fmt.Errorf("disk '%v' has no backing file", target)
+	}
+
+	volumes, err := virt.collectVolumes()
+	if err != nil {
+		return err
+	} else if _, ok := volumes[backing]; !ok {
+		return fmt.Errorf("backing image '%v' is not in an active storage pool, so it can't be checked for other users", backing)
+	}
+
+	users := []string{}
+	for volumePath, volume := range volumes {
+		if volume.BackingPath == backing && volumePath != path {
+			users = append(users, volume.Name)
+		}
+	}
+
+	// Domains may use the backing image directly (e.g. the VM or template
+	// this one was cloned from), or through their own backing chain
+	name, err := dom.GetName()
+	if err != nil {
+		return err
+	}
+	diskUsers, err := virt.collectDiskUsers()
+	if err != nil {
+		return err
+	}
+	for _, user := range diskUsers[backing] {
+		if user != name {
+			users = append(users, user)
+		}
+	}
+
+	if len(users) > 0 {
+		return fmt.Errorf("backing image '%v' is shared with %v", backing, strings.Join(users, ", "))
+	}
+
+	err = dom.runBlockJob(virt, target, progress, true, func() error {
+		return dom.BlockCommit(target, "", "", 0, libvirt.DOMAIN_BLOCK_COMMIT_ACTIVE|libvirt.DOMAIN_BLOCK_COMMIT_SHALLOW)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The persistent definition still points at the old overlay
+	if err := dom.setDiskSource(virt, target, backing); err != nil {
+		return err
+	}
+
+	return virt.DeleteVolume(path)
+}
+
+// Start a block job and wait for it to finish while reporting progress.
+// Completion and failure are taken from block job events, while progress is
+// polled since libvirt only emits events on state changes. Jobs which reach
+// the ready state (i.e. active commits) are pivoted if pivot is set. A job
+// which vanishes without a final event, or whose VM stops, is an error.
+func (dom *Domain) runBlockJob(virt *Connection, target string, progress BlockJobProgress, pivot bool, start func() error) error {
+	// A job ends only once, so its final event always fits. Ready events
+	// may repeat and are only needed once.
+	final := make(chan libvirt.ConnectDomainEventBlockJobStatus, 1)
+	ready := make(chan struct{}, 1)
+
+	callbackId, err := virt.DomainEventBlockJob2Register(&dom.Domain, func(_ *libvirt.Connect, _ *libvirt.Domain, event *libvirt.DomainEventBlockJob) {
+		if event.Disk != target {
+			return
+		}
+
+		// Never block the event loop, even if we already stopped listening
+		if event.Status == libvirt.DOMAIN_BLOCK_JOB_READY {
+			select {
+			case ready <- struct{}{}:
+			default:
+			}
+		} else {
+			select {
+			case final <- event.Status:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer virt.DomainEventDeregister(callbackId)
+
+	if err := start(); err != nil {
+		return err
+	}
+
+	finish := func(event libvirt.ConnectDomainEventBlockJobStatus) error {
+		switch event {
+		case libvirt.DOMAIN_BLOCK_JOB_FAILED:
+			return fmt.Errorf("block job on '%v' failed", target)
+		case libvirt.DOMAIN_BLOCK_JOB_CANCELED:
+			return fmt.Errorf("block job on '%v' was cancelled", target)
+		default:
+			return nil
+		}
+	}
+
+	for {
+		select {
+		case event := <-final:
+			return finish(event)
+		case <-ready:
+			if pivot {
+				if err := dom.BlockJobAbort(target, libvirt.DOMAIN_BLOCK_JOB_ABORT_PIVOT); err != nil {
+					return err
+				}
+			}
+		case <-time.After(500 * time.Millisecond):
+			info, err := dom.GetBlockJobInfo(target, 0)
+			if err != nil {
+				if active, activeErr := dom.IsActive(); activeErr == nil && !active {
+					return fmt.Errorf("VM stopped during the block job on '%v'", target)
+				}
+				continue
+			} else if info.Type == 0 {
+				// The job is gone. Its final event may still be on the way.
+				select {
+				case event := <-final:
+					return finish(event)
+				case <-time.After(time.Second):
+					return fmt.Errorf("block job on '%v' ended without reporting a result", target)
+				}
+			} else if progress != nil {
+				progress(info.Cur, info.End)
+			}
+		}
+	}
+}
+
+func (dom *Domain) flattenOffline(virt *Connection, target string, path string) error {
+	volume, err := virt.LookupStorageVolByPath(path)
+	if err != nil {
+		return err
+	}
+	defer volume.Free()
+
+	pool, err := volume.LookupPoolByVolume()
+	if err != nil {
+		return err
+	}
+	defer pool.Free()
+
+	name, err := volume.GetName()
+	if err != nil {
+		return err
+	}
+
+	newVolumeDescription := libvirtxml.StorageVolume{
+		Name: fmt.Sprintf("%v-flat.qcow2", strings.TrimSuffix(name, filepath.Ext(name))),
+		Target: &libvirtxml.StorageVolumeTarget{
+			Format: &libvirtxml.StorageVolumeTargetFormat{
+				Type: "qcow2",
+			},
+		},
+	}
+
+	var newVolume *libvirt.StorageVol
+	if xmlDesc, err := xml.Marshal(&newVolumeDescription); err != nil {
+		return err
+	} else if newVolume, err = pool.StorageVolCreateXMLFrom(string(xmlDesc), volume, 0); err != nil {
+		return err
+	}
+	defer newVolume.Free()
+
+	newPath, err := newVolume.GetPath()
+	if err != nil {
+		return errors.Join(err, dom.cleanupVolumes(virt, []*libvirt.StorageVol{newVolume}))
+	}
+
+	if err := dom.setDiskSource(virt, target, newPath); err != nil {
+		return errors.Join(err, dom.cleanupVolumes(virt, []*libvirt.StorageVol{newVolume}))
+	}
+
+	return volume.Delete(libvirt.STORAGE_VOL_DELETE_NORMAL)
+}
+
+// Point the persistent definition of a disk at a new file
+func (dom *Domain) setDiskSource(virt *Connection, target string, path string) error {
+	description, err := dom.GetDescription(libvirt.DOMAIN_XML_INACTIVE | libvirt.DOMAIN_XML_SECURE)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for idx := range description.Devices.Disks {
+		disk := &description.Devices.Disks[idx]
+		if disk.Target != nil && disk.Target.Dev == target {
+			disk.Source = &libvirtxml.DomainDiskSource{
+				File: &libvirtxml.DomainDiskSourceFile{
+					File: path,
+				},
+			}
+			disk.BackingStore = nil
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no disk with target '%v'", target)
+	}
+
+	if xmlDesc, err := xml.Marshal(description); err != nil {
+		return err
+	} else if _, err := virt.DomainDefineXML(string(xmlDesc)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Return the backing file of the volume at path, or an empty string if the
+// volume has no backing file or is not managed by a storage pool.
+func volumeBackingPath(virt *Connection, path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	volume, err := virt.LookupStorageVolByPath(path)
+	if err != nil {
+		return "", nil
+	}
+	defer volume.Free()
+
+	description := libvirtxml.StorageVolume{}
+	if xmlDesc, err := volume.GetXMLDesc(0); err != nil {
+		return "", err
+	} else if err := xml.Unmarshal([]byte(xmlDesc), &description); err != nil {
+		return "", err
+	} else if description.BackingStore == nil {
+		return "", nil
+	} else {
+		return description.BackingStore.Path, nil
+	}
+}
@@ -0,0 +1,69 @@
+package virt
+
+import (
+	"io"
+
+	"libvirt.org/go/libvirt"
+)
+
+// A domain's serial console, connected through a libvirt stream. It can be
+// used as an io.ReadWriteCloser.
+type Console struct {
+	stream *libvirt.Stream
+}
+
+// Connect to a console of the running domain. An empty device selects the
+// first console. With force, any existing console session is disconnected.
+func (dom *Domain) OpenConsole(virt *Connection, device string, force bool) (*Console, error) {
+	stream, err := virt.NewStream(0)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := libvirt.DOMAIN_CONSOLE_SAFE
+	if force {
+		flags |= libvirt.DOMAIN_CONSOLE_FORCE
+	}
+
+	if err := dom.Domain.OpenConsole(device, stream, flags); err != nil {
+		stream.Free()
+		return nil, err
+	}
+
+	return &Console{
+		stream: stream,
+	}, nil
+}
+
+func (console *Console) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err := console.stream.Recv(p)
+	if err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+func (console *Console) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n, err := console.stream.Send(p[written:])
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+func (console *Console) Close() error {
+	// Aborting is fine, since there is nothing buffered worth flushing
+	console.stream.Abort()
+	return console.stream.Free()
+}
@@ -0,0 +1,217 @@
+package virt
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// Delete the domain, forcing it off first if it is running. Snapshot,
+// checkpoint and managed save data is removed with it, as is NVRAM data no
+// other domain shares. If
+// removeStorage is set, the writable disks of the domain are deleted too,
+// unless another domain uses them or another volume is stacked on them
+// (e.g. as a linked clone backing image). Read-only disks such as install
+// media are always kept.
+func (dom *Domain) Delete(virt *Connection, removeStorage bool) error {
+	name, err := dom.GetName()
+	if err != nil {
+		return err
+	}
+
+	volumes := []string{}
+	if removeStorage {
+		if volumes, err = dom.ownedDisks(virt, name); err != nil {
+			return err
+		}
+	}
+
+	if active, err := dom.IsActive(); err != nil {
+		return err
+	} else if active {
+		if err := dom.Destroy(); err != nil {
+			return err
+		}
+	}
+
+	// Older clones share the UEFI variables of their source
+	flags := libvirt.DOMAIN_UNDEFINE_MANAGED_SAVE |
+		libvirt.DOMAIN_UNDEFINE_SNAPSHOTS_METADATA |
+		libvirt.DOMAIN_UNDEFINE_CHECKPOINTS_METADATA
+	if shared, err := dom.sharesNVRAM(virt, name); err != nil {
+		return err
+	} else if shared {
+		flags |= libvirt.DOMAIN_UNDEFINE_KEEP_NVRAM
+	} else {
+		flags |= libvirt.DOMAIN_UNDEFINE_NVRAM
+	}
+
+	if err := dom.UndefineFlags(flags); err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, path := range volumes {
+		if err := virt.DeleteVolume(path); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Check whether another domain uses the same UEFI variable store
+func (dom *Domain) sharesNVRAM(virt *Connection, name string) (bool, error) {
+	path, err := dom.nvramPath()
+	if err != nil || path == "" {
+		return false, err
+	}
+
+	domains, err := virt.EnumerateAllDomains()
+	if err != nil {
+		return false, err
+	}
+
+	for _, domain := range domains {
+		if other, err := domain.GetName(); err != nil {
+			return false, err
+		} else if other == name {
+			continue
+		} else if otherPath, err := domain.nvramPath(); err != nil {
+			return false, err
+		} else if otherPath == path {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Return the path of the UEFI variable store in the persistent definition
+func (dom *Domain) nvramPath() (string, error) {
+	description := &libvirtxml.Domain{}
+	if xmlDesc, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE); err != nil {
+		return "", err
+	} else if err := xml.Unmarshal([]byte(xmlDesc), description); err != nil {
+		return "", err
+	}
+
+	if description.OS == nil || description.OS.NVRam == nil {
+		return "", nil
+	} else if nvram := description.OS.NVRam; nvram.Source != nil && nvram.Source.File != nil {
+		return nvram.Source.File.File, nil
+	} else {
+		return nvram.NVRam, nil
+	}
+}
+
+// Find the writable disks of the domain, and the cloud-init seed created
+// for it, which no other domain uses and which back no other volume
+func (dom *Domain) ownedDisks(virt *Connection, name string) ([]string, error) {
+	paths, err := dom.writableDisks(virt)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := virt.collectDiskUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	backing, err := virt.backingPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	// Seeds are attached read-only, but belong to the domain they were made for
+	if seed := dom.GetVmmData().Seed; seed != "" {
+		paths[seed] = struct{}{}
+	}
+
+	owned := []string{}
+outer:
+	for path := range paths {
+		if _, ok := backing[path]; ok {
+			continue
+		}
+		for _, user := range users[path] {
+			if user != name {
+				continue outer
+			}
+		}
+		owned = append(owned, path)
+	}
+
+	return owned, nil
+}
+
+// Return an error if another volume is stacked on one of the writable disks
+// of the domain, since changing the disk (e.g. by reverting a snapshot)
+// would corrupt that volume.
+func (dom *Domain) CheckNoDependents(virt *Connection) error {
+	paths, err := dom.writableDisks(virt)
+	if err != nil {
+		return err
+	}
+
+	backing, err := virt.backingPaths()
+	if err != nil {
+		return err
+	}
+
+	for path := range paths {
+		if _, ok := backing[path]; ok {
+			return fmt.Errorf("disk '%v' is the backing image of other volumes", path)
+		}
+	}
+	return nil
+}
+
+// Return the paths of the writable disks in the persistent definition
+func (dom *Domain) writableDisks(virt *Connection) (map[string]struct{}, error) {
+	description := &libvirtxml.Domain{}
+	if xmlDesc, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE); err != nil {
+		return nil, err
+	} else if err := xml.Unmarshal([]byte(xmlDesc), description); err != nil {
+		return nil, err
+	}
+
+	paths := map[string]struct{}{}
+	if description.Devices != nil {
+		for _, disk := range description.Devices.Disks {
+			if disk.ReadOnly == nil && (disk.Device == "" || disk.Device == "disk") {
+				virt.collectSourcePaths(paths, disk.Source)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// Return the backing paths of all volumes. Inactive definitions don't list
+// backing chains, so volumes stacked on a disk are found through the pools.
+func (c *Connection) backingPaths() (map[string]struct{}, error) {
+	volumes, err := c.collectVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	backing := map[string]struct{}{}
+	for _, volume := range volumes {
+		if volume.BackingPath != "" {
+			backing[volume.BackingPath] = struct{}{}
+		}
+	}
+	return backing, nil
+}
+
+// Return the names of all domains using the given disk image
+func (c *Connection) DiskUsers(path string) ([]string, error) {
+	users, err := c.collectDiskUsers()
+	if err != nil {
+		return nil, err
+	}
+	return users[path], nil
+}
@@ -0,0 +1,314 @@
+package virt
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// Prefixes used for disk target device names on each bus type
+var diskTargetPrefixes = map[string]string{
+	"virtio": "vd",
+	"sata":   "sd",
+	"scsi":   "sd",
+	"usb":    "sd",
+	"ide":    "hd",
+}
+
+// Parse the domain XML description
+func (dom *Domain) GetDescription(flags libvirt.DomainXMLFlags) (*libvirtxml.Domain, error) {
+	description := &libvirtxml.Domain{}
+	if xmlDesc, err := dom.GetXMLDesc(flags); err != nil {
+		return nil, err
+	} else if err := xml.Unmarshal([]byte(xmlDesc), description); err != nil {
+		return nil, err
+	}
+
+	if description.Devices == nil {
+		description.Devices = &libvirtxml.DomainDeviceList{}
+	}
+
+	return description, nil
+}
+
+// Flags used for device changes. Changes always apply to the persistent
+// configuration, and additionally to the live domain if it is running.
+func (dom *Domain) deviceModifyFlags() libvirt.DomainDeviceModifyFlags {
+	flags := libvirt.DOMAIN_DEVICE_MODIFY_CONFIG
+	if active, err := dom.IsActive(); err == nil && active {
+		flags |= libvirt.DOMAIN_DEVICE_MODIFY_LIVE
+	}
+	return flags
+}
+
+// Return all disks of the given device type ("disk", "cdrom", etc). An
+// empty device type returns all disks.
+func (dom *Domain) Disks(device string) ([]libvirtxml.DomainDisk, error) {
+	description, err := dom.GetDescription(0)
+	if err != nil {
+		return nil, err
+	}
+
+	disks := []libvirtxml.DomainDisk{}
+	for _, disk := range description.Devices.Disks {
+		if disk.Target == nil {
+			continue
+		} else if device != "" && disk.Device != device && !(device == "disk" && disk.Device == "") {
+			continue
+		}
+		disks = append(disks, disk)
+	}
+
+	return disks, nil
+}
+
+// Find a disk by its target device name (e.g. "vda")
+func (dom *Domain) LookupDisk(target string) (*libvirtxml.DomainDisk, error) {
+	disks, err := dom.Disks("")
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range disks {
+		if disks[idx].Target.Dev == target {
+			return &disks[idx], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no disk with target '%v'", target)
+}
+
+// Return the host path backing a disk, or an empty string for disks with
+// no local source (e.g. network disks or empty CD-ROM drives).
+func DiskSourcePath(disk *libvirtxml.DomainDisk) string {
+	if disk.Source == nil {
+		return ""
+	} else if disk.Source.File != nil {
+		return disk.Source.File.File
+	} else if disk.Source.Block != nil {
+		return disk.Source.Block.Dev
+	} else if disk.Source.Volume != nil {
+		return fmt.Sprintf("%v/%v", disk.Source.Volume.Pool, disk.Source.Volume.Volume)
+	}
+	return ""
+}
+
+// Pick the first unused target device name on the given bus
+func (dom *Domain) nextDiskTarget(bus string) (string, error) {
+	prefix, ok := diskTargetPrefixes[bus]
+	if !ok {
+		return "", fmt.Errorf("unsupported disk bus '%v'", bus)
+	}
+
+	disks, err := dom.Disks("")
+	if err != nil {
+		return "", err
+	}
+
+	used := map[string]struct{}{}
+	for _, disk := range disks {
+		used[disk.Target.Dev] = struct{}{}
+	}
+
+	for letter := 'a'; letter <= 'z'; letter++ {
+		target := fmt.Sprintf("%v%c", prefix, letter)
+		if _, ok := used[target]; !ok {
+			return target, nil
+		}
+	}
+
+	return "", fmt.Errorf("no free disk targets on bus '%v'", bus)
+}
+
+// Create a new qcow2 volume in the named pool and attach it to the domain on
+// the given bus. The target device name of the new disk is returned. If the
+// disk cannot be attached, the new volume is removed.
+func (dom *Domain) AddDisk(virt *Connection, poolName string, capacity uint64, bus string) (string, error) {
+	name, err := dom.GetName()
+	if err != nil {
+		return "", err
+	}
+
+	target, err := dom.nextDiskTarget(bus)
+	if err != nil {
+		return "", err
+	}
+
+	pool, err := virt.LookupStoragePoolByName(poolName)
+	if err != nil {
+		return "", err
+	}
+	defer pool.Free()
+
+	volumeDescription := libvirtxml.StorageVolume{
+		Name: fmt.Sprintf("%v-%v.qcow2", name, target),
+		Capacity: &libvirtxml.StorageVolumeSize{
+			Unit:  "bytes",
+			Value: capacity,
+		},
+		Target: &libvirtxml.StorageVolumeTarget{
+			Format: &libvirtxml.StorageVolumeTargetFormat{
+				Type: "qcow2",
+			},
+		},
+	}
+
+	var volume *libvirt.StorageVol
+	if xmlDesc, err := xml.Marshal(&volumeDescription); err != nil {
+		return "", err
+	} else if volume, err = pool.StorageVolCreateXML(string(xmlDesc), 0); err != nil {
+		return "", err
+	}
+	defer volume.Free()
+
+	path, err := volume.GetPath()
+	if err != nil {
+		return "", errors.Join(err, dom.cleanupVolumes(virt, []*libvirt.StorageVol{volume}))
+	}
+
+	disk := libvirtxml.DomainDisk{
+		Device: "disk",
+		Driver: &libvirtxml.DomainDiskDriver{
+			Name: "qemu",
+			Type: "qcow2",
+		},
+		Source: &libvirtxml.DomainDiskSource{
+			File: &libvirtxml.DomainDiskSourceFile{
+				File: path,
+			},
+		},
+		Target: &libvirtxml.DomainDiskTarget{
+			Dev: target,
+			Bus: bus,
+		},
+	}
+
+	if diskXml, err := disk.Marshal(); err != nil {
+		return "", errors.Join(err, dom.cleanupVolumes(virt, []*libvirt.StorageVol{volume}))
+	} else if err := dom.AttachDeviceFlags(diskXml, dom.deviceModifyFlags()); err != nil {
+		return "", errors.Join(err, dom.cleanupVolumes(virt, []*libvirt.StorageVol{volume}))
+	}
+
+	return target, nil
+}
+
+// Grow a disk to the given capacity in bytes. Running domains are resized
+// live through the hypervisor so the guest sees the change immediately,
+// otherwise the backing volume is resized directly.
+func (dom *Domain) ResizeDisk(virt *Connection, target string, capacity uint64) error {
+	disk, err := dom.LookupDisk(target)
+	if err != nil {
+		return err
+	}
+
+	if active, err := dom.IsActive(); err != nil {
+		return err
+	} else if active {
+		return dom.BlockResize(target, capacity, libvirt.DOMAIN_BLOCK_RESIZE_BYTES)
+	}
+
+	path := DiskSourcePath(disk)
+	if path == "" || disk.Source.Volume != nil {
+		return fmt.Errorf("disk '%v' is not backed by a local volume", target)
+	}
+
+	volume, err := virt.LookupStorageVolByPath(path)
+	if err != nil {
+		return err
+	}
+	defer volume.Free()
+
+	return volume.Resize(capacity, 0)
+}
+
+// Detach a disk from the domain. The backing volume is left untouched.
+func (dom *Domain) DetachDisk(target string) error {
+	disk, err := dom.LookupDisk(target)
+	if err != nil {
+		return err
+	}
+
+	if diskXml, err := disk.Marshal(); err != nil {
+		return err
+	} else {
+		return dom.DetachDeviceFlags(diskXml, dom.deviceModifyFlags())
+	}
+}
+
+// Insert the media at path into a CD-ROM drive. An empty path ejects
+// the current media.
+func (dom *Domain) ChangeMedia(target string, path string) error {
+	disk, err := dom.LookupDisk(target)
+	if err != nil {
+		return err
+	} else if disk.Device != "cdrom" && disk.Device != "floppy" {
+		return fmt.Errorf("disk '%v' is not removable media", target)
+	}
+
+	if path == "" {
+		disk.Source = nil
+	} else {
+		disk.Source = &libvirtxml.DomainDiskSource{
+			File: &libvirtxml.DomainDiskSourceFile{
+				File: path,
+			},
+		}
+	}
+
+	if diskXml, err := disk.Marshal(); err != nil {
+		return err
+	} else {
+		return dom.UpdateDeviceFlags(diskXml, dom.deviceModifyFlags()|libvirt.DOMAIN_DEVICE_MODIFY_FORCE)
+	}
+}
+
+// Attach a new read-only CD-ROM drive holding the media at path. The drive
+// goes on the SATA bus for q35 machines and on IDE otherwise, since those
+// are the buses the machine types provide. The target device name of the
+// new drive is returned.
+func (dom *Domain) AttachCDROM(path string) (string, error) {
+	description, err := dom.GetDescription(libvirt.DOMAIN_XML_INACTIVE)
+	if err != nil {
+		return "", err
+	}
+
+	bus := "ide"
+	if description.OS != nil && description.OS.Type != nil && strings.Contains(description.OS.Type.Machine, "q35") {
+		bus = "sata"
+	}
+
+	target, err := dom.nextDiskTarget(bus)
+	if err != nil {
+		return "", err
+	}
+
+	disk := libvirtxml.DomainDisk{
+		Device: "cdrom",
+		Driver: &libvirtxml.DomainDiskDriver{
+			Name: "qemu",
+			Type: "raw",
+		},
+		Source: &libvirtxml.DomainDiskSource{
+			File: &libvirtxml.DomainDiskSourceFile{
+				File: path,
+			},
+		},
+		Target: &libvirtxml.DomainDiskTarget{
+			Dev: target,
+			Bus: bus,
+		},
+		ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+	}
+
+	if diskXml, err := disk.Marshal(); err != nil {
+		return "", err
+	} else if err := dom.AttachDeviceFlags(diskXml, dom.deviceModifyFlags()); err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
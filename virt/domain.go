@@ -2,6 +2,7 @@ package virt
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -23,9 +24,24 @@ var (
 )
 
 type VmmDomainMetadata struct {
-	Path    string   `xml:"path"`
-	Labels  []string `xml:"label"`
-	XMLName xml.Name `xml:"vmm"`
+	Path         string                `xml:"path"`
+	Labels       []string              `xml:"label"`
+	LookingGlass *LookingGlassMetadata `xml:"looking-glass,omitempty"`
+	Viewers      []string              `xml:"viewer"` // Preferred viewers, default first
+	Startup      *StartupMetadata      `xml:"startup,omitempty"`
+	Manifest     *ManifestMetadata     `xml:"manifest,omitempty"`   // Set for domains created by `vroomm apply`
+	Template     bool                  `xml:"template,omitempty"`   // Golden image which is cloned rather than started
+	Seed         string                `xml:"seed,omitempty"`       // Path of the cloud-init seed volume created for this domain
+	Disposable   bool                  `xml:"disposable,omitempty"` // Deleted along with its storage once it shuts off
+	XMLName      xml.Name              `xml:"vmm"`
+}
+
+// Records which manifest a domain was created from, and what it was cloned
+// from, so later applies can tell whether it needs to be rebuilt.
+type ManifestMetadata struct {
+	Name   string `xml:"name"`
+	Source string `xml:"source"`
+	Linked bool   `xml:"linked"`
 }
 
 type Domain struct {
@@ -58,7 +74,14 @@ func (dom *Domain) GetVmmData() VmmDomainMetadata {
 	}
 }
 
+// Store the vroomm metadata in the persistent definition, and also in the
+// live definition of a running domain so GetVmmData sees it right away.
 func (dom *Domain) UpdateVmmData(metadata VmmDomainMetadata) error {
+	flags := libvirt.DOMAIN_AFFECT_CONFIG
+	if active, err := dom.IsActive(); err == nil && active {
+		flags |= libvirt.DOMAIN_AFFECT_LIVE
+	}
+
 	if xmlData, err := xml.Marshal(metadata); err != nil {
 		return err
 	} else {
@@ -67,7 +90,7 @@ func (dom *Domain) UpdateVmmData(metadata VmmDomainMetadata) error {
 			string(xmlData),
 			vmmMetaTag,
 			vmmMetaNamespace,
-			libvirt.DOMAIN_AFFECT_CONFIG,
+			flags,
 		)
 	}
 }
@@ -92,6 +115,29 @@ func (dom *Domain) Clone(virt *Connection, name string, linked bool) (newDomain
 	description.Name = name
 	description.UUID = uuid.NewString()
 
+	// Sharing the UEFI variable store would let the clone change (and its
+	// deletion remove) the variables of the source. Without a path, libvirt
+	// creates a fresh store from the template.
+	if description.OS != nil && description.OS.NVRam != nil {
+		description.OS.NVRam.NVRam = ""
+		description.OS.NVRam.Source = nil
+		if description.OS.NVRam.Template == "" {
+			description.OS.NVRam = nil
+		}
+	}
+
+	// The seed of the source would hand its hostname and instance ID to the
+	// clone, so its drive is left out. Callers attach a seed of its own.
+	if seed := dom.GetVmmData().Seed; seed != "" && description.Devices != nil {
+		disks := []libvirtxml.DomainDisk{}
+		for idx := range description.Devices.Disks {
+			if DiskSourcePath(&description.Devices.Disks[idx]) != seed {
+				disks = append(disks, description.Devices.Disks[idx])
+			}
+		}
+		description.Devices.Disks = disks
+	}
+
 	createdVolumes := []*libvirt.StorageVol{}
 
 	for idx, disk := range description.Devices.Disks {
@@ -103,28 +149,48 @@ func (dom *Domain) Clone(virt *Connection, name string, linked bool) (newDomain
 		// Clone the disk
 		vol, err := dom.cloneDisk(virt, &description, idx, linked)
 		if err != nil {
-			dom.cleanupVolumes(virt, createdVolumes)
-			return nil, err
+			return nil, errors.Join(err, dom.cleanupVolumes(virt, createdVolumes))
 		} else if vol != nil {
 			createdVolumes = append(createdVolumes, vol)
 		}
 	}
 
 	if xmlDesc, err := xml.Marshal(&description); err != nil {
-		dom.cleanupVolumes(virt, createdVolumes)
-		return nil, err
+		return nil, errors.Join(err, dom.cleanupVolumes(virt, createdVolumes))
 	} else if libvirtDomain, err := virt.DomainDefineXML(string(xmlDesc)); err != nil {
-		dom.cleanupVolumes(virt, createdVolumes)
+		return nil, errors.Join(err, dom.cleanupVolumes(virt, createdVolumes))
+	} else if newDomain, err = NewDomain(*libvirtDomain); err != nil {
 		return nil, err
-	} else {
-		return NewDomain(*libvirtDomain)
 	}
+
+	// The copied metadata still describes the source. Callers mark the clone
+	// as managed by a manifest, disposable or seeded themselves.
+	if metadata := newDomain.GetVmmData(); metadata.Manifest != nil || metadata.Disposable || metadata.Seed != "" {
+		metadata.Manifest = nil
+		metadata.Disposable = false
+		metadata.Seed = ""
+		if err := newDomain.UpdateVmmData(metadata); err != nil {
+			return nil, errors.Join(err, newDomain.Delete(virt, true))
+		}
+	}
+
+	return newDomain, nil
 }
 
-func (dom *Domain) cleanupVolumes(virt *Connection, volumes []*libvirt.StorageVol) {
+// Remove volumes created during a failed operation. Every volume is attempted
+// even if an earlier one fails, so the caller gets the full list of leftovers
+// (which `vroomm gc` can clean up later).
+func (dom *Domain) cleanupVolumes(virt *Connection, volumes []*libvirt.StorageVol) error {
+	errs := []error{}
 	for _, volume := range volumes {
-		volume.Delete(libvirt.STORAGE_VOL_DELETE_NORMAL)
+		if err := volume.Delete(libvirt.STORAGE_VOL_DELETE_NORMAL); err != nil {
+			if path, pathErr := volume.GetPath(); pathErr == nil {
+				err = fmt.Errorf("failed to remove volume %v: %w", path, err)
+			}
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
 }
 
 // Clone the disk at the specified disk index
@@ -210,6 +276,43 @@ func (dom *Domain) cloneDisk(virt *Connection, description *libvirtxml.Domain, i
 	return newVolume, nil
 }
 
+// Take a snapshot of every writable disk. Memory is included if the domain
+// is running, and the snapshot records the current domain definition.
 func (dom *Domain) Snapshot(name string) error {
-	return nil
+	description := libvirtxml.Domain{}
+	if xmlDesc, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_SECURE); err != nil {
+		return err
+	} else if err := xml.Unmarshal([]byte(xmlDesc), &description); err != nil {
+		return err
+	}
+
+	domainSnapshot := libvirtxml.DomainSnapshot{
+		Name: name,
+		Disks: &libvirtxml.DomainSnapshotDisks{
+			Disks: []libvirtxml.DomainSnapshotDisk{},
+		},
+		Domain: &description,
+	}
+
+	if description.Devices != nil {
+		for _, disk := range description.Devices.Disks {
+			snapshot := ""
+			if disk.ReadOnly != nil {
+				snapshot = "no"
+			}
+
+			domainSnapshot.Disks.Disks = append(domainSnapshot.Disks.Disks, libvirtxml.DomainSnapshotDisk{
+				Name:     disk.Target.Dev,
+				Snapshot: snapshot,
+			})
+		}
+	}
+
+	if snapshotXml, err := xml.Marshal(&domainSnapshot); err != nil {
+		return err
+	} else if snapshot, err := dom.CreateSnapshotXML(string(snapshotXml), 0); err != nil {
+		return err
+	} else {
+		return snapshot.Free()
+	}
 }
@@ -0,0 +1,33 @@
+package virt
+
+import (
+	"sync"
+
+	"libvirt.org/go/libvirt"
+)
+
+var (
+	eventLoopOnce  sync.Once
+	eventLoopError error
+)
+
+// Register and run the default libvirt event loop. This must happen before
+// any connection is opened, otherwise domain event callbacks registered on
+// that connection will never fire. It is safe to call multiple times.
+func startEventLoop() error {
+	eventLoopOnce.Do(func() {
+		if eventLoopError = libvirt.EventRegisterDefaultImpl(); eventLoopError != nil {
+			return
+		}
+
+		go func() {
+			for {
+				if err := libvirt.EventRunDefaultImpl(); err != nil {
+					return
+				}
+			}
+		}()
+	})
+
+	return eventLoopError
+}
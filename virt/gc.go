@@ -0,0 +1,372 @@
+package virt
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// The reason a volume was reported as garbage
+type GarbageReason string
+
+const (
+	GarbageOrphaned       GarbageReason = "orphaned"        // No domain or volume references this volume
+	GarbageMissingBacking GarbageReason = "missing-backing" // The volume's backing file cannot be found
+)
+
+// A storage volume which is likely no longer needed
+type GarbageVolume struct {
+	Pool        string        // Name of the pool holding the volume
+	Name        string        // Name of the volume
+	Path        string        // Full path of the volume
+	BackingPath string        // Path of the backing store (if any)
+	Allocation  uint64        // Bytes allocated on the host for this volume
+	Reason      GarbageReason // Why this volume was reported
+	Domains     []string      // Domains which still reference this volume
+	Dependents  []string      // Paths of volumes backed by this volume
+}
+
+// The result of scanning all storage pools for unused volumes
+type GarbageReport struct {
+	Volumes []GarbageVolume
+}
+
+// Return the total number of bytes which would be freed by cleaning
+// up all removable volumes in the report.
+func (report *GarbageReport) Reclaimable() uint64 {
+	total := uint64(0)
+	for _, volume := range report.Volumes {
+		if volume.Removable() {
+			total += volume.Allocation
+		}
+	}
+	return total
+}
+
+// A volume is only removable if no domain or other volume still references
+// it. Linked clones with a missing backing file which are still attached to
+// a domain, or which back other overlays, must be dealt with by fixing or
+// removing those first.
+func (volume *GarbageVolume) Removable() bool {
+	return len(volume.Domains) == 0 && len(volume.Dependents) == 0
+}
+
+// Return the domains and volumes which still reference this volume
+func (volume *GarbageVolume) UsedBy() []string {
+	return append(append([]string{}, volume.Domains...), volume.Dependents...)
+}
+
+// Scan all storage pools and cross-reference every volume against the
+// disks of all domains (including their snapshots and backing chains)
+// and the backing stores of all other volumes. Volumes which are not
+// referenced by anything and linked clones whose backing file no longer
+// exists are returned. ISO images are never reported as orphaned, since
+// installation media is commonly kept around unattached.
+func (c *Connection) FindGarbage() (*GarbageReport, error) {
+	volumes, err := c.collectVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Map volume paths to the names of all domains using them
+	users, err := c.collectDiskUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	// Map backing store paths to the volumes stacked on them
+	dependents := map[string][]string{}
+	for path, volume := range volumes {
+		if volume.BackingPath != "" {
+			dependents[volume.BackingPath] = append(dependents[volume.BackingPath], path)
+		}
+	}
+
+	report := &GarbageReport{
+		Volumes: []GarbageVolume{},
+	}
+
+	for path, volume := range volumes {
+		volume.Domains = users[path]
+		volume.Dependents = dependents[path]
+		sort.Strings(volume.Dependents)
+
+		// Reported even while still in use, but only removable once unused
+		if volume.BackingPath != "" && !c.backingExists(path, volume.BackingPath, volumes) {
+			volume.Reason = GarbageMissingBacking
+			report.Volumes = append(report.Volumes, volume.GarbageVolume)
+			continue
+		}
+
+		if len(volume.Domains) > 0 || len(volume.Dependents) > 0 || volume.isInstallMedia {
+			continue
+		}
+
+		volume.Reason = GarbageOrphaned
+		report.Volumes = append(report.Volumes, volume.GarbageVolume)
+	}
+
+	return report, nil
+}
+
+// Check whether the backing file of an overlay exists. Relative backing
+// paths are relative to the overlay. Backing files are usually volumes, but
+// may also live outside of any (active) pool.
+func (c *Connection) backingExists(overlay string, backing string, volumes map[string]*scannedVolume) bool {
+	if !filepath.IsAbs(backing) {
+		backing = filepath.Join(filepath.Dir(overlay), backing)
+	}
+
+	if _, ok := volumes[backing]; ok {
+		return true
+	} else if volume, err := c.LookupStorageVolByPath(backing); err == nil {
+		volume.Free()
+		return true
+	}
+
+	// Files outside of pools can only be checked on this host. On remote
+	// hosts, they are assumed to exist rather than risk removing overlays.
+	if local, err := c.isLocal(); err != nil || !local {
+		return true
+	}
+	_, err := os.Stat(backing)
+	return err == nil
+}
+
+// Whether the connection is to the libvirt daemon on this host
+func (c *Connection) isLocal() (bool, error) {
+	uri, err := c.GetURI()
+	if err != nil {
+		return false, err
+	}
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return false, err
+	}
+	return parsed.Host == "", nil
+}
+
+// Delete all removable volumes in the report. If dryRun is set, nothing is
+// deleted, but the returned list still contains every volume which would
+// have been removed. Deletion continues after a failure, and all errors are
+// returned together.
+func (c *Connection) CleanupGarbage(report *GarbageReport, dryRun bool) ([]GarbageVolume, error) {
+	removed := []GarbageVolume{}
+	errs := []error{}
+
+	for _, volume := range report.Volumes {
+		if !volume.Removable() {
+			continue
+		}
+
+		if !dryRun {
+			if err := c.DeleteVolume(volume.Path); err != nil {
+				errs = append(errs, fmt.Errorf("%v: %w", volume.Path, err))
+				continue
+			}
+		}
+
+		removed = append(removed, volume)
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// Delete the storage volume at the given path
+func (c *Connection) DeleteVolume(path string) error {
+	if volume, err := c.LookupStorageVolByPath(path); err != nil {
+		return err
+	} else {
+		defer volume.Free()
+		return volume.Delete(libvirt.STORAGE_VOL_DELETE_NORMAL)
+	}
+}
+
+type scannedVolume struct {
+	GarbageVolume
+	isInstallMedia bool
+}
+
+// Enumerate all volumes in all active pools keyed by their path
+func (c *Connection) collectVolumes() (map[string]*scannedVolume, error) {
+	pools, err := c.ListAllStoragePools(libvirt.CONNECT_LIST_STORAGE_POOLS_ACTIVE)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := map[string]*scannedVolume{}
+
+	errs := []error{}
+	for idx := range pools {
+		if err := scanPool(&pools[idx], volumes); err != nil {
+			errs = append(errs, err)
+		}
+		pools[idx].Free()
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}
+
+// Add all volumes of the pool to the map
+func scanPool(pool *libvirt.StoragePool, volumes map[string]*scannedVolume) error {
+	poolName, err := pool.GetName()
+	if err != nil {
+		return err
+	}
+
+	// Make sure files created outside of libvirt are visible
+	pool.Refresh(0)
+
+	poolVolumes, err := pool.ListAllStorageVolumes(0)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for idx := range poolVolumes {
+		if scanned, err := scanVolume(poolName, &poolVolumes[idx]); err != nil {
+			errs = append(errs, err)
+		} else {
+			volumes[scanned.Path] = scanned
+		}
+		poolVolumes[idx].Free()
+	}
+
+	return errors.Join(errs...)
+}
+
+func scanVolume(poolName string, volume *libvirt.StorageVol) (*scannedVolume, error) {
+	description := libvirtxml.StorageVolume{}
+	if xmlDesc, err := volume.GetXMLDesc(0); err != nil {
+		return nil, err
+	} else if err := xml.Unmarshal([]byte(xmlDesc), &description); err != nil {
+		return nil, err
+	}
+
+	path, err := volume.GetPath()
+	if err != nil {
+		return nil, err
+	}
+
+	scanned := &scannedVolume{
+		GarbageVolume: GarbageVolume{
+			Pool: poolName,
+			Name: description.Name,
+			Path: path,
+		},
+		isInstallMedia: strings.EqualFold(filepath.Ext(path), ".iso"),
+	}
+
+	if description.Allocation != nil {
+		scanned.Allocation = scaleSize(description.Allocation.Value, description.Allocation.Unit)
+	}
+	if description.BackingStore != nil {
+		scanned.BackingPath = description.BackingStore.Path
+	}
+	if description.Target != nil && description.Target.Format != nil && description.Target.Format.Type == "iso" {
+		scanned.isInstallMedia = true
+	}
+
+	return scanned, nil
+}
+
+// Map the path of every disk, backing store and snapshot disk to the
+// names of the domains which use it. Both the live and persistent
+// definitions are checked so pending configuration changes are honored.
+func (c *Connection) collectDiskUsers() (map[string][]string, error) {
+	domains, err := c.EnumerateAllDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[string][]string{}
+
+	for _, domain := range domains {
+		name, err := domain.GetName()
+		if err != nil {
+			return nil, err
+		}
+
+		paths := map[string]struct{}{}
+		descriptions := []*libvirtxml.Domain{}
+
+		for _, flags := range []libvirt.DomainXMLFlags{libvirt.DOMAIN_XML_INACTIVE, 0} {
+			description := &libvirtxml.Domain{}
+			if xmlDesc, err := domain.GetXMLDesc(flags); err != nil {
+				return nil, err
+			} else if err := xml.Unmarshal([]byte(xmlDesc), description); err != nil {
+				return nil, err
+			}
+			descriptions = append(descriptions, description)
+		}
+
+		if snapshots, err := domain.ListAllSnapshots(0); err == nil {
+			for _, snapshot := range snapshots {
+				description := libvirtxml.DomainSnapshot{}
+				if xmlDesc, err := snapshot.GetXMLDesc(0); err == nil && xml.Unmarshal([]byte(xmlDesc), &description) == nil {
+					if description.Disks != nil {
+						for _, disk := range description.Disks.Disks {
+							c.collectSourcePaths(paths, disk.Source)
+						}
+					}
+					if description.Domain != nil {
+						descriptions = append(descriptions, description.Domain)
+					}
+				}
+				snapshot.Free()
+			}
+		}
+
+		for _, description := range descriptions {
+			if description.Devices == nil {
+				continue
+			}
+			for _, disk := range description.Devices.Disks {
+				c.collectSourcePaths(paths, disk.Source)
+				for backing := disk.BackingStore; backing != nil; backing = backing.BackingStore {
+					c.collectSourcePaths(paths, backing.Source)
+				}
+			}
+		}
+
+		for path := range paths {
+			users[path] = append(users[path], name)
+		}
+	}
+
+	return users, nil
+}
+
+// Add the host path of a disk source to the set if it refers to a local file,
+// block device or storage pool volume.
+func (c *Connection) collectSourcePaths(paths map[string]struct{}, source *libvirtxml.DomainDiskSource) {
+	if source == nil {
+		return
+	} else if source.File != nil && source.File.File != "" {
+		paths[source.File.File] = struct{}{}
+	} else if source.Block != nil && source.Block.Dev != "" {
+		paths[source.Block.Dev] = struct{}{}
+	} else if source.Volume != nil {
+		if pool, err := c.LookupStoragePoolByName(source.Volume.Pool); err == nil {
+			if volume, err := pool.LookupStorageVolByName(source.Volume.Volume); err == nil {
+				if path, err := volume.GetPath(); err == nil {
+					paths[path] = struct{}{}
+				}
+				volume.Free()
+			}
+			pool.Free()
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package virt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"libvirt.org/go/libvirt"
+)
+
+const (
+	libosinfoNamespace = "http://libosinfo.org/xmlns/libvirt/domain/1.0"
+)
+
+// Guest operating system families, as used to pick a default viewer
+const (
+	OSFamilyWindows = "windows"
+	OSFamilyLinux   = "linux"
+	OSFamilyUnknown = "default"
+)
+
+type libosinfoMetadata struct {
+	XMLName xml.Name `xml:"libosinfo"`
+	OS      struct {
+		ID string `xml:"id,attr"`
+	} `xml:"os"`
+}
+
+// Return the listening port of the running domain's SPICE or VNC display
+func (dom *Domain) GraphicsPort(kind string) (int, error) {
+	description, err := dom.GetDescription(0)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, graphics := range description.Devices.Graphics {
+		if kind == "spice" && graphics.Spice != nil && graphics.Spice.Port > 0 {
+			return graphics.Spice.Port, nil
+		} else if kind == "vnc" && graphics.VNC != nil && graphics.VNC.Port > 0 {
+			return graphics.VNC.Port, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no active %v display", kind)
+}
+
+// Guess the guest operating system family from the libosinfo metadata
+// written by virt-install and virt-manager.
+func (dom *Domain) OSFamily() string {
+	metadata := libosinfoMetadata{}
+	if xmlData, err := dom.GetMetadata(libvirt.DOMAIN_METADATA_ELEMENT, libosinfoNamespace, libvirt.DOMAIN_AFFECT_CURRENT); err != nil {
+		return OSFamilyUnknown
+	} else if err := xml.Unmarshal([]byte(xmlData), &metadata); err != nil || metadata.OS.ID == "" {
+		return OSFamilyUnknown
+	} else if strings.Contains(metadata.OS.ID, "microsoft.com/win") {
+		return OSFamilyWindows
+	} else {
+		return OSFamilyLinux
+	}
+}
@@ -0,0 +1,123 @@
+package virt
+
+import (
+	"fmt"
+	"time"
+
+	"libvirt.org/go/libvirt"
+)
+
+// A user logged in to the guest
+type GuestUser struct {
+	Name      string    // User name
+	Domain    string    // Logon domain (Windows only)
+	LoginTime time.Time // When the user logged in
+}
+
+// A mounted filesystem in the guest
+type GuestFilesystem struct {
+	MountPoint string // Mount point (or drive letter)
+	Type       string // Filesystem type
+	Total      uint64 // Total size in bytes (zero if unknown)
+	Used       uint64 // Used space in bytes
+}
+
+// Guest operating system details reported by the guest agent
+type GuestInfo struct {
+	OS          string            // Pretty OS name
+	Kernel      string            // Kernel release
+	Hostname    string            // Guest hostname
+	TimeZone    string            // Time zone name and UTC offset
+	Users       []GuestUser       // Logged in users
+	Filesystems []GuestFilesystem // Mounted filesystems
+}
+
+// Query the guest agent for OS, hostname, user, time zone and filesystem
+// information. Fails if the guest agent is not connected.
+func (dom *Domain) GuestInfo() (*GuestInfo, error) {
+	raw, err := dom.GetGuestInfo(
+		libvirt.DOMAIN_GUEST_INFO_OS|
+			libvirt.DOMAIN_GUEST_INFO_HOSTNAME|
+			libvirt.DOMAIN_GUEST_INFO_USERS|
+			libvirt.DOMAIN_GUEST_INFO_TIMEZONE|
+			libvirt.DOMAIN_GUEST_INFO_FILESYSTEM,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &GuestInfo{
+		Hostname:    raw.Hostname,
+		Users:       []GuestUser{},
+		Filesystems: []GuestFilesystem{},
+	}
+
+	if raw.OS != nil {
+		if raw.OS.PrettyNameSet {
+			info.OS = raw.OS.PrettyName
+		} else {
+			info.OS = raw.OS.Name + " " + raw.OS.Version
+		}
+		info.Kernel = raw.OS.KernelRelease
+	}
+
+	if raw.TimeZone != nil {
+		info.TimeZone = raw.TimeZone.Name
+		if raw.TimeZone.OffsetSet {
+			sign, offset := "+", raw.TimeZone.Offset
+			if offset < 0 {
+				sign, offset = "-", -offset
+			}
+			info.TimeZone = fmt.Sprintf("%v (UTC%v%02d:%02d)", info.TimeZone, sign, offset/3600, offset%3600/60)
+		}
+	}
+
+	for _, user := range raw.Users {
+		info.Users = append(info.Users, GuestUser{
+			Name:      user.Name,
+			Domain:    user.Domain,
+			LoginTime: time.UnixMilli(int64(user.LoginTime)),
+		})
+	}
+
+	for _, fs := range raw.FileSystems {
+		info.Filesystems = append(info.Filesystems, GuestFilesystem{
+			MountPoint: fs.MountPoint,
+			Type:       fs.FSType,
+			Total:      fs.TotalBytes,
+			Used:       fs.UsedBytes,
+		})
+	}
+
+	return info, nil
+}
+
+// Call the callback whenever the guest agent of the domain connects or
+// disconnects, or the domain changes lifecycle state. The returned function
+// stops watching. The callback runs on the libvirt event loop, and must not
+// block.
+func (dom *Domain) WatchGuestAgent(virt *Connection, callback func(connected bool)) (func(), error) {
+	agentId, err := virt.DomainEventAgentLifecycleRegister(&dom.Domain, func(_ *libvirt.Connect, _ *libvirt.Domain, event *libvirt.DomainEventAgentLifecycle) {
+		callback(event.State == libvirt.CONNECT_DOMAIN_EVENT_AGENT_LIFECYCLE_STATE_CONNECTED)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The agent never reports a disconnect when the domain is destroyed
+	lifecycleId, err := virt.DomainEventLifecycleRegister(&dom.Domain, func(_ *libvirt.Connect, _ *libvirt.Domain, event *libvirt.DomainEventLifecycle) {
+		if event.Event == libvirt.DOMAIN_EVENT_STOPPED || event.Event == libvirt.DOMAIN_EVENT_CRASHED {
+			callback(false)
+		}
+	})
+	if err != nil {
+		virt.DomainEventDeregister(agentId)
+		return nil, err
+	}
+
+	return func() {
+		virt.DomainEventDeregister(agentId)
+		virt.DomainEventDeregister(lifecycleId)
+	}, nil
+}
@@ -0,0 +1,222 @@
+package virt
+
+import (
+	"fmt"
+	"time"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// Hardware, software and capability overview of the hypervisor host, along
+// with the resources committed to its domains.
+type HostInfo struct {
+	Hostname          string   // Host name of the hypervisor
+	Model             string   // CPU model
+	Arch              string   // Host CPU architecture
+	CPUs              uint     // Number of active CPUs
+	MHz               uint     // CPU frequency
+	Nodes             uint32   // NUMA nodes
+	Sockets           uint32   // Sockets per node
+	Cores             uint32   // Cores per socket
+	Threads           uint32   // Threads per core
+	MemoryTotal       uint64   // Total memory in bytes
+	MemoryFree        uint64   // Free memory in bytes
+	Hypervisor        string   // Hypervisor driver name
+	HypervisorVersion string   // Hypervisor version
+	LibvirtVersion    string   // Libvirt version
+	VirtType          string   // Preferred domain type (e.g. kvm)
+	MachineTypes      []string // Machine types supported for the host architecture
+	Firmware          []string // Supported firmware types (e.g. efi, bios)
+	Loaders           []string // Available firmware loader images
+	RunningDomains    int      // Number of running domains
+	AllDomains        int      // Number of defined domains
+	RunningVCPUs      uint     // vCPUs assigned to running domains
+	AllVCPUs          uint     // vCPUs assigned to all domains
+	RunningMemory     uint64   // Maximum memory of running domains in bytes
+	AllMemory         uint64   // Maximum memory of all domains in bytes
+}
+
+// Host CPU time counters at a point in time
+type HostCPUSample struct {
+	Time time.Time // When the sample was taken
+	Busy uint64    // Total busy (kernel, user and interrupt) time in nanoseconds
+	Idle uint64    // Total idle (including I/O wait) time in nanoseconds
+}
+
+// Collect node information, versions, capabilities and committed resources
+// of the connected host.
+func (c *Connection) HostInfo() (*HostInfo, error) {
+	node, err := c.GetNodeInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &HostInfo{
+		Model:        node.Model,
+		CPUs:         node.Cpus,
+		MHz:          node.MHz,
+		Nodes:        node.Nodes,
+		Sockets:      node.Sockets,
+		Cores:        node.Cores,
+		Threads:      node.Threads,
+		MemoryTotal:  node.Memory << 10,
+		MachineTypes: []string{},
+		Firmware:     []string{},
+		Loaders:      []string{},
+	}
+
+	if info.Hostname, err = c.GetHostname(); err != nil {
+		return nil, err
+	} else if info.MemoryFree, err = c.GetFreeMemory(); err != nil {
+		return nil, err
+	} else if info.Hypervisor, err = c.GetType(); err != nil {
+		return nil, err
+	}
+
+	if version, err := c.GetVersion(); err != nil {
+		return nil, err
+	} else {
+		info.HypervisorVersion = formatVersion(version)
+	}
+
+	if version, err := c.GetLibVersion(); err != nil {
+		return nil, err
+	} else {
+		info.LibvirtVersion = formatVersion(version)
+	}
+
+	if err := c.collectCapabilities(info); err != nil {
+		return nil, err
+	}
+
+	if err := c.collectCommitted(info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Fill in the host architecture, machine types and firmware. Firmware comes
+// from the domain capabilities, which not every driver supports, so failing
+// to query them is not an error.
+func (c *Connection) collectCapabilities(info *HostInfo) error {
+	capsXml, err := c.GetCapabilities()
+	if err != nil {
+		return err
+	}
+
+	var caps libvirtxml.Caps
+	if err := caps.Unmarshal(capsXml); err != nil {
+		return err
+	}
+
+	if caps.Host.CPU != nil {
+		info.Arch = caps.Host.CPU.Arch
+	}
+
+	for _, guest := range caps.Guests {
+		if guest.Arch.Name != info.Arch || guest.OSType != "hvm" {
+			continue
+		}
+
+		for _, machine := range guest.Arch.Machines {
+			info.MachineTypes = append(info.MachineTypes, machine.Name)
+		}
+
+		// Prefer hardware acceleration when it is available
+		for _, domain := range guest.Arch.Domains {
+			if info.VirtType == "" || domain.Type == "kvm" {
+				info.VirtType = domain.Type
+			}
+		}
+	}
+
+	domCapsXml, err := c.GetDomainCapabilities("", info.Arch, "", info.VirtType, 0)
+	if err != nil {
+		return nil
+	}
+
+	var domCaps libvirtxml.DomainCaps
+	if err := domCaps.Unmarshal(domCapsXml); err != nil || domCaps.OS == nil {
+		return nil
+	}
+
+	for _, enum := range domCaps.OS.Enums {
+		if enum.Name == "firmware" {
+			info.Firmware = append(info.Firmware, enum.Values...)
+		}
+	}
+
+	if domCaps.OS.Loader != nil {
+		info.Loaders = append(info.Loaders, domCaps.OS.Loader.Values...)
+	}
+
+	return nil
+}
+
+// Total the vCPUs and memory assigned to running and to all domains
+func (c *Connection) collectCommitted(info *HostInfo) error {
+	domains, err := c.ListAllDomains(0)
+	if err != nil {
+		return err
+	}
+
+	for idx := range domains {
+		domain := &domains[idx]
+		domInfo, err := domain.GetInfo()
+		active, activeErr := domain.IsActive()
+		domain.Free()
+		if err != nil {
+			return err
+		} else if activeErr != nil {
+			return activeErr
+		}
+
+		info.AllDomains++
+		info.AllVCPUs += domInfo.NrVirtCpu
+		info.AllMemory += domInfo.MaxMem << 10
+
+		if active {
+			info.RunningDomains++
+			info.RunningVCPUs += domInfo.NrVirtCpu
+			info.RunningMemory += domInfo.MaxMem << 10
+		}
+	}
+
+	return nil
+}
+
+// Sample the CPU time counters of the host, summed over all CPUs
+func (c *Connection) SampleHostCPU() (HostCPUSample, error) {
+	stats, err := c.GetCPUStats(int(libvirt.NODE_CPU_STATS_ALL_CPUS), 0)
+	if err != nil {
+		return HostCPUSample{}, err
+	}
+
+	return HostCPUSample{
+		Time: time.Now(),
+		Busy: stats.Kernel + stats.User + stats.Intr,
+		Idle: stats.Idle + stats.Iowait,
+	}, nil
+}
+
+// Compute the host CPU usage in percent between a previous and current sample
+func (sample *HostCPUSample) Usage(previous *HostCPUSample) float64 {
+	if sample.Busy < previous.Busy || sample.Idle < previous.Idle {
+		return 0
+	}
+
+	busy := sample.Busy - previous.Busy
+	total := busy + sample.Idle - previous.Idle
+	if total == 0 {
+		return 0
+	}
+
+	return float64(busy) / float64(total) * 100
+}
+
+// Format a libvirt version number (major * 1,000,000 + minor * 1,000 + release)
+func formatVersion(version uint32) string {
+	return fmt.Sprintf("%d.%d.%d", version/1000000, version/1000%1000, version%1000)
+}
@@ -0,0 +1,332 @@
+package virt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// A USB or PCI device on the host which can be passed through to a domain
+type HostDevice struct {
+	Name    string   // Node device name (e.g. pci_0000_01_00_0)
+	Type    string   // Either "usb" or "pci"
+	Address string   // Host bus address
+	Vendor  string   // Vendor name (or ID if the name is unknown)
+	Product string   // Product name (or ID if the name is unknown)
+	Holders []string // Domains which currently have the device assigned
+	hostdev libvirtxml.DomainHostdev
+
+	usb       *usbHostdev
+	usbBus    uint
+	usbDevice uint
+}
+
+// A USB hostdev keyed by vendor and product ID, which (unlike the bus and
+// device numbers) survive replugging the device. libvirtxml can't describe
+// these, so they are marshaled by hand.
+type usbHostdev struct {
+	XMLName xml.Name         `xml:"hostdev"`
+	Mode    string           `xml:"mode,attr"`
+	Type    string           `xml:"type,attr"`
+	Source  usbHostdevSource `xml:"source"`
+}
+
+type usbHostdevSource struct {
+	Vendor  *usbHostdevID      `xml:"vendor"`
+	Product *usbHostdevID      `xml:"product"`
+	Address *usbHostdevAddress `xml:"address"`
+}
+
+type usbHostdevID struct {
+	ID string `xml:"id,attr"`
+}
+
+// Kept as strings, since PCI hostdevs (parsed alongside) use hex addresses
+type usbHostdevAddress struct {
+	Bus    string `xml:"bus,attr"`
+	Device string `xml:"device,attr"`
+}
+
+// Compare two addresses numerically. Values are decimal (and often zero
+// padded, such as "008") unless they start with "0x".
+func (address *usbHostdevAddress) equal(other *usbHostdevAddress) bool {
+	same := func(a string, b string) bool {
+		x, errA := parseUSBNumber(a)
+		y, errB := parseUSBNumber(b)
+		return errA == nil && errB == nil && x == y
+	}
+	return same(address.Bus, other.Bus) && same(address.Device, other.Device)
+}
+
+func parseUSBNumber(value string) (uint64, error) {
+	if hex, ok := strings.CutPrefix(strings.ToLower(value), "0x"); ok {
+		return strconv.ParseUint(hex, 16, 16)
+	}
+	return strconv.ParseUint(value, 10, 16)
+}
+
+// The USB hostdevs of a domain definition
+type usbHostdevs struct {
+	Hostdevs []usbHostdev `xml:"devices>hostdev"`
+}
+
+func (dev *HostDevice) String() string {
+	return fmt.Sprintf("%v %v %v %v", dev.Type, dev.Address, dev.Vendor, dev.Product)
+}
+
+// Enumerate all USB and PCI devices on the host along with the domains
+// which currently hold them. USB root hubs and PCI bridges are skipped,
+// since they can never be passed through in a useful way.
+func (c *Connection) EnumerateHostDevices() ([]*HostDevice, error) {
+	nodeDevices, err := c.ListAllNodeDevices(libvirt.CONNECT_LIST_NODE_DEVICES_CAP_USB_DEV | libvirt.CONNECT_LIST_NODE_DEVICES_CAP_PCI_DEV)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []*HostDevice{}
+	byAddress := map[string]*HostDevice{}
+	usbDevices := []*HostDevice{}
+	usbIDs := map[string]int{}
+
+	for _, nodeDevice := range nodeDevices {
+		description := libvirtxml.NodeDevice{}
+		xmlDesc, err := nodeDevice.GetXMLDesc(0)
+		nodeDevice.Free()
+		if err != nil {
+			return nil, err
+		} else if err := xml.Unmarshal([]byte(xmlDesc), &description); err != nil {
+			return nil, err
+		}
+
+		if device := newHostDevice(&description); device != nil {
+			devices = append(devices, device)
+			if device.usb != nil {
+				usbDevices = append(usbDevices, device)
+				usbIDs[device.usb.id()]++
+			} else {
+				byAddress[device.Address] = device
+			}
+		}
+	}
+
+	// Identical devices can only be told apart by their current address
+	for _, device := range usbDevices {
+		if usbIDs[device.usb.id()] > 1 {
+			device.usb.Source.Address = device.usbAddress()
+		}
+	}
+
+	domains, err := c.EnumerateAllDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, domain := range domains {
+		name, err := domain.GetName()
+		if err != nil {
+			return nil, err
+		}
+
+		description, err := domain.GetDescription(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if description.Devices != nil {
+			for idx := range description.Devices.Hostdevs {
+				if device, ok := byAddress[hostdevAddress(&description.Devices.Hostdevs[idx])]; ok {
+					device.Holders = append(device.Holders, name)
+				}
+			}
+		}
+
+		hostdevs, err := domain.usbHostdevs()
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range usbDevices {
+			for idx := range hostdevs {
+				if device.matchesUSB(&hostdevs[idx]) {
+					device.Holders = append(device.Holders, name)
+					break
+				}
+			}
+		}
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Type != devices[j].Type {
+			return devices[i].Type > devices[j].Type
+		}
+		return devices[i].Address < devices[j].Address
+	})
+
+	return devices, nil
+}
+
+func newHostDevice(description *libvirtxml.NodeDevice) *HostDevice {
+	if usb := description.Capability.USBDevice; usb != nil {
+		// Skip root hubs (Linux Foundation)
+		if usb.Vendor.ID == "0x1d6b" {
+			return nil
+		}
+
+		return &HostDevice{
+			Name:      description.Name,
+			Type:      "usb",
+			Address:   fmt.Sprintf("%03d:%03d", usb.Bus, usb.Device),
+			Vendor:    idName(usb.Vendor),
+			Product:   idName(usb.Product),
+			usbBus:    uint(usb.Bus),
+			usbDevice: uint(usb.Device),
+			usb: &usbHostdev{
+				Mode: "subsystem",
+				Type: "usb",
+				Source: usbHostdevSource{
+					Vendor:  &usbHostdevID{ID: strings.ToLower(usb.Vendor.ID)},
+					Product: &usbHostdevID{ID: strings.ToLower(usb.Product.ID)},
+				},
+			},
+		}
+	} else if pci := description.Capability.PCI; pci != nil {
+		// Skip bridges (PCI class 0x06)
+		if strings.HasPrefix(pci.Class, "0x06") || pci.Domain == nil || pci.Bus == nil || pci.Slot == nil || pci.Function == nil {
+			return nil
+		}
+
+		dev := &HostDevice{
+			Name:    description.Name,
+			Type:    "pci",
+			Vendor:  idName(pci.Vendor),
+			Product: idName(pci.Product),
+			hostdev: libvirtxml.DomainHostdev{
+				Managed: "yes",
+				SubsysPCI: &libvirtxml.DomainHostdevSubsysPCI{
+					Source: &libvirtxml.DomainHostdevSubsysPCISource{
+						Address: &libvirtxml.DomainAddressPCI{
+							Domain:   pci.Domain,
+							Bus:      pci.Bus,
+							Slot:     pci.Slot,
+							Function: pci.Function,
+						},
+					},
+				},
+			},
+		}
+		dev.Address = hostdevAddress(&dev.hostdev)
+		return dev
+	}
+
+	return nil
+}
+
+func idName(id libvirtxml.NodeDeviceIDName) string {
+	if id.Name != "" {
+		return id.Name
+	}
+	return id.ID
+}
+
+// Return the vendor and product ID of a USB hostdev
+func (hostdev *usbHostdev) id() string {
+	if hostdev.Source.Vendor == nil || hostdev.Source.Product == nil {
+		return ""
+	}
+	return strings.ToLower(hostdev.Source.Vendor.ID + ":" + hostdev.Source.Product.ID)
+}
+
+// Return the current bus address of a USB device
+func (dev *HostDevice) usbAddress() *usbHostdevAddress {
+	return &usbHostdevAddress{
+		Bus:    strconv.FormatUint(uint64(dev.usbBus), 10),
+		Device: strconv.FormatUint(uint64(dev.usbDevice), 10),
+	}
+}
+
+// Check whether a domain's USB hostdev refers to the device. Hostdevs are
+// matched by vendor and product ID, and by address if they have one (which
+// covers identical devices and hostdevs defined by address only).
+func (dev *HostDevice) matchesUSB(hostdev *usbHostdev) bool {
+	if hostdev.Type != "usb" {
+		return false
+	} else if id := hostdev.id(); id != "" && id != dev.usb.id() {
+		return false
+	} else if address := hostdev.Source.Address; address != nil {
+		return address.equal(dev.usbAddress())
+	}
+	return hostdev.id() != ""
+}
+
+// Parse the USB hostdevs from the domain's live definition
+func (dom *Domain) usbHostdevs() ([]usbHostdev, error) {
+	xmlDesc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return nil, err
+	}
+
+	hostdevs := usbHostdevs{}
+	if err := xml.Unmarshal([]byte(xmlDesc), &hostdevs); err != nil {
+		return nil, err
+	}
+	return hostdevs.Hostdevs, nil
+}
+
+// Return a key describing the host address of a PCI hostdev, used to match
+// domain hostdevs against host devices.
+func hostdevAddress(hostdev *libvirtxml.DomainHostdev) string {
+	if hostdev.SubsysPCI != nil && hostdev.SubsysPCI.Source != nil {
+		if address := hostdev.SubsysPCI.Source.Address; address != nil && address.Domain != nil && address.Bus != nil && address.Slot != nil && address.Function != nil {
+			return fmt.Sprintf("%04x:%02x:%02x.%x", *address.Domain, *address.Bus, *address.Slot, *address.Function)
+		}
+	}
+	return ""
+}
+
+// Pass a host device through to the domain
+func (dom *Domain) AttachHostDevice(device *HostDevice) error {
+	if device.usb != nil {
+		if hostdevXml, err := xml.Marshal(device.usb); err != nil {
+			return err
+		} else {
+			return dom.AttachDeviceFlags(string(hostdevXml), dom.deviceModifyFlags())
+		}
+	}
+
+	if hostdevXml, err := device.hostdev.Marshal(); err != nil {
+		return err
+	} else {
+		return dom.AttachDeviceFlags(hostdevXml, dom.deviceModifyFlags())
+	}
+}
+
+// Return a host device passed through to the domain back to the host
+func (dom *Domain) DetachHostDevice(device *HostDevice) error {
+	if device.usb != nil {
+		// Detach the hostdev as the domain defines it, which may be by address
+		hostdevs, err := dom.usbHostdevs()
+		if err != nil {
+			return err
+		}
+		for idx := range hostdevs {
+			if device.matchesUSB(&hostdevs[idx]) {
+				if hostdevXml, err := xml.Marshal(&hostdevs[idx]); err != nil {
+					return err
+				} else {
+					return dom.DetachDeviceFlags(string(hostdevXml), dom.deviceModifyFlags())
+				}
+			}
+		}
+		return fmt.Errorf("device '%v' is not passed through to the domain", device.Name)
+	}
+
+	if hostdevXml, err := device.hostdev.Marshal(); err != nil {
+		return err
+	} else {
+		return dom.DetachDeviceFlags(hostdevXml, dom.deviceModifyFlags())
+	}
+}
@@ -0,0 +1,151 @@
+package virt
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	lookingGlassShmemName  = "looking-glass"
+	lookingGlassShmemModel = "ivshmem-plain"
+)
+
+// Per-domain Looking Glass settings, stored in the vroomm metadata. Zero
+// values fall back to the application configuration.
+type LookingGlassMetadata struct {
+	Width  uint     `xml:"width,omitempty"`  // Guest display width
+	Height uint     `xml:"height,omitempty"` // Guest display height
+	Args   []string `xml:"arg"`              // Extra looking-glass-client arguments
+}
+
+// The state of the IVSHMEM device used by Looking Glass in a domain
+type LookingGlassStatus struct {
+	Present  bool   // Whether an ivshmem-plain shmem device exists
+	Name     string // Name of the shared memory object
+	Size     uint64 // Current size of the shared memory in bytes
+	Required uint64 // Size required for the display resolution in bytes
+}
+
+// Return the path of the shared memory file used by the client
+func (status *LookingGlassStatus) Path() string {
+	return "/dev/shm/" + status.Name
+}
+
+// Whether the device exists and is large enough for the display resolution
+func (status *LookingGlassStatus) Ready() bool {
+	return status.Present && status.Size >= status.Required
+}
+
+func (status *LookingGlassStatus) String() string {
+	if !status.Present {
+		return "no IVSHMEM device"
+	} else if status.Size < status.Required {
+		return fmt.Sprintf("%v too small (%v, needs %v)", status.Path(), FormatSize(status.Size), FormatSize(status.Required))
+	}
+	return fmt.Sprintf("ready (%v, %v)", status.Path(), FormatSize(status.Size))
+}
+
+// Compute the shared memory size needed for a display resolution. Looking
+// Glass needs two 32-bit frames plus 10MiB of overhead, rounded up to the
+// next power of two.
+func LookingGlassSize(width uint, height uint) uint64 {
+	required := uint64(width)*uint64(height)*4*2 + 10*1024*1024
+
+	size := uint64(1024 * 1024)
+	for size < required {
+		size <<= 1
+	}
+
+	return size
+}
+
+// Inspect a domain description for a Looking Glass IVSHMEM device
+func InspectLookingGlass(description *libvirtxml.Domain, width uint, height uint) *LookingGlassStatus {
+	status := &LookingGlassStatus{
+		Name:     lookingGlassShmemName,
+		Required: LookingGlassSize(width, height),
+	}
+
+	if shmem := findLookingGlassShmem(description); shmem != nil {
+		status.Present = true
+		status.Name = shmem.Name
+		if shmem.Size != nil {
+			status.Size = scaleSize(uint64(shmem.Size.Value), shmem.Size.Unit)
+		}
+	}
+
+	return status
+}
+
+func findLookingGlassShmem(description *libvirtxml.Domain) *libvirtxml.DomainShmem {
+	if description.Devices == nil {
+		return nil
+	}
+
+	var found *libvirtxml.DomainShmem
+	for idx := range description.Devices.Shmems {
+		shmem := &description.Devices.Shmems[idx]
+		if shmem.Model == nil || shmem.Model.Type != lookingGlassShmemModel {
+			continue
+		} else if shmem.Name == lookingGlassShmemName {
+			return shmem
+		} else if found == nil {
+			found = shmem
+		}
+	}
+
+	return found
+}
+
+// Inspect the persistent definition of the domain for a Looking Glass device
+func (dom *Domain) LookingGlass(width uint, height uint) (*LookingGlassStatus, error) {
+	description, err := dom.GetDescription(libvirt.DOMAIN_XML_INACTIVE)
+	if err != nil {
+		return nil, err
+	}
+	return InspectLookingGlass(description, width, height), nil
+}
+
+// Add an IVSHMEM device sized for the given resolution, or grow an existing
+// one which is too small. Shared memory devices cannot be hotplugged, so the
+// change only applies to the persistent definition and takes effect on the
+// next boot.
+func (dom *Domain) SetupLookingGlass(virt *Connection, width uint, height uint) (*LookingGlassStatus, error) {
+	description, err := dom.GetDescription(libvirt.DOMAIN_XML_INACTIVE | libvirt.DOMAIN_XML_SECURE)
+	if err != nil {
+		return nil, err
+	}
+
+	status := InspectLookingGlass(description, width, height)
+	if status.Ready() {
+		return status, nil
+	}
+
+	size := &libvirtxml.DomainShmemSize{
+		Value: uint(status.Required / (1024 * 1024)),
+		Unit:  "M",
+	}
+
+	if shmem := findLookingGlassShmem(description); shmem != nil {
+		shmem.Size = size
+	} else {
+		description.Devices.Shmems = append(description.Devices.Shmems, libvirtxml.DomainShmem{
+			Name: lookingGlassShmemName,
+			Size: size,
+			Model: &libvirtxml.DomainShmemModel{
+				Type: lookingGlassShmemModel,
+			},
+		})
+	}
+
+	if xmlDesc, err := xml.Marshal(description); err != nil {
+		return nil, err
+	} else if _, err := virt.DomainDefineXML(string(xmlDesc)); err != nil {
+		return nil, err
+	}
+
+	return InspectLookingGlass(description, width, height), nil
+}
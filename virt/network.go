@@ -0,0 +1,186 @@
+package virt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+type Network struct {
+	libvirt.Network // Core network connection
+}
+
+// A DHCP lease handed out by a libvirt network, mapped back to the domain
+// which owns the leased MAC address (if any).
+type NetworkLease struct {
+	MAC      string    // MAC address of the client
+	Address  string    // Leased address with prefix
+	Hostname string    // Hostname reported by the client
+	Domain   string    // Name of the domain owning the MAC address
+	Expiry   time.Time // When the lease expires
+}
+
+// A summary of a libvirt network's state and configuration
+type NetworkInfo struct {
+	Name      string         // Network name
+	Active    bool           // Whether the network is running
+	Autostart bool           // Whether the network starts with the host
+	Forward   string         // Forwarding mode (nat, route, bridge, etc)
+	Bridge    string         // Host bridge device
+	Ranges    []string       // Subnets and DHCP ranges
+	Leases    []NetworkLease // Current DHCP leases (only for active networks)
+}
+
+func NewNetwork(network libvirt.Network) (*Network, error) {
+	return &Network{
+		Network: network,
+	}, nil
+}
+
+func (c *Connection) EnumerateNetworks() ([]*Network, error) {
+	rawNetworks, err := c.ListAllNetworks(0)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := []*Network{}
+	for _, rawNetwork := range rawNetworks {
+		if network, err := NewNetwork(rawNetwork); err != nil {
+			return nil, err
+		} else {
+			networks = append(networks, network)
+		}
+	}
+
+	sort.Slice(networks, func(i, j int) bool {
+		left, _ := networks[i].GetName()
+		right, _ := networks[j].GetName()
+		return left < right
+	})
+
+	return networks, nil
+}
+
+func (c *Connection) LookupNetwork(name string) (*Network, error) {
+	if network, err := c.LookupNetworkByName(name); err != nil {
+		return nil, err
+	} else {
+		return NewNetwork(*network)
+	}
+}
+
+// Map the MAC address of every network interface of every domain to the
+// name of the domain.
+func (c *Connection) DomainsByMAC() (map[string]string, error) {
+	domains, err := c.EnumerateAllDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := map[string]string{}
+	for _, domain := range domains {
+		name, err := domain.GetName()
+		if err != nil {
+			return nil, err
+		}
+
+		description, err := domain.GetDescription(0)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, iface := range description.Devices.Interfaces {
+			if iface.MAC != nil {
+				owners[strings.ToLower(iface.MAC.Address)] = name
+			}
+		}
+	}
+
+	return owners, nil
+}
+
+func (network *Network) GetDescription() (*libvirtxml.Network, error) {
+	description := &libvirtxml.Network{}
+	if xmlDesc, err := network.GetXMLDesc(0); err != nil {
+		return nil, err
+	} else if err := xml.Unmarshal([]byte(xmlDesc), description); err != nil {
+		return nil, err
+	}
+	return description, nil
+}
+
+// Collect the state, configuration and DHCP leases of the network. The owners
+// map is used to resolve lease MAC addresses to domains (see DomainsByMAC),
+// and may be nil.
+func (network *Network) Info(owners map[string]string) (*NetworkInfo, error) {
+	description, err := network.GetDescription()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &NetworkInfo{
+		Name:   description.Name,
+		Ranges: []string{},
+		Leases: []NetworkLease{},
+	}
+
+	if info.Active, err = network.IsActive(); err != nil {
+		return nil, err
+	} else if info.Autostart, err = network.GetAutostart(); err != nil {
+		return nil, err
+	}
+
+	if description.Forward != nil {
+		info.Forward = description.Forward.Mode
+	} else {
+		info.Forward = "isolated"
+	}
+	if description.Bridge != nil {
+		info.Bridge = description.Bridge.Name
+	}
+
+	for _, ip := range description.IPs {
+		subnet := ip.Address
+		if ip.Prefix != 0 {
+			subnet = fmt.Sprintf("%v/%v", ip.Address, ip.Prefix)
+		} else if ip.Netmask != "" {
+			size, _ := net.IPMask(net.ParseIP(ip.Netmask).To4()).Size()
+			subnet = fmt.Sprintf("%v/%v", ip.Address, size)
+		}
+
+		if ip.DHCP != nil {
+			for _, dhcpRange := range ip.DHCP.Ranges {
+				subnet = fmt.Sprintf("%v (dhcp %v - %v)", subnet, dhcpRange.Start, dhcpRange.End)
+			}
+		}
+
+		info.Ranges = append(info.Ranges, subnet)
+	}
+
+	if !info.Active {
+		return info, nil
+	}
+
+	leases, err := network.GetDHCPLeases()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lease := range leases {
+		info.Leases = append(info.Leases, NetworkLease{
+			MAC:      lease.Mac,
+			Address:  fmt.Sprintf("%v/%v", lease.IPaddr, lease.Prefix),
+			Hostname: lease.Hostname,
+			Domain:   owners[strings.ToLower(lease.Mac)],
+			Expiry:   lease.ExpiryTime,
+		})
+	}
+
+	return info, nil
+}
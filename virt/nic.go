@@ -0,0 +1,208 @@
+package virt
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// The kinds of interface sources supported when attaching or moving NICs
+const (
+	InterfaceSourceNetwork = "network" // A libvirt managed network
+	InterfaceSourceBridge  = "bridge"  // An existing host bridge device
+)
+
+// Generate a random MAC address in the QEMU/KVM OUI (52:54:00)
+func GenerateMAC() (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", suffix[0], suffix[1], suffix[2]), nil
+}
+
+// Return all network interfaces of the domain
+func (dom *Domain) Interfaces() ([]libvirtxml.DomainInterface, error) {
+	description, err := dom.GetDescription(0)
+	if err != nil {
+		return nil, err
+	}
+	return description.Devices.Interfaces, nil
+}
+
+// Find a network interface by its MAC address
+func (dom *Domain) LookupInterface(mac string) (*libvirtxml.DomainInterface, error) {
+	interfaces, err := dom.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range interfaces {
+		if interfaces[idx].MAC != nil && strings.EqualFold(interfaces[idx].MAC.Address, mac) {
+			return &interfaces[idx], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no interface with MAC address '%v'", mac)
+}
+
+// Return the kind ("network" or "bridge") and name of the interface source,
+// or empty strings for other interface types.
+func InterfaceSource(iface *libvirtxml.DomainInterface) (string, string) {
+	if iface.Source == nil {
+		return "", ""
+	} else if iface.Source.Network != nil {
+		return InterfaceSourceNetwork, iface.Source.Network.Network
+	} else if iface.Source.Bridge != nil {
+		return InterfaceSourceBridge, iface.Source.Bridge.Bridge
+	}
+	return "", ""
+}
+
+// Return whether the link of the interface is up
+func InterfaceLinkUp(iface *libvirtxml.DomainInterface) bool {
+	return iface.Link == nil || iface.Link.State != "down"
+}
+
+func newInterfaceSource(kind string, source string) (*libvirtxml.DomainInterfaceSource, error) {
+	switch kind {
+	case InterfaceSourceNetwork:
+		return &libvirtxml.DomainInterfaceSource{
+			Network: &libvirtxml.DomainInterfaceSourceNetwork{
+				Network: source,
+			},
+		}, nil
+	case InterfaceSourceBridge:
+		return &libvirtxml.DomainInterfaceSource{
+			Bridge: &libvirtxml.DomainInterfaceSourceBridge{
+				Bridge: source,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported interface source type '%v'", kind)
+	}
+}
+
+// Attach a new NIC with the given model (e.g. virtio, e1000e) to a libvirt
+// network or host bridge. The MAC address of the new NIC is returned.
+func (dom *Domain) AttachInterface(kind string, source string, model string) (string, error) {
+	mac, err := GenerateMAC()
+	if err != nil {
+		return "", err
+	}
+
+	ifaceSource, err := newInterfaceSource(kind, source)
+	if err != nil {
+		return "", err
+	}
+
+	iface := libvirtxml.DomainInterface{
+		MAC: &libvirtxml.DomainInterfaceMAC{
+			Address: mac,
+		},
+		Source: ifaceSource,
+	}
+	if model != "" {
+		iface.Model = &libvirtxml.DomainInterfaceModel{
+			Type: model,
+		}
+	}
+
+	if ifaceXml, err := iface.Marshal(); err != nil {
+		return "", err
+	} else if err := dom.AttachDeviceFlags(ifaceXml, dom.deviceModifyFlags()); err != nil {
+		return "", err
+	}
+
+	return mac, nil
+}
+
+// Detach the NIC with the given MAC address
+func (dom *Domain) DetachInterface(mac string) error {
+	iface, err := dom.LookupInterface(mac)
+	if err != nil {
+		return err
+	}
+
+	if ifaceXml, err := iface.Marshal(); err != nil {
+		return err
+	} else {
+		return dom.DetachDeviceFlags(ifaceXml, dom.deviceModifyFlags())
+	}
+}
+
+// Move the NIC with the given MAC address to another network or bridge
+func (dom *Domain) SetInterfaceSource(mac string, kind string, source string) error {
+	iface, err := dom.LookupInterface(mac)
+	if err != nil {
+		return err
+	}
+
+	if iface.Source, err = newInterfaceSource(kind, source); err != nil {
+		return err
+	}
+
+	return dom.updateInterface(iface)
+}
+
+// Set the link state of the NIC with the given MAC address. Taking the link
+// down is the virtual equivalent of pulling the cable.
+func (dom *Domain) SetInterfaceLink(mac string, up bool) error {
+	iface, err := dom.LookupInterface(mac)
+	if err != nil {
+		return err
+	}
+
+	iface.Link = &libvirtxml.DomainInterfaceLink{
+		State: "up",
+	}
+	if !up {
+		iface.Link.State = "down"
+	}
+
+	return dom.updateInterface(iface)
+}
+
+func (dom *Domain) updateInterface(iface *libvirtxml.DomainInterface) error {
+	// The live target device name is assigned by libvirt, and must not
+	// leak into the persistent definition.
+	iface.Target = nil
+
+	if ifaceXml, err := iface.Marshal(); err != nil {
+		return err
+	} else {
+		return dom.UpdateDeviceFlags(ifaceXml, dom.deviceModifyFlags())
+	}
+}
+
+// Give every NIC of the persistent definition a new random MAC address, so
+// a clone does not clash with the domain it was cloned from.
+func (dom *Domain) ResetMACs(virt *Connection) error {
+	description, err := dom.GetDescription(libvirt.DOMAIN_XML_INACTIVE | libvirt.DOMAIN_XML_SECURE)
+	if err != nil {
+		return err
+	} else if description.Devices == nil || len(description.Devices.Interfaces) == 0 {
+		return nil
+	}
+
+	for idx := range description.Devices.Interfaces {
+		mac, err := GenerateMAC()
+		if err != nil {
+			return err
+		}
+		description.Devices.Interfaces[idx].MAC = &libvirtxml.DomainInterfaceMAC{
+			Address: mac,
+		}
+	}
+
+	if xmlDesc, err := description.Marshal(); err != nil {
+		return err
+	} else if _, err := virt.DomainDefineXML(xmlDesc); err != nil {
+		return err
+	}
+
+	return nil
+}
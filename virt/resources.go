@@ -0,0 +1,69 @@
+package virt
+
+import (
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// Return the configured memory (in bytes) and vCPU count of the domain
+func (dom *Domain) Resources() (uint64, uint, error) {
+	description, err := dom.GetDescription(libvirt.DOMAIN_XML_INACTIVE)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	memory := uint64(0)
+	if description.Memory != nil {
+		memory = scaleSize(uint64(description.Memory.Value), description.Memory.Unit)
+	}
+
+	vcpus := uint(0)
+	if description.VCPU != nil {
+		vcpus = description.VCPU.Value
+	}
+
+	return memory, vcpus, nil
+}
+
+// Change the memory (in bytes) and vCPU count of the persistent definition.
+// A zero value leaves that setting alone. Running domains pick up the change
+// the next time they boot.
+func (dom *Domain) SetResources(virt *Connection, memory uint64, vcpus uint) error {
+	description, err := dom.GetDescription(libvirt.DOMAIN_XML_INACTIVE | libvirt.DOMAIN_XML_SECURE)
+	if err != nil {
+		return err
+	}
+
+	if memory > 0 {
+		kib := uint(memory >> 10)
+		description.Memory = &libvirtxml.DomainMemory{Value: kib, Unit: "KiB"}
+		description.CurrentMemory = &libvirtxml.DomainCurrentMemory{Value: kib, Unit: "KiB"}
+	}
+
+	if vcpus > 0 {
+		if description.VCPU == nil {
+			description.VCPU = &libvirtxml.DomainVCPU{}
+		}
+		description.VCPU.Value = vcpus
+		description.VCPU.Current = 0
+		description.VCPUs = nil
+
+		// An explicit topology must multiply out to the vCPU count
+		if description.CPU != nil && description.CPU.Topology != nil {
+			description.CPU.Topology = &libvirtxml.DomainCPUTopology{
+				Sockets: 1,
+				Dies:    1,
+				Cores:   int(vcpus),
+				Threads: 1,
+			}
+		}
+	}
+
+	if xmlDesc, err := description.Marshal(); err != nil {
+		return err
+	} else if _, err := virt.DomainDefineXML(xmlDesc); err != nil {
+		return err
+	}
+
+	return nil
+}
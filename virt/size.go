@@ -0,0 +1,77 @@
+package virt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Convert a libvirt scaled integer (e.g. a volume capacity or domain
+// memory size) into bytes. Libvirt accepts both power-of-two and
+// power-of-ten units, and defaults to bytes when no unit is given.
+func scaleSize(value uint64, unit string) uint64 {
+	switch strings.ToLower(unit) {
+	case "", "b", "bytes":
+		return value
+	case "kb":
+		return value * 1000
+	case "k", "kib":
+		return value << 10
+	case "mb":
+		return value * 1000 * 1000
+	case "m", "mib":
+		return value << 20
+	case "gb":
+		return value * 1000 * 1000 * 1000
+	case "g", "gib":
+		return value << 30
+	case "tb":
+		return value * 1000 * 1000 * 1000 * 1000
+	case "t", "tib":
+		return value << 40
+	default:
+		return value
+	}
+}
+
+// Format a byte count as a human readable power-of-two size
+func FormatSize(bytes uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	value := float64(bytes)
+	idx := 0
+	for value >= 1024 && idx < len(units)-1 {
+		value /= 1024
+		idx++
+	}
+	if idx == 0 {
+		return fmt.Sprintf("%d %v", bytes, units[idx])
+	}
+	return fmt.Sprintf("%.1f %v", value, units[idx])
+}
+
+// Parse a user supplied size such as "20G", "512MiB" or "1.5T" into bytes.
+// Sizes without a unit are interpreted as GiB, since that is almost always
+// what is meant when sizing a disk.
+func ParseSize(text string) (uint64, error) {
+	text = strings.TrimSpace(text)
+	split := strings.IndexFunc(text, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+
+	number, unit := text, "G"
+	if split >= 0 {
+		number, unit = text[:split], strings.TrimSpace(text[split:])
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size '%v'", text)
+	}
+
+	scale := scaleSize(1, unit)
+	if scale == 1 && !strings.EqualFold(unit, "b") && !strings.EqualFold(unit, "bytes") {
+		return 0, fmt.Errorf("invalid size unit '%v'", unit)
+	}
+
+	return uint64(value * float64(scale)), nil
+}
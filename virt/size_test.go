@@ -0,0 +1,54 @@
+package virt
+
+import (
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    uint64
+		wantErr bool
+	}{
+		{text: "20", want: 20 << 30},
+		{text: "20G", want: 20 << 30},
+		{text: "20GiB", want: 20 << 30},
+		{text: "20 GiB", want: 20 << 30},
+		{text: " 512M ", want: 512 << 20},
+		{text: "1.5G", want: 3 << 29},
+		{text: "4gb", want: 4 * 1000 * 1000 * 1000},
+		{text: "1T", want: 1 << 40},
+		{text: "64k", want: 64 << 10},
+		{text: "100b", want: 100},
+		{text: "100 bytes", want: 100},
+		{text: "0", want: 0},
+		{text: "", wantErr: true},
+		{text: "G", wantErr: true},
+		{text: "1.2.3G", wantErr: true},
+		{text: "-1G", wantErr: true},
+		{text: "10 parsecs", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseSize(test.text)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseSize(%q): expected an error, got %v", test.text, got)
+			}
+		} else if err != nil {
+			t.Errorf("ParseSize(%q): unexpected error: %v", test.text, err)
+		} else if got != test.want {
+			t.Errorf("ParseSize(%q): got %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestFormatSizeRoundTrip(t *testing.T) {
+	for _, size := range []uint64{1 << 20, 512 << 20, 4 << 30, 2 << 40} {
+		if got, err := ParseSize(FormatSize(size)); err != nil {
+			t.Errorf("ParseSize(FormatSize(%v)): unexpected error: %v", size, err)
+		} else if got != size {
+			t.Errorf("ParseSize(FormatSize(%v)) = %v (%q)", size, got, FormatSize(size))
+		}
+	}
+}
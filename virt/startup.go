@@ -0,0 +1,77 @@
+package virt
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Conditions a domain must meet before domains depending on it are started
+const (
+	WaitNone  = ""      // Started is enough
+	WaitAgent = "agent" // The guest agent answers a ping
+	WaitIP    = "ip"    // An address of the domain is known
+)
+
+const (
+	defaultReadyTimeout = 5 * time.Minute
+	readyPollInterval   = time.Second
+)
+
+// Startup dependencies of a domain, stored in the vroomm metadata
+type StartupMetadata struct {
+	DependsOn []string `xml:"depends-on"`        // Names of domains which must be ready first
+	Wait      string   `xml:"wait,omitempty"`    // Readiness condition (agent, ip or empty)
+	Delay     uint     `xml:"delay,omitempty"`   // Seconds to wait once ready before starting dependents
+	Timeout   uint     `xml:"timeout,omitempty"` // Seconds to wait for readiness (default 300)
+}
+
+// How long to wait for the domain to become ready
+func (startup *StartupMetadata) ReadyTimeout() time.Duration {
+	if startup == nil || startup.Timeout == 0 {
+		return defaultReadyTimeout
+	}
+	return time.Duration(startup.Timeout) * time.Second
+}
+
+// Check a readiness condition name
+func ValidateWait(wait string) error {
+	switch wait {
+	case WaitNone, WaitAgent, WaitIP:
+		return nil
+	default:
+		return fmt.Errorf("unknown wait condition '%v' (expected agent or ip)", wait)
+	}
+}
+
+// Wait until the domain meets the readiness condition, polling until the
+// context is done.
+func (dom *Domain) WaitReady(ctx context.Context, wait string, sources []AddressSource) error {
+	if err := ValidateWait(wait); err != nil {
+		return err
+	} else if wait == WaitNone {
+		return nil
+	}
+
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		switch wait {
+		case WaitAgent:
+			if err := dom.agentCommand("guest-ping", nil, nil); err == nil {
+				return nil
+			}
+		case WaitIP:
+			if addresses, err := dom.ResolveAddresses(sources); err == nil && len(addresses) > 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %v: %w", wait, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package virt
+
+import (
+	"time"
+
+	"libvirt.org/go/libvirt"
+)
+
+// Raw resource counters of a running domain at a point in time
+type DomainSample struct {
+	Name        string    // Domain name
+	Time        time.Time // When the sample was taken
+	CPUTime     uint64    // Total CPU time used, in nanoseconds
+	VCPUs       int       // Number of online vCPUs
+	MemoryUsed  uint64    // Memory used by the guest in bytes (as far as it is known)
+	MemoryTotal uint64    // Memory currently assigned to the guest in bytes
+	BlockRead   uint64    // Total bytes read from all disks
+	BlockWrite  uint64    // Total bytes written to all disks
+	NetRx       uint64    // Total bytes received on all interfaces
+	NetTx       uint64    // Total bytes sent on all interfaces
+}
+
+// Resource usage of a domain between two samples
+type DomainRates struct {
+	CPU         float64 // CPU usage in percent of all vCPUs
+	MemoryUsed  uint64  // Memory used by the guest in bytes
+	MemoryTotal uint64  // Memory assigned to the guest in bytes
+	BlockRead   float64 // Disk read rate in bytes per second
+	BlockWrite  float64 // Disk write rate in bytes per second
+	NetRx       float64 // Network receive rate in bytes per second
+	NetTx       float64 // Network send rate in bytes per second
+}
+
+// Sample the resource counters of the given domains, or of every running
+// domain if none are given.
+func (c *Connection) SampleDomains(domains ...*Domain) ([]DomainSample, error) {
+	rawDomains := []*libvirt.Domain{}
+	for _, domain := range domains {
+		rawDomains = append(rawDomains, &domain.Domain)
+	}
+
+	flags := libvirt.ConnectGetAllDomainStatsFlags(0)
+	if len(rawDomains) == 0 {
+		flags = libvirt.CONNECT_GET_ALL_DOMAINS_STATS_ACTIVE
+	}
+
+	now := time.Now()
+	stats, err := c.GetAllDomainStats(
+		rawDomains,
+		libvirt.DOMAIN_STATS_CPU_TOTAL|libvirt.DOMAIN_STATS_BALLOON|libvirt.DOMAIN_STATS_VCPU|libvirt.DOMAIN_STATS_BLOCK|libvirt.DOMAIN_STATS_INTERFACE,
+		flags,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := []DomainSample{}
+	for _, stat := range stats {
+		name, err := stat.Domain.GetName()
+		stat.Domain.Free()
+		if err != nil {
+			return nil, err
+		}
+
+		samples = append(samples, newDomainSample(name, now, &stat))
+	}
+
+	return samples, nil
+}
+
+func newDomainSample(name string, now time.Time, stat *libvirt.DomainStats) DomainSample {
+	sample := DomainSample{
+		Name:  name,
+		Time:  now,
+		VCPUs: len(stat.Vcpu),
+	}
+
+	if stat.Cpu != nil {
+		sample.CPUTime = stat.Cpu.Time
+	}
+
+	// Balloon values are in KiB. Used memory is only accurate when the guest
+	// reports memory statistics, otherwise fall back to the QEMU process RSS.
+	if balloon := stat.Balloon; balloon != nil {
+		sample.MemoryTotal = balloon.Current << 10
+		if balloon.AvailableSet && balloon.UnusedSet {
+			sample.MemoryUsed = (balloon.Available - balloon.Unused) << 10
+		} else if balloon.RssSet {
+			sample.MemoryUsed = balloon.Rss << 10
+		}
+	}
+
+	for _, block := range stat.Block {
+		sample.BlockRead += block.RdBytes
+		sample.BlockWrite += block.WrBytes
+	}
+
+	for _, net := range stat.Net {
+		sample.NetRx += net.RxBytes
+		sample.NetTx += net.TxBytes
+	}
+
+	return sample
+}
+
+// Compute the resource usage between a previous and current sample
+func (sample *DomainSample) Rates(previous *DomainSample) DomainRates {
+	rates := DomainRates{
+		MemoryUsed:  sample.MemoryUsed,
+		MemoryTotal: sample.MemoryTotal,
+	}
+
+	elapsed := sample.Time.Sub(previous.Time).Seconds()
+	if elapsed <= 0 {
+		return rates
+	}
+
+	// Counters reset when the domain restarts, which would produce garbage
+	delta := func(now uint64, before uint64) float64 {
+		if now < before {
+			return 0
+		}
+		return float64(now-before) / elapsed
+	}
+
+	if sample.VCPUs > 0 {
+		rates.CPU = delta(sample.CPUTime, previous.CPUTime) / 1e9 / float64(sample.VCPUs) * 100
+	}
+	rates.BlockRead = delta(sample.BlockRead, previous.BlockRead)
+	rates.BlockWrite = delta(sample.BlockWrite, previous.BlockWrite)
+	rates.NetRx = delta(sample.NetRx, previous.NetRx)
+	rates.NetTx = delta(sample.NetTx, previous.NetTx)
+
+	return rates
+}
@@ -0,0 +1,110 @@
+package virt
+
+import (
+	"encoding/xml"
+	"errors"
+	"sort"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// Return the names of all active storage pools
+func (c *Connection) StoragePoolNames() ([]string, error) {
+	pools, err := c.ListAllStoragePools(libvirt.CONNECT_LIST_STORAGE_POOLS_ACTIVE)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, pool := range pools {
+		if name, err := pool.GetName(); err == nil {
+			names = append(names, name)
+		}
+		pool.Free()
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Return the paths of all ISO images in active storage pools
+func (c *Connection) ListInstallMedia() ([]string, error) {
+	volumes, err := c.collectVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for path, volume := range volumes {
+		if volume.isInstallMedia {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Create a raw volume in the named pool and upload data into it, returning
+// the path of the new volume. The volume is removed again if the upload
+// fails.
+func (c *Connection) UploadVolume(poolName string, name string, data []byte) (string, error) {
+	pool, err := c.LookupStoragePoolByName(poolName)
+	if err != nil {
+		return "", err
+	}
+	defer pool.Free()
+
+	volumeDescription := libvirtxml.StorageVolume{
+		Name: name,
+		Capacity: &libvirtxml.StorageVolumeSize{
+			Unit:  "bytes",
+			Value: uint64(len(data)),
+		},
+		Target: &libvirtxml.StorageVolumeTarget{
+			Format: &libvirtxml.StorageVolumeTargetFormat{
+				Type: "raw",
+			},
+		},
+	}
+
+	var volume *libvirt.StorageVol
+	if xmlDesc, err := xml.Marshal(&volumeDescription); err != nil {
+		return "", err
+	} else if volume, err = pool.StorageVolCreateXML(string(xmlDesc), 0); err != nil {
+		return "", err
+	}
+	defer volume.Free()
+
+	if err := c.uploadStream(volume, data); err != nil {
+		return "", errors.Join(err, volume.Delete(libvirt.STORAGE_VOL_DELETE_NORMAL))
+	}
+
+	return volume.GetPath()
+}
+
+// Send data to a volume through a libvirt stream, which also works for
+// remote connections.
+func (c *Connection) uploadStream(volume *libvirt.StorageVol, data []byte) error {
+	stream, err := c.NewStream(0)
+	if err != nil {
+		return err
+	}
+	defer stream.Free()
+
+	if err := volume.Upload(stream, 0, uint64(len(data)), 0); err != nil {
+		return err
+	}
+
+	for len(data) > 0 {
+		sent, err := stream.Send(data)
+		if err != nil {
+			stream.Abort()
+			return err
+		}
+		data = data[sent:]
+	}
+
+	return stream.Finish()
+}
@@ -0,0 +1,50 @@
+package virt
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// Returned when starting or editing a template
+	ErrTemplate = errors.New("VM is a template; create a new VM from it, or unmark it first")
+)
+
+// Whether the domain is marked as a template
+func (dom *Domain) IsTemplate() bool {
+	return dom.GetVmmData().Template
+}
+
+// Mark or unmark the domain as a template. Only shut off domains can become
+// templates, and autostart is turned off so the template is never booted
+// behind our back.
+func (dom *Domain) SetTemplate(template bool) error {
+	if template {
+		if active, err := dom.IsActive(); err != nil {
+			return err
+		} else if active {
+			return fmt.Errorf("shut the VM down before marking it as a template")
+		}
+
+		if err := dom.SetAutostart(false); err != nil {
+			return err
+		}
+	}
+
+	metadata := dom.GetVmmData()
+	metadata.Template = template
+	return dom.UpdateVmmData(metadata)
+}
+
+// Return ErrTemplate if the domain is a template
+func (dom *Domain) CheckNotTemplate() error {
+	if dom.IsTemplate() {
+		return ErrTemplate
+	}
+	return nil
+}
+
+// Whether the domain is deleted once it shuts off
+func (dom *Domain) IsDisposable() bool {
+	return dom.GetVmmData().Disposable
+}
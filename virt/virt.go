@@ -13,6 +13,10 @@ func New(connectionUri string) (*Connection, error) {
 
 	conn := &Connection{}
 
+	if err := startEventLoop(); err != nil {
+		return nil, err
+	}
+
 	conn.Connect, err = libvirt.NewConnect(connectionUri)
 	if err != nil {
 		return nil, err
@@ -21,6 +25,17 @@ func New(connectionUri string) (*Connection, error) {
 	return conn, nil
 }
 
+// Lookup a domain by either its name or UUID
+func (c *Connection) LookupDomain(nameOrUUID string) (*Domain, error) {
+	if domain, err := c.LookupDomainByName(nameOrUUID); err == nil {
+		return NewDomain(*domain)
+	} else if domain, uuidErr := c.LookupDomainByUUIDString(nameOrUUID); uuidErr == nil {
+		return NewDomain(*domain)
+	} else {
+		return nil, err
+	}
+}
+
 func (c *Connection) EnumerateActiveDomains() ([]*Domain, error) {
 	rawDomains, err := c.ListAllDomains(libvirt.CONNECT_LIST_DOMAINS_ACTIVE)
 	if err != nil {